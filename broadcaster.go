@@ -0,0 +1,68 @@
+package ldclient
+
+import (
+	"sync"
+)
+
+// Keeps track of the subscribers to a stream of values, such as the analytics events delivered by eventTap
+// or the changes delivered by dataChangeBroadcaster. Each subscription type has its own channel type, so
+// the subscription delivers values itself; the broadcaster only manages the set of subscriptions and makes
+// sure that every channel is closed exactly once.
+type broadcaster struct {
+	subs   []broadcastSubscription
+	closed bool
+	lock   sync.Mutex
+}
+
+// Implemented by the subscription types that are managed by a broadcaster.
+type broadcastSubscription interface {
+	// Closes the subscription's channel. The broadcaster calls this at most once.
+	closeChannel()
+}
+
+// Adds a subscription. If the broadcaster has already been closed, the subscription's channel is closed
+// immediately instead.
+func (b *broadcaster) subscribe(sub broadcastSubscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		sub.closeChannel()
+	} else {
+		b.subs = append(b.subs, sub)
+	}
+}
+
+func (b *broadcaster) unsubscribe(sub broadcastSubscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i, s := range b.subs {
+		if s == sub {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			sub.closeChannel() // if it's no longer in the list, the channel was already closed by close()
+			break
+		}
+	}
+}
+
+// Calls fn with the current subscriptions. The lock is held until fn returns, so that no subscription's
+// channel can be closed while values are being sent to it; therefore, fn must not block.
+func (b *broadcaster) withSubscriptions(fn func(subs []broadcastSubscription)) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if len(b.subs) > 0 {
+		fn(b.subs)
+	}
+}
+
+func (b *broadcaster) close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, sub := range b.subs {
+		sub.closeChannel()
+	}
+	b.subs = nil
+}
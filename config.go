@@ -16,6 +16,18 @@ type Config struct {
 	BaseUri string
 	// The base URI of the LaunchDarkly streaming service. This should not normally be changed except for testing.
 	StreamUri string
+	// A list of base URIs for the main LaunchDarkly service, in order of preference: for instance, two Relay
+	// Proxy instances followed by LaunchDarkly itself. If this is non-empty, BaseUri is ignored. The SDK uses
+	// the most preferred endpoint that is working, and returns to a preferred endpoint once it has recovered;
+	// see EndpointFailbackInterval.
+	BaseUris []string
+	// A list of base URIs for the streaming service, in order of preference. If this is non-empty, StreamUri
+	// is ignored. This works in the same way as BaseUris.
+	StreamUris []string
+	// How long the SDK avoids an endpoint in BaseUris or StreamUris after a request to it has failed. After
+	// this time, the SDK tries the endpoint again, switching back to it if it is preferred over the one in
+	// use. If zero, the default of 1 minute is used.
+	EndpointFailbackInterval time.Duration
 	// The base URI of the LaunchDarkly service that accepts analytics events. This should not normally be
 	// changed except for testing.
 	EventsUri string
@@ -24,20 +36,51 @@ type Config struct {
 	// causes EventsUri to be ignored.
 	EventsEndpointUri string
 	// The capacity of the events buffer. The client buffers up to this many events in memory before flushing.
-	// If the capacity is exceeded before the buffer is flushed, events will be discarded.
+	// If the capacity is exceeded before the buffer is flushed, events will be discarded. If AggregateMetrics
+	// is enabled, each metric aggregate counts as one event.
 	Capacity int
 	// The time between flushes of the event buffer. Decreasing the flush interval means that the event buffer
 	// is less likely to reach capacity.
 	FlushInterval time.Duration
+	// If non-zero, the event processor starts a flush as soon as this many events are waiting to be sent,
+	// without waiting for FlushInterval. This is only useful if it is less than Capacity.
+	FlushAfterEventCount int
+	// If non-zero, the event processor starts a flush as soon as the events that are waiting to be sent
+	// add up to approximately this many bytes of JSON, without waiting for FlushInterval. The size is
+	// estimated as events are received, and does not include the summary event.
+	FlushAfterBytes int
+	// Set to true to break down the flag evaluation counts in summary events by evaluation reason: which
+	// targeting rule was matched, whether the fallthrough variation was used, which error occurred, etc. This
+	// also makes the counts available locally through LDClient.GetEvaluationReasonCounts. It provides some
+	// of the insight of full feature events, at much lower cost.
+	SummarizeEvaluationReasons bool
 	// Enables event sampling if non-zero. When set to the default of zero, all events are sent to Launchdarkly.
 	// If greater than zero, there is a 1 in SamplingInterval chance that events will be sent (for example, a
 	// value of 20 means on average 5% of events will be sent).
 	//
-	// Deprecated: This feature will be removed in a future version of the SDK.
+	// Deprecated: This feature will be removed in a future version of the SDK. Use EventSampling instead.
 	SamplingInterval int32
+	// Sets sampling ratios for particular kinds of analytics events, or for events with particular flag
+	// keys or custom event keys. Summary counts are never sampled. See EventSamplingConfig.
+	EventSampling EventSamplingConfig
 	// The polling interval (when streaming is disabled). Values less than the default of MinimumPollInterval
 	// will be set to the default.
 	PollInterval time.Duration
+	// The proportion of PollInterval that is added, at random, to each delay between polls, so that many
+	// SDK instances that started at the same time will not all poll at the same time. For instance, 0.2
+	// means that each delay is between 100% and 120% of PollInterval. The default of zero means no jitter.
+	PollIntervalJitterRatio float64
+	// The path of a local file for saving the last response from the polling service, including its ETag.
+	// When the SDK restarts in polling mode, it then makes a conditional request with that ETag right away;
+	// if the data has not changed, the saved response is used, and it is not parsed again if the feature
+	// store already contains data (other than data loaded from SnapshotFile, which may be older). The saved
+	// response is replaced only when the ETag changes.
+	PollCacheFile string
+	// Set to true to save the last response from the polling service, as described for PollCacheFile, in
+	// the feature store instead of in a file, so that it is shared by all processes that use the same store.
+	// The redis, ldconsul, and lddynamodb stores support this. Consul and DynamoDB limit the size of a
+	// stored value (to 512KB and 400KB respectively), so for a large data set, use PollCacheFile instead.
+	PollCacheInFeatureStore bool
 	// An object that can be used to produce log output. Setting this property is equivalent to passing
 	// the same object to config.Loggers.SetBaseLogger().
 	//
@@ -59,13 +102,85 @@ type Config struct {
 	// Sets the implementation of FeatureStore for holding feature flags and related data received from
 	// LaunchDarkly. See NewInMemoryFeatureStoreFactory (the default) and the redis, ldconsul, and lddynamodb packages.
 	FeatureStoreFactory FeatureStoreFactory
+	// The path of a local file for saving the last known flag data. If this is set, the SDK writes the full
+	// set of flags and segments to the file each time it receives them from LaunchDarkly, and when it starts,
+	// it loads the file into the feature store before connecting. Until fresh data arrives, Initialized()
+	// returns false, but flag evaluations use the saved data instead of returning default values. The file
+	// is not loaded if the feature store already contains data (as a persistent store may), or if UseLdd
+	// is set.
+	SnapshotFile string
+	// For a persistent feature store that is shared between processes, how often a heartbeat is written
+	// to or read from the store. A client that receives data from LaunchDarkly writes the current time to
+	// the store at this interval, while it has a working connection (an open stream that has sent data, or
+	// a successful poll within the last two poll intervals), so that other processes can tell that the
+	// data is current. A client in daemon mode (UseLdd), or in offline mode with OfflineUseFeatureStore,
+	// reads the heartbeat at this interval instead; see LDClient.GetDataStoreFreshness. The redis, ldconsul,
+	// and lddynamodb stores support heartbeats. If zero, no heartbeat is written or read.
+	FeatureStoreHeartbeatInterval time.Duration
+	// For a client that reads the heartbeat described in FeatureStoreHeartbeatInterval, the maximum age of
+	// the data. If the most recent heartbeat is older than this, or there is none, the client logs an error
+	// and reports the data as stale. If zero, the data is never considered stale.
+	FeatureStoreMaxDataAge time.Duration
+	// Set to true to make flag evaluations return default values, with the error ErrStaleData, while the
+	// data is stale as described for FeatureStoreMaxDataAge.
+	UseDefaultsForStaleData bool
+	// If any of StoredFlagKeys, StoredFlagKeyPrefixes, or StoredFlagFilter is set, the SDK stores only the
+	// flags that match at least one of them, which reduces memory usage and the time taken to initialize
+	// the feature store in environments with many flags. Flags that are prerequisites of a stored flag, and
+	// segments that a stored flag refers to, are always stored as well, so that evaluations are correct;
+	// other segments are not stored. Evaluating a flag that was not stored returns the default value with
+	// the error FLAG_NOT_FOUND. The filter applies to data from the streaming and polling services, the
+	// SnapshotFile, and the ldfiledata package; a custom UpdateProcessor can apply it with FilterStoredData.
+	StoredFlagKeys []string
+	// Stores flags whose keys begin with any of these prefixes; see StoredFlagKeys.
+	StoredFlagKeyPrefixes []string
+	// A function that selects additional flags to store; see StoredFlagKeys. It must be safe to call from
+	// multiple goroutines.
+	StoredFlagFilter func(*FeatureFlag) bool
 	// Sets whether streaming mode should be enabled. By default, streaming is enabled. It should only be
 	// disabled on the advice of LaunchDarkly support.
 	Stream bool
+	// The delay before the first attempt to reconnect to the streaming service after a connection fails or
+	// is interrupted. Each consecutive attempt doubles the delay, up to StreamMaxReconnectDelay. If zero,
+	// the default of 1 second is used.
+	StreamInitialReconnectDelay time.Duration
+	// The maximum delay between attempts to reconnect to the streaming service. If zero, the default of 30
+	// seconds is used.
+	StreamMaxReconnectDelay time.Duration
+	// The proportion of each stream reconnection delay that is random, so that many SDK instances that lost
+	// their connections at the same time will not all reconnect at the same time. For instance, 0.5, which
+	// is the value in DefaultConfig, means that each delay is between 50% and 100% of the exponential backoff
+	// value. Zero means no jitter.
+	StreamReconnectJitterRatio float64
+	// How long a stream connection must remain open before the reconnection delay is reset to
+	// StreamInitialReconnectDelay. If zero, the default of 1 minute is used.
+	StreamBackoffResetInterval time.Duration
+	// If greater than zero, the SDK stops using the streaming service and polls for updates instead, once
+	// this many consecutive attempts to connect to the stream have failed. This can help in environments
+	// where a proxy does not allow long-lived connections. While polling, the SDK periodically tries the
+	// stream again (see StreamFallbackProbeInterval), and switches back to streaming if it succeeds. By
+	// default, the SDK never falls back to polling.
+	StreamFallbackFailureCount int
+	// If greater than zero, the SDK falls back to polling, as described for StreamFallbackFailureCount, if
+	// attempts to connect to the stream have been failing for at least this long.
+	StreamFallbackTimeout time.Duration
+	// How often the SDK tries to reconnect to the stream after falling back to polling. If zero, the
+	// default of 5 minutes is used.
+	StreamFallbackProbeInterval time.Duration
 	// Sets whether this client should use the LaunchDarkly relay in daemon mode. In this mode, the client does
 	// not subscribe to the streaming or polling API, but reads data only from the feature store. See:
 	// https://docs.launchdarkly.com/docs/the-relay-proxy
 	UseLdd bool
+	// In daemon mode (UseLdd), how often the client reads all of the data from the feature store to detect
+	// changes made by the Relay Proxy. Changes are reported by LDClient.SubscribeDataChanges. If the store has
+	// a cache (see utils.FeatureStoreWrapper), each scan also refreshes the cache. If zero, the client does
+	// not scan the store.
+	LddChangeDetectionInterval time.Duration
+	// In daemon mode, if LddChangeDetectionInterval is set, the data is reported as stale by
+	// LDClient.GetDaemonModeStatus if no changes have been detected for this long. If zero, the data is
+	// only reported as stale if the store cannot be read. This is ignored if FeatureStoreHeartbeatInterval
+	// is set, since the heartbeat is a better indication of whether the Relay Proxy is updating the store.
+	LddStaleAfter time.Duration
 	// Sets whether to send analytics events back to LaunchDarkly. By default, the client will send events. This
 	// differs from Offline in that it only affects sending events, not streaming or polling for events from the
 	// server.
@@ -73,14 +188,40 @@ type Config struct {
 	// Sets whether this client is offline. An offline client will not make any network connections to LaunchDarkly,
 	// and will return default values for all feature flags.
 	Offline bool
+	// Set to true, along with Offline, to make the offline client evaluate flags normally using whatever data is
+	// in the feature store: for instance, a persistent store that is populated by another process, a
+	// SnapshotFile, or a file data source (see the ldfiledata package). The client still makes no network
+	// connections to LaunchDarkly and sends no analytics events.
+	OfflineUseFeatureStore bool
 	// Sets whether or not all user attributes (other than the key) should be hidden from LaunchDarkly. If this
 	// is true, all user attribute values will be private, not just the attributes specified in PrivateAttributeNames.
 	AllAttributesPrivate bool
 	// Set to true if you need to see the full user details in every analytics event.
 	InlineUsersInEvents bool
 	// Marks a set of user attribute names private. Any users sent to LaunchDarkly with this configuration
-	// active will have attributes with these names removed.
+	// active will have attributes with these names removed. A name beginning with a slash, such as
+	// "/billing/cardLast4", refers to a property within a custom attribute whose value is a JSON object; only
+	// that property is removed, and the path is listed in the user's private attributes. A custom attribute
+	// whose name is exactly the same as the path, slash included, is also removed.
 	PrivateAttributeNames []string
+	// Set to true to use PublicAttributeNames as an allowlist: only the user attributes that are listed there
+	// (along with the key and anonymous attributes, which are always sent) will be included in analytics
+	// events, and all others will be private. This means that any newly added custom attribute is private
+	// by default. Attributes listed in PrivateAttributeNames are private even if they are also in
+	// PublicAttributeNames, and AllAttributesPrivate takes precedence over this setting.
+	OnlyPublicAttributes bool
+	// The names of user attributes that may be sent in analytics events if OnlyPublicAttributes is true.
+	// These are top-level names such as "country" or the name of a custom attribute. This has no effect
+	// unless OnlyPublicAttributes is true.
+	PublicAttributeNames []string
+	// If not empty, private user attributes are not removed from analytics events, but are replaced with
+	// a hex-encoded HMAC-SHA256 hash of their value using this key. This allows distinct values to be
+	// counted and correlated without sending the values themselves. The hashed attributes are still
+	// listed as private in the event data.
+	PrivateAttributeHashKey []byte
+	// Set to true to also replace the user key with its hash in analytics events. This has no effect
+	// unless PrivateAttributeHashKey is set.
+	HashUserKeyInEvents bool
 	// Sets whether the client should log a warning message whenever a flag cannot be evaluated due to an error
 	// (e.g. there is no flag with that key, or the user properties are invalid). By default, these messages are
 	// not logged, although you can detect such errors programmatically using the VariationDetail methods.
@@ -102,6 +243,35 @@ type Config struct {
 	UserKeysCapacity int
 	// The interval at which the event processor will reset its set of known user keys.
 	UserKeysFlushInterval time.Duration
+	// The maximum number of individual feature events (including debug events) and custom events that the
+	// event processor will send for any one user key within UserEventsLimitInterval. Events beyond the limit
+	// are not sent individually. Flag evaluations are still counted in summary events; custom events are
+	// counted as dropped events in LDClient.EventStats and in diagnostic data. The default of zero means
+	// there is no limit.
+	// The number of user keys that are tracked for this purpose is limited by UserKeysCapacity.
+	UserEventsLimit int
+	// The time window for UserEventsLimit. The default is one minute.
+	UserEventsLimitInterval time.Duration
+	// A list of user keys for which the event processor will always send a debug event, including the
+	// evaluation reason, for every flag evaluation. This makes it possible to trace what one user is
+	// seeing without turning on event tracking or debugging for the flag itself. These debug events are
+	// not subject to EventSampling or UserEventsLimit. The evaluation reason is only added to the debug
+	// events; other events for these users are unchanged.
+	DebugEventsUserKeys []string
+	// A function that selects additional users for debug events, in the same way as DebugEventsUserKeys.
+	// It is called once for every flag evaluation, so it should be fast, and it must be safe to call from
+	// multiple goroutines.
+	DebugEventsUserFilter func(User) bool
+	// Set to true to aggregate the values passed to TrackMetric locally, instead of sending a custom event
+	// for every call. For each event key and user, the event processor then sends one "metric-summary"
+	// event per flush, with the count, sum, minimum, and maximum of the values. The data parameter of
+	// TrackMetric is not sent for aggregated metrics. Aggregated metrics are not subject to EventSampling
+	// or UserEventsLimit, but each aggregate counts toward Capacity along with the other buffered events.
+	AggregateMetrics bool
+	// If AggregateMetrics is enabled, this optionally adds a histogram of the values to each metric-summary
+	// event. Each element is the inclusive upper bound of a bucket; values greater than the last bound are
+	// counted in an additional bucket.
+	MetricHistogramBounds []float64
 	// The User-Agent header to send with HTTP requests. This defaults to a value that identifies the version
 	// of the Go SDK for LaunchDarkly usage metrics.
 	UserAgent string
@@ -139,6 +309,30 @@ type Config struct {
 	HTTPClientFactory HTTPClientFactory
 	// Used internally to share a diagnosticsManager instance between components.
 	diagnosticsManager *diagnosticsManager
+	// Used internally to share the snapshot file between the client and the update processor.
+	snapshot *snapshotFile
+}
+
+// EventSamplingConfig specifies how often analytics events should be sent, for events of a particular
+// kind or with a particular key. Each interval has the same meaning as Config.SamplingInterval: a value
+// of N means that there is a 1 in N chance that an event will be sent, so 1 means all events are sent.
+// A value of zero means the interval is not set, in which case the next less specific setting is used.
+//
+// The data that is used for summary counts (the number of times each flag variation was evaluated) is
+// not affected by sampling; summary counts are always exact.
+type EventSamplingConfig struct {
+	// The sampling interval for full feature events, for flags that have event tracking enabled.
+	FeatureEventsInterval int32
+	// The sampling interval for debug events, for flags that have event debugging enabled.
+	DebugEventsInterval int32
+	// The sampling interval for custom events, as generated by TrackEvent, TrackData, and TrackMetric.
+	CustomEventsInterval int32
+	// Sampling intervals for feature and debug events with specific flag keys. These take precedence
+	// over FeatureEventsInterval and DebugEventsInterval.
+	FlagKeyIntervals map[string]int32
+	// Sampling intervals for custom events with specific event keys. These take precedence over
+	// CustomEventsInterval.
+	CustomEventKeyIntervals map[string]int32
 }
 
 // HTTPClientFactory is a function that creates a custom HTTP client.
@@ -151,6 +345,22 @@ type UpdateProcessorFactory func(sdkKey string, config Config) (UpdateProcessor,
 // the minimum will be used instead.
 const MinimumPollInterval = 30 * time.Second
 
+// Returns the base URIs of the main LaunchDarkly service in order of preference.
+func (c Config) baseUris() []string {
+	if len(c.BaseUris) > 0 {
+		return c.BaseUris
+	}
+	return []string{c.BaseUri}
+}
+
+// Returns the base URIs of the streaming service in order of preference.
+func (c Config) streamUris() []string {
+	if len(c.StreamUris) > 0 {
+		return c.StreamUris
+	}
+	return []string{c.StreamUri}
+}
+
 func (c Config) newHTTPClient() *http.Client {
 	factory := c.HTTPClientFactory
 	if factory == nil {
@@ -202,12 +412,17 @@ var DefaultConfig = Config{
 	PollInterval:                MinimumPollInterval,
 	Timeout:                     3000 * time.Millisecond,
 	Stream:                      true,
+	StreamInitialReconnectDelay: time.Second,
+	StreamMaxReconnectDelay:     30 * time.Second,
+	StreamReconnectJitterRatio:  0.5,
+	StreamBackoffResetInterval:  time.Minute,
 	FeatureStore:                nil,
 	UseLdd:                      false,
 	SendEvents:                  true,
 	Offline:                     false,
 	UserKeysCapacity:            1000,
 	UserKeysFlushInterval:       5 * time.Minute,
+	UserEventsLimitInterval:     time.Minute,
 	UserAgent:                   "",
 	Logger:                      defaultLogger,
 	DiagnosticRecordingInterval: 15 * time.Minute,
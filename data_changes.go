@@ -0,0 +1,97 @@
+package ldclient
+
+import (
+	"sync"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v4/ldlog"
+)
+
+// DataChangeEvent describes a change to a feature flag or segment that the client has detected. See
+// LDClient.SubscribeDataChanges.
+type DataChangeEvent struct {
+	// Kind is Features or Segments.
+	Kind VersionedDataKind
+	// Key is the key of the flag or segment.
+	Key string
+	// Version is the new version of the item, or zero if the item was deleted.
+	Version int
+}
+
+// DataChangeSubscription represents a subscription to data changes. See LDClient.SubscribeDataChanges.
+type DataChangeSubscription interface {
+	// Channel returns the channel for receiving change events.
+	Channel() <-chan DataChangeEvent
+	// Close stops the subscription, closing the channel.
+	Close()
+}
+
+// Optional interface implemented by UpdateProcessors that support LDClient.SubscribeDataChanges.
+type dataChangeSubscriber interface {
+	subscribeDataChanges() DataChangeSubscription
+}
+
+// The maximum number of change events that can be waiting in a subscriber's channel. If the subscriber
+// falls further behind than this, events are dropped rather than holding up the update processor.
+const dataChangeSubscriptionCapacity = 1000
+
+// Delivers change events to subscribers.
+type dataChangeBroadcaster struct {
+	broadcaster
+	loggers ldlog.Loggers
+}
+
+type dataChangeSubscription struct {
+	ch       chan DataChangeEvent
+	owner    *dataChangeBroadcaster
+	fullOnce sync.Once
+}
+
+func newDataChangeBroadcaster(loggers ldlog.Loggers) *dataChangeBroadcaster {
+	return &dataChangeBroadcaster{loggers: loggers}
+}
+
+func (b *dataChangeBroadcaster) subscribe() DataChangeSubscription {
+	sub := &dataChangeSubscription{
+		ch:    make(chan DataChangeEvent, dataChangeSubscriptionCapacity),
+		owner: b,
+	}
+	b.broadcaster.subscribe(sub)
+	return sub
+}
+
+// Delivers the given events to every subscriber. This never blocks: if a subscriber's channel is full,
+// the event is dropped for that subscriber.
+func (b *dataChangeBroadcaster) publish(events []DataChangeEvent) {
+	if len(events) == 0 {
+		return
+	}
+	b.withSubscriptions(func(subs []broadcastSubscription) {
+		for _, e := range events {
+			for _, sub := range subs {
+				sub.(*dataChangeSubscription).send(e)
+			}
+		}
+	})
+}
+
+func (s *dataChangeSubscription) send(e DataChangeEvent) {
+	select {
+	case s.ch <- e:
+	default:
+		s.fullOnce.Do(func() {
+			s.owner.loggers.Warn("A data change subscriber is not keeping up with changes; some changes will not be delivered to it")
+		})
+	}
+}
+
+func (s *dataChangeSubscription) closeChannel() {
+	close(s.ch)
+}
+
+func (s *dataChangeSubscription) Channel() <-chan DataChangeEvent {
+	return s.ch
+}
+
+func (s *dataChangeSubscription) Close() {
+	s.owner.unsubscribe(s)
+}
@@ -0,0 +1,185 @@
+package ldclient
+
+import (
+	"strings"
+)
+
+// Restricts which flags and segments are stored; see Config.StoredFlagKeys.
+type dataFilter struct {
+	keys      map[string]bool
+	prefixes  []string
+	predicate func(*FeatureFlag) bool
+	// The versions of the items that the store has as deleted, as far as we know, since FeatureStore.Get
+	// does not distinguish them from items that it doesn't have. This is only maintained by the stream
+	// processor, which calls recordInit and recordStored from a single goroutine.
+	deleted map[itemRef]int
+}
+
+// Identifies a flag or segment that a flag depends on.
+type itemRef struct {
+	kind VersionedDataKind
+	key  string
+}
+
+// Returns nil if no filter is configured.
+func newDataFilter(config Config) *dataFilter {
+	if len(config.StoredFlagKeys) == 0 && len(config.StoredFlagKeyPrefixes) == 0 && config.StoredFlagFilter == nil {
+		return nil
+	}
+	f := &dataFilter{
+		keys:      make(map[string]bool, len(config.StoredFlagKeys)),
+		prefixes:  config.StoredFlagKeyPrefixes,
+		predicate: config.StoredFlagFilter,
+		deleted:   make(map[itemRef]int),
+	}
+	for _, key := range config.StoredFlagKeys {
+		f.keys[key] = true
+	}
+	return f
+}
+
+// FilterStoredData returns the subset of a full data set that should be stored according to StoredFlagKeys,
+// StoredFlagKeyPrefixes, and StoredFlagFilter, including the prerequisites and segments that the selected
+// flags depend on. If none of those is set, it returns the data unchanged. A custom UpdateProcessor should
+// call this before FeatureStore.Init.
+func (c Config) FilterStoredData(
+	allData map[VersionedDataKind]map[string]VersionedData) map[VersionedDataKind]map[string]VersionedData {
+	if f := newDataFilter(c); f != nil {
+		return f.filterAll(allData)
+	}
+	return allData
+}
+
+// The flag is nil for a deletion, in which case only the key is checked.
+func (f *dataFilter) matchesFlag(key string, flag *FeatureFlag) bool {
+	if f.keys[key] {
+		return true
+	}
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return flag != nil && f.predicate != nil && f.predicate(flag)
+}
+
+// Returns the flags that match the filter, the flags that they use as prerequisites (at any depth), and
+// the segments that any of those flags refer to. Kinds of data other than flags and segments are kept.
+func (f *dataFilter) filterAll(
+	allData map[VersionedDataKind]map[string]VersionedData) map[VersionedDataKind]map[string]VersionedData {
+	ret := make(map[VersionedDataKind]map[string]VersionedData, len(allData))
+	for kind, items := range allData {
+		ret[kind] = items
+	}
+	ret[Features] = make(map[string]VersionedData)
+	ret[Segments] = make(map[string]VersionedData)
+
+	var pending []itemRef
+	for key, item := range allData[Features] {
+		if flag, ok := item.(*FeatureFlag); ok && f.matchesFlag(key, flag) {
+			pending = append(pending, itemRef{Features, key})
+		}
+	}
+	for len(pending) > 0 {
+		ref := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if _, done := ret[ref.kind][ref.key]; done {
+			continue
+		}
+		item, ok := allData[ref.kind][ref.key]
+		if !ok {
+			continue // evaluation reports a missing prerequisite or segment in the usual way
+		}
+		ret[ref.kind][ref.key] = item
+		if flag, ok := item.(*FeatureFlag); ok {
+			pending = append(pending, flagDependencies(flag)...)
+		}
+	}
+	return ret
+}
+
+// Returns true if an updated or deleted item should be applied to the store: that is, if it is a flag that
+// matches the filter, or if it is already in the store because a stored flag depends on it. The item is nil
+// for a deletion.
+func (f *dataFilter) shouldStore(store FeatureStore, kind VersionedDataKind, key string, item VersionedData) bool {
+	switch kind {
+	case Features:
+		flag, _ := item.(*FeatureFlag)
+		if f.matchesFlag(key, flag) {
+			return true
+		}
+	case Segments:
+	default:
+		return true
+	}
+	return f.isInStore(store, itemRef{kind, key})
+}
+
+// Returns the prerequisites and segments of a flag that are not in the store. This happens when an updated
+// flag starts to depend on something that was previously filtered out. Items that have been deleted are
+// not missing, since requesting them again would not find them.
+func (f *dataFilter) missingDependencies(store FeatureStore, flag *FeatureFlag) []itemRef {
+	var ret []itemRef
+	for _, ref := range flagDependencies(flag) {
+		if !f.isInStore(store, ref) {
+			ret = append(ret, ref)
+		}
+	}
+	return ret
+}
+
+// Returns true if the store has the item, or has it as deleted.
+func (f *dataFilter) isInStore(store FeatureStore, ref itemRef) bool {
+	if _, ok := f.deleted[ref]; ok {
+		return true
+	}
+	existing, _ := store.Get(ref.kind, ref.key)
+	return existing != nil
+}
+
+// Records the deleted items in a full data set that was stored, as returned by filterAll.
+func (f *dataFilter) recordInit(storeData map[VersionedDataKind]map[string]VersionedData) {
+	f.deleted = make(map[itemRef]int)
+	for kind, items := range storeData {
+		for key, item := range items {
+			if item.IsDeleted() {
+				f.deleted[itemRef{kind, key}] = item.GetVersion()
+			}
+		}
+	}
+}
+
+// Records an item that was updated or deleted in the store. Like the store, this ignores an item whose
+// version is not higher than that of a deleted item with the same key.
+func (f *dataFilter) recordStored(kind VersionedDataKind, key string, version int, deleted bool) {
+	ref := itemRef{kind, key}
+	deletedVersion, wasDeleted := f.deleted[ref]
+	if wasDeleted && version <= deletedVersion {
+		return
+	}
+	if deleted {
+		f.deleted[ref] = version
+	} else {
+		delete(f.deleted, ref)
+	}
+}
+
+func flagDependencies(flag *FeatureFlag) []itemRef {
+	var ret []itemRef
+	for _, prereq := range flag.Prerequisites {
+		ret = append(ret, itemRef{Features, prereq.Key})
+	}
+	for _, rule := range flag.Rules {
+		for _, clause := range rule.Clauses {
+			if clause.Op != OperatorSegmentMatch {
+				continue
+			}
+			for _, value := range clause.Values {
+				if key, ok := value.(string); ok {
+					ret = append(ret, itemRef{Segments, key})
+				}
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+package ldclient
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeDataFilterTestData() map[VersionedDataKind]map[string]VersionedData {
+	return MakeAllVersionedDataMap(
+		map[string]*FeatureFlag{
+			"svc-a": {Key: "svc-a", Prerequisites: []Prerequisite{{Key: "shared-1"}}},
+			"svc-b": {Key: "svc-b", Rules: []Rule{{Clauses: []Clause{
+				{Attribute: "", Op: OperatorSegmentMatch, Values: []interface{}{"segment-1"}},
+			}}}},
+			"shared-1": {Key: "shared-1", Prerequisites: []Prerequisite{{Key: "shared-2"}, {Key: "missing"}}},
+			"shared-2": {Key: "shared-2"},
+			"other": {Key: "other", Rules: []Rule{{Clauses: []Clause{
+				{Attribute: "", Op: OperatorSegmentMatch, Values: []interface{}{"segment-2"}},
+			}}}},
+			"special": {Key: "special", Salt: "x"},
+		},
+		map[string]*Segment{
+			"segment-1": {Key: "segment-1"},
+			"segment-2": {Key: "segment-2"},
+		},
+	)
+}
+
+func sortedKeys(items map[string]VersionedData) []string {
+	var keys []string
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestFilterStoredDataReturnsAllDataIfNoFilterIsConfigured(t *testing.T) {
+	data := makeDataFilterTestData()
+	assert.Equal(t, data, Config{}.FilterStoredData(data))
+}
+
+func TestFilterStoredDataIncludesDependencies(t *testing.T) {
+	config := Config{
+		StoredFlagKeyPrefixes: []string{"svc-"},
+		StoredFlagKeys:        []string{"unknown"},
+		StoredFlagFilter:      func(flag *FeatureFlag) bool { return flag.Salt == "x" },
+	}
+	filtered := config.FilterStoredData(makeDataFilterTestData())
+	assert.Equal(t, []string{"shared-1", "shared-2", "special", "svc-a", "svc-b"}, sortedKeys(filtered[Features]))
+	assert.Equal(t, []string{"segment-1"}, sortedKeys(filtered[Segments]))
+}
+
+func TestDataFilterShouldStore(t *testing.T) {
+	f := newDataFilter(Config{StoredFlagKeys: []string{"wanted"}})
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(
+		map[string]*FeatureFlag{"prereq": {Key: "prereq"}},
+		map[string]*Segment{"segment": {Key: "segment"}},
+	))
+
+	assert.True(t, f.shouldStore(store, Features, "wanted", &FeatureFlag{Key: "wanted"}))
+	assert.True(t, f.shouldStore(store, Features, "wanted", nil))
+	assert.True(t, f.shouldStore(store, Features, "prereq", &FeatureFlag{Key: "prereq"}))
+	assert.True(t, f.shouldStore(store, Segments, "segment", &Segment{Key: "segment"}))
+	assert.False(t, f.shouldStore(store, Features, "unwanted", &FeatureFlag{Key: "unwanted"}))
+	assert.False(t, f.shouldStore(store, Features, "unwanted", nil))
+	assert.False(t, f.shouldStore(store, Segments, "other-segment", &Segment{Key: "other-segment"}))
+}
+
+func TestDataFilterMissingDependencies(t *testing.T) {
+	f := newDataFilter(Config{StoredFlagKeys: []string{"flag"}})
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(map[string]*FeatureFlag{"prereq1": {Key: "prereq1"}}, nil))
+	flag := &FeatureFlag{
+		Key:           "flag",
+		Prerequisites: []Prerequisite{{Key: "prereq1"}, {Key: "prereq2"}},
+		Rules: []Rule{{Clauses: []Clause{
+			{Attribute: "key", Op: OperatorIn, Values: []interface{}{"x"}},
+			{Attribute: "", Op: OperatorSegmentMatch, Values: []interface{}{"segment"}},
+		}}},
+	}
+	assert.Equal(t, []itemRef{{Features, "prereq2"}, {Segments, "segment"}}, f.missingDependencies(store, flag))
+}
+
+func TestDataFilterTreatsDeletedItemsAsStored(t *testing.T) {
+	f := newDataFilter(Config{StoredFlagKeys: []string{"flag"}})
+	store := NewInMemoryFeatureStore(nil)
+	storeData := MakeAllVersionedDataMap(nil, map[string]*Segment{
+		"segment1": {Key: "segment1", Version: 1},
+		"segment2": {Key: "segment2", Version: 1, Deleted: true},
+	})
+	_ = store.Init(storeData)
+	f.recordInit(storeData)
+	_ = store.Delete(Segments, "segment1", 2)
+	f.recordStored(Segments, "segment1", 2, true)
+	flag := &FeatureFlag{Key: "flag", Rules: []Rule{{Clauses: []Clause{
+		{Attribute: "", Op: OperatorSegmentMatch, Values: []interface{}{"segment1", "segment2", "segment3"}},
+	}}}}
+
+	assert.Equal(t, []itemRef{{Segments, "segment3"}}, f.missingDependencies(store, flag))
+	assert.True(t, f.shouldStore(store, Segments, "segment1", &Segment{Key: "segment1", Version: 3}))
+	assert.True(t, f.shouldStore(store, Segments, "segment2", &Segment{Key: "segment2", Version: 3}))
+	assert.False(t, f.shouldStore(store, Segments, "segment3", &Segment{Key: "segment3", Version: 3}))
+
+	f.recordStored(Segments, "segment1", 1, false) // older than the deletion, so the store ignores it too
+	assert.True(t, f.shouldStore(store, Segments, "segment1", &Segment{Key: "segment1", Version: 3}))
+	f.recordInit(MakeAllVersionedDataMap(nil, nil))
+	assert.False(t, f.shouldStore(store, Segments, "segment1", &Segment{Key: "segment1", Version: 3}))
+}
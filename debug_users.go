@@ -0,0 +1,29 @@
+package ldclient
+
+// Decides whether a user has been selected for forced debug events, by Config.DebugEventsUserKeys or
+// Config.DebugEventsUserFilter. The client checks each evaluation once and records the result in the
+// event; the event processor only checks events that did not come from the client.
+type debugUserMatcher struct {
+	keys   map[string]struct{}
+	filter func(User) bool
+}
+
+func newDebugUserMatcher(config Config) debugUserMatcher {
+	m := debugUserMatcher{filter: config.DebugEventsUserFilter}
+	if len(config.DebugEventsUserKeys) > 0 {
+		m.keys = make(map[string]struct{}, len(config.DebugEventsUserKeys))
+		for _, k := range config.DebugEventsUserKeys {
+			m.keys[k] = struct{}{}
+		}
+	}
+	return m
+}
+
+func (m debugUserMatcher) matches(user User) bool {
+	if user.Key != nil {
+		if _, ok := m.keys[*user.Key]; ok {
+			return true
+		}
+	}
+	return m.filter != nil && m.filter(user)
+}
@@ -15,14 +15,16 @@ type diagnosticId struct {
 	SDKKeySuffix string `json:"sdkKeySuffix,omitempty"`
 }
 
-type diagnosticSDKData struct {
+// DiagnosticSDKData describes the SDK in Diagnostics.
+type DiagnosticSDKData struct {
 	Name           string `json:"name"`
 	Version        string `json:"version"`
 	WrapperName    string `json:"wrapperName,omitempty"`
 	WrapperVersion string `json:"wrapperVersion,omitempty"`
 }
 
-type diagnosticPlatformData struct {
+// DiagnosticPlatformData describes the runtime platform in Diagnostics.
+type DiagnosticPlatformData struct {
 	Name      string `json:"name"`
 	GoVersion string `json:"goVersion"`
 	OSArch    string `json:"osArch"`
@@ -30,31 +32,34 @@ type diagnosticPlatformData struct {
 	OSVersion string `json:"osVersion"`
 }
 
-type milliseconds int
+// DiagnosticMillis is a duration in milliseconds, as it is reported in diagnostic data.
+type DiagnosticMillis int
 
-type diagnosticConfigData struct {
+// DiagnosticConfigData describes the SDK configuration in Diagnostics. It does not include any
+// information that could identify the application, such as URIs or the SDK key.
+type DiagnosticConfigData struct {
 	CustomBaseURI               bool                   `json:"customBaseURI"`
 	CustomStreamURI             bool                   `json:"customStreamURI"`
 	CustomEventsURI             bool                   `json:"customEventsURI"`
 	DataStoreType               ldvalue.OptionalString `json:"dataStoreType"`
 	EventsCapacity              int                    `json:"eventsCapacity"`
-	ConnectTimeoutMillis        milliseconds           `json:"connectTimeoutMillis"`
-	SocketTimeoutMillis         milliseconds           `json:"socketTimeoutMillis"`
-	EventsFlushIntervalMillis   milliseconds           `json:"eventsFlushIntervalMillis"`
-	PollingIntervalMillis       milliseconds           `json:"pollingIntervalMillis"`
-	StartWaitMillis             milliseconds           `json:"startWaitMillis"`
+	ConnectTimeoutMillis        DiagnosticMillis       `json:"connectTimeoutMillis"`
+	SocketTimeoutMillis         DiagnosticMillis       `json:"socketTimeoutMillis"`
+	EventsFlushIntervalMillis   DiagnosticMillis       `json:"eventsFlushIntervalMillis"`
+	PollingIntervalMillis       DiagnosticMillis       `json:"pollingIntervalMillis"`
+	StartWaitMillis             DiagnosticMillis       `json:"startWaitMillis"`
 	SamplingInterval            int32                  `json:"samplingInterval"`
-	ReconnectTimeMillis         milliseconds           `json:"reconnectTimeMillis"`
+	ReconnectTimeMillis         DiagnosticMillis       `json:"reconnectTimeMillis"`
 	StreamingDisabled           bool                   `json:"streamingDisabled"`
 	UsingRelayDaemon            bool                   `json:"usingRelayDaemon"`
 	Offline                     bool                   `json:"offline"`
 	AllAttributesPrivate        bool                   `json:"allAttributesPrivate"`
 	InlineUsersInEvents         bool                   `json:"inlineUsersInEvents"`
 	UserKeysCapacity            int                    `json:"userKeysCapacity"`
-	UserKeysFlushIntervalMillis milliseconds           `json:"userKeysFlushIntervalMillis"`
+	UserKeysFlushIntervalMillis DiagnosticMillis       `json:"userKeysFlushIntervalMillis"`
 	UsingProxy                  bool                   `json:"usingProxy"`
 	// UsingProxyAuthenticator  bool         `json:"usingProxyAuthenticator"` // not knowable in Go SDK
-	DiagnosticRecordingIntervalMillis milliseconds `json:"diagnosticRecordingIntervalMillis"`
+	DiagnosticRecordingIntervalMillis DiagnosticMillis `json:"diagnosticRecordingIntervalMillis"`
 }
 
 type diagnosticBaseEvent struct {
@@ -65,9 +70,9 @@ type diagnosticBaseEvent struct {
 
 type diagnosticInitEvent struct {
 	diagnosticBaseEvent
-	SDK           diagnosticSDKData      `json:"sdk"`
-	Configuration diagnosticConfigData   `json:"configuration"`
-	Platform      diagnosticPlatformData `json:"platform"`
+	SDK           DiagnosticSDKData      `json:"sdk"`
+	Configuration DiagnosticConfigData   `json:"configuration"`
+	Platform      DiagnosticPlatformData `json:"platform"`
 }
 
 type diagnosticPeriodicEvent struct {
@@ -76,13 +81,14 @@ type diagnosticPeriodicEvent struct {
 	DroppedEvents     int                        `json:"droppedEvents"`
 	DeduplicatedUsers int                        `json:"deduplicatedUsers"`
 	EventsInLastBatch int                        `json:"eventsInLastBatch"`
-	StreamInits       []diagnosticStreamInitInfo `json:"streamInits"`
+	StreamInits       []DiagnosticStreamInitInfo `json:"streamInits"`
 }
 
-type diagnosticStreamInitInfo struct {
-	Timestamp      uint64       `json:"timestamp"`
-	Failed         bool         `json:"failed"`
-	DurationMillis milliseconds `json:"durationMillis"`
+// DiagnosticStreamInitInfo describes an attempt to connect to the streaming service.
+type DiagnosticStreamInitInfo struct {
+	Timestamp      uint64           `json:"timestamp"`
+	Failed         bool             `json:"failed"`
+	DurationMillis DiagnosticMillis `json:"durationMillis"`
 }
 
 type diagnosticsManager struct {
@@ -91,19 +97,24 @@ type diagnosticsManager struct {
 	startWaitTime     time.Duration // this is passed in separately because in Go, it's not part of the Config
 	startTime         uint64
 	dataSinceTime     uint64
-	streamInits       []diagnosticStreamInitInfo
+	streamInits       []DiagnosticStreamInitInfo
+	dataSource        DiagnosticDataSourceData // cumulative, unlike streamInits which is reset for each periodic event
+	storeLatency      *latencyRecorder
 	periodicEventGate <-chan struct{}
 	lock              sync.Mutex
 }
 
+// The maximum number of stream connection attempts that we will remember.
+const maxDiagnosticStreamInits = 100
+
 // Optional interface that can be implemented by components whose types can't be easily
 // determined by looking at the config object.
 type diagnosticsComponentDescriptor interface {
 	GetDiagnosticsComponentTypeName() string
 }
 
-func durationToMillis(d time.Duration) milliseconds {
-	return milliseconds(d / time.Millisecond)
+func durationToMillis(d time.Duration) DiagnosticMillis {
+	return DiagnosticMillis(d / time.Millisecond)
 }
 
 func newDiagnosticId(sdkKey string) diagnosticId {
@@ -133,37 +144,56 @@ func newDiagnosticsManager(
 		startWaitTime:     startWaitTime,
 		startTime:         timestamp,
 		dataSinceTime:     timestamp,
+		storeLatency:      newLatencyRecorder(),
 		periodicEventGate: periodicEventGate,
 	}
 	return m
 }
 
 // Called by the stream processor when a stream connection has either succeeded or failed.
-func (m *diagnosticsManager) RecordStreamInit(timestamp uint64, failed bool, durationMillis milliseconds) {
+func (m *diagnosticsManager) RecordStreamInit(timestamp uint64, failed bool, durationMillis DiagnosticMillis) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
-	m.streamInits = append(m.streamInits, diagnosticStreamInitInfo{
+	info := DiagnosticStreamInitInfo{
 		Timestamp:      timestamp,
 		Failed:         failed,
 		DurationMillis: durationMillis,
-	})
+	}
+	m.streamInits = appendStreamInit(m.streamInits, info)
+	m.dataSource.StreamInits = appendStreamInit(m.dataSource.StreamInits, info)
+	if m.dataSource.StreamConnectionAttempts > 0 {
+		m.dataSource.ReconnectCount++
+	}
+	m.dataSource.StreamConnectionAttempts++
+	if failed {
+		m.dataSource.StreamConnectionFailures++
+	}
+}
+
+// Appends to a list of stream connection attempts, discarding the oldest one if the list is full. If
+// the application has opted out of diagnostic events, nothing else would ever limit the list's size.
+func appendStreamInit(streamInits []DiagnosticStreamInitInfo, info DiagnosticStreamInitInfo) []DiagnosticStreamInitInfo {
+	if len(streamInits) >= maxDiagnosticStreamInits {
+		streamInits = append(streamInits[:0:0], streamInits[len(streamInits)-maxDiagnosticStreamInits+1:]...)
+	}
+	return append(streamInits, info)
 }
 
 // Called by DefaultEventProcessor to create the initial diagnostics event that includes the configuration.
 func (m *diagnosticsManager) CreateInitEvent() diagnosticInitEvent {
-	sdkData := diagnosticSDKData{
+	sdkData := DiagnosticSDKData{
 		Name:    "go-server-sdk",
 		Version: Version,
 	}
 	// Notes on configData
-	// - reconnectTimeMillis: hard-coded in eventsource because we're not overriding StreamOptionInitialRetry.
+	// - reconnectTimeMillis: the initial stream reconnection delay, after applying the default.
 	// - usingProxy: there are many ways to implement an HTTP proxy in Go, but the only one we're capable of
 	//   detecting is the HTTP_PROXY environment variable; programmatic approaches involve using a custom
 	//   transport, which we have no way of distinguishing from other kinds of custom transports (for the
 	//   same reason, we cannot detect if proxy authentication is being used).
-	configData := diagnosticConfigData{
-		CustomBaseURI:                     m.config.BaseUri != DefaultConfig.BaseUri,
-		CustomStreamURI:                   m.config.StreamUri != DefaultConfig.StreamUri,
+	configData := DiagnosticConfigData{
+		CustomBaseURI:                     isCustomURI(m.config.baseUris(), DefaultConfig.BaseUri),
+		CustomStreamURI:                   isCustomURI(m.config.streamUris(), DefaultConfig.StreamUri),
 		CustomEventsURI:                   m.config.EventsUri != DefaultConfig.EventsUri,
 		DataStoreType:                     getComponentTypeName(m.config.FeatureStore),
 		EventsCapacity:                    m.config.Capacity,
@@ -173,7 +203,7 @@ func (m *diagnosticsManager) CreateInitEvent() diagnosticInitEvent {
 		PollingIntervalMillis:             durationToMillis(m.config.PollInterval),
 		StartWaitMillis:                   durationToMillis(m.startWaitTime),
 		SamplingInterval:                  m.config.SamplingInterval,
-		ReconnectTimeMillis:               3000,
+		ReconnectTimeMillis:               durationToMillis(newStreamBackoff(m.config).initialDelay),
 		StreamingDisabled:                 !m.config.Stream,
 		UsingRelayDaemon:                  m.config.UseLdd,
 		Offline:                           m.config.Offline,
@@ -187,7 +217,7 @@ func (m *diagnosticsManager) CreateInitEvent() diagnosticInitEvent {
 	// Notes on platformData
 	// - osArch: in Go, GOARCH is set at compile time, not at runtime (unlike GOOS, whiich is runtime).
 	// - osVersion: Go provides no portable way to get this property.
-	platformData := diagnosticPlatformData{
+	platformData := DiagnosticPlatformData{
 		Name:      "Go",
 		GoVersion: runtime.Version(),
 		OSName:    normalizeOSName(runtime.GOOS),
@@ -249,6 +279,10 @@ func (m *diagnosticsManager) CreateStatsEventAndReset(
 	return event
 }
 
+func isCustomURI(uris []string, defaultURI string) bool {
+	return len(uris) != 1 || uris[0] != defaultURI
+}
+
 func getComponentTypeName(component interface{}) ldvalue.OptionalString {
 	if component != nil {
 		if dcd, ok := component.(diagnosticsComponentDescriptor); ok {
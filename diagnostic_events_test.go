@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/launchdarkly/go-sdk-common.v1/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v4/internal"
 )
 
 func TestDiagnosticIDHasRandomID(t *testing.T) {
@@ -53,8 +54,8 @@ func TestDiagnosticInitEventDefaultConfig(t *testing.T) {
 	assert.Equal(t, expectedDiagnosticConfigForDefaultConfig(), event.Configuration)
 }
 
-func expectedDiagnosticConfigForDefaultConfig() diagnosticConfigData {
-	return diagnosticConfigData{
+func expectedDiagnosticConfigForDefaultConfig() DiagnosticConfigData {
+	return DiagnosticConfigData{
 		CustomBaseURI:                     false,
 		CustomStreamURI:                   false,
 		CustomEventsURI:                   false,
@@ -63,9 +64,9 @@ func expectedDiagnosticConfigForDefaultConfig() diagnosticConfigData {
 		SocketTimeoutMillis:               durationToMillis(DefaultConfig.Timeout),
 		EventsFlushIntervalMillis:         durationToMillis(DefaultConfig.FlushInterval),
 		PollingIntervalMillis:             durationToMillis(DefaultConfig.PollInterval),
-		StartWaitMillis:                   milliseconds(5000),
+		StartWaitMillis:                   DiagnosticMillis(5000),
 		SamplingInterval:                  0,
-		ReconnectTimeMillis:               3000,
+		ReconnectTimeMillis:               1000,
 		StreamingDisabled:                 false,
 		UsingRelayDaemon:                  false,
 		Offline:                           false,
@@ -81,37 +82,38 @@ func TestDiagnosticEventCustomConfig(t *testing.T) {
 	id := newDiagnosticId("sdkkey")
 	tests := []struct {
 		setConfig   func(*Config)
-		setExpected func(*diagnosticConfigData)
+		setExpected func(*DiagnosticConfigData)
 	}{
-		{func(c *Config) { c.BaseUri = "custom" }, func(d *diagnosticConfigData) { d.CustomBaseURI = true }},
-		{func(c *Config) { c.StreamUri = "custom" }, func(d *diagnosticConfigData) { d.CustomStreamURI = true }},
-		{func(c *Config) { c.EventsUri = "custom" }, func(d *diagnosticConfigData) { d.CustomEventsURI = true }},
+		{func(c *Config) { c.BaseUri = "custom" }, func(d *DiagnosticConfigData) { d.CustomBaseURI = true }},
+		{func(c *Config) { c.StreamUri = "custom" }, func(d *DiagnosticConfigData) { d.CustomStreamURI = true }},
+		{func(c *Config) { c.EventsUri = "custom" }, func(d *DiagnosticConfigData) { d.CustomEventsURI = true }},
 		{func(c *Config) { c.FeatureStore = NewInMemoryFeatureStore(nil) },
-			func(d *diagnosticConfigData) {
+			func(d *DiagnosticConfigData) {
 				d.DataStoreType = ldvalue.NewOptionalString("memory")
 			}},
 		{func(c *Config) { c.FeatureStore = customStoreForDiagnostics{name: "Foo"} },
-			func(d *diagnosticConfigData) {
+			func(d *DiagnosticConfigData) {
 				d.DataStoreType = ldvalue.NewOptionalString("Foo")
 			}},
 		// Can't use our actual persistent store implementations (Redis, etc.) in this test because it'd be
 		// a circular package reference. There are tests in each of those packages to verify that they
 		// return the expected component type names.
-		{func(c *Config) { c.Capacity = 99 }, func(d *diagnosticConfigData) { d.EventsCapacity = 99 }},
-		{func(c *Config) { c.Timeout = time.Second }, func(d *diagnosticConfigData) {
+		{func(c *Config) { c.Capacity = 99 }, func(d *DiagnosticConfigData) { d.EventsCapacity = 99 }},
+		{func(c *Config) { c.Timeout = time.Second }, func(d *DiagnosticConfigData) {
 			d.ConnectTimeoutMillis = 1000
 			d.SocketTimeoutMillis = 1000
 		}},
-		{func(c *Config) { c.FlushInterval = time.Second }, func(d *diagnosticConfigData) { d.EventsFlushIntervalMillis = 1000 }},
-		{func(c *Config) { c.PollInterval = time.Second }, func(d *diagnosticConfigData) { d.PollingIntervalMillis = 1000 }},
-		{func(c *Config) { c.SamplingInterval = 2 }, func(d *diagnosticConfigData) { d.SamplingInterval = 2 }},
-		{func(c *Config) { c.Stream = false }, func(d *diagnosticConfigData) { d.StreamingDisabled = true }},
-		{func(c *Config) { c.UseLdd = true }, func(d *diagnosticConfigData) { d.UsingRelayDaemon = true }},
-		{func(c *Config) { c.AllAttributesPrivate = true }, func(d *diagnosticConfigData) { d.AllAttributesPrivate = true }},
-		{func(c *Config) { c.InlineUsersInEvents = true }, func(d *diagnosticConfigData) { d.InlineUsersInEvents = true }},
-		{func(c *Config) { c.UserKeysCapacity = 2 }, func(d *diagnosticConfigData) { d.UserKeysCapacity = 2 }},
-		{func(c *Config) { c.UserKeysFlushInterval = time.Second }, func(d *diagnosticConfigData) { d.UserKeysFlushIntervalMillis = 1000 }},
-		{func(c *Config) { c.DiagnosticRecordingInterval = time.Second }, func(d *diagnosticConfigData) { d.DiagnosticRecordingIntervalMillis = 1000 }},
+		{func(c *Config) { c.FlushInterval = time.Second }, func(d *DiagnosticConfigData) { d.EventsFlushIntervalMillis = 1000 }},
+		{func(c *Config) { c.PollInterval = time.Second }, func(d *DiagnosticConfigData) { d.PollingIntervalMillis = 1000 }},
+		{func(c *Config) { c.SamplingInterval = 2 }, func(d *DiagnosticConfigData) { d.SamplingInterval = 2 }},
+		{func(c *Config) { c.Stream = false }, func(d *DiagnosticConfigData) { d.StreamingDisabled = true }},
+		{func(c *Config) { c.StreamInitialReconnectDelay = 5 * time.Second }, func(d *DiagnosticConfigData) { d.ReconnectTimeMillis = 5000 }},
+		{func(c *Config) { c.UseLdd = true }, func(d *DiagnosticConfigData) { d.UsingRelayDaemon = true }},
+		{func(c *Config) { c.AllAttributesPrivate = true }, func(d *DiagnosticConfigData) { d.AllAttributesPrivate = true }},
+		{func(c *Config) { c.InlineUsersInEvents = true }, func(d *DiagnosticConfigData) { d.InlineUsersInEvents = true }},
+		{func(c *Config) { c.UserKeysCapacity = 2 }, func(d *DiagnosticConfigData) { d.UserKeysCapacity = 2 }},
+		{func(c *Config) { c.UserKeysFlushInterval = time.Second }, func(d *DiagnosticConfigData) { d.UserKeysFlushIntervalMillis = 1000 }},
+		{func(c *Config) { c.DiagnosticRecordingInterval = time.Second }, func(d *DiagnosticConfigData) { d.DiagnosticRecordingIntervalMillis = 1000 }},
 	}
 	for _, test := range tests {
 		config := DefaultConfig
@@ -156,3 +158,112 @@ func (c customStoreForDiagnostics) Upsert(kind VersionedDataKind, item Versioned
 func (c customStoreForDiagnostics) Initialized() bool {
 	return false
 }
+
+func TestDiagnosticsManagerRecordsCumulativeStreamInits(t *testing.T) {
+	m := newDiagnosticsManager(newDiagnosticId("sdkkey"), Config{}, time.Second, time.Now(), nil)
+	m.RecordStreamInit(1000, true, 100)
+	m.RecordStreamInit(2000, false, 200)
+	event := m.CreateStatsEventAndReset(0, 0, 0)
+	assert.Len(t, event.StreamInits, 2)
+	m.RecordStreamInit(3000, false, 300)
+
+	d := m.GetDiagnostics()
+	assert.Equal(t, []DiagnosticStreamInitInfo{
+		{Timestamp: 1000, Failed: true, DurationMillis: 100},
+		{Timestamp: 2000, Failed: false, DurationMillis: 200},
+		{Timestamp: 3000, Failed: false, DurationMillis: 300},
+	}, d.DataSource.StreamInits)
+	assert.Equal(t, 3, d.DataSource.StreamConnectionAttempts)
+	assert.Equal(t, 1, d.DataSource.StreamConnectionFailures)
+	assert.Equal(t, 2, d.DataSource.ReconnectCount)
+}
+
+func TestDiagnosticsManagerLimitsStreamInitHistory(t *testing.T) {
+	m := newDiagnosticsManager(newDiagnosticId("sdkkey"), Config{}, time.Second, time.Now(), nil)
+	for i := 0; i < maxDiagnosticStreamInits+5; i++ {
+		m.RecordStreamInit(uint64(i), false, 0)
+	}
+	d := m.GetDiagnostics()
+	assert.Len(t, d.DataSource.StreamInits, maxDiagnosticStreamInits)
+	assert.Equal(t, uint64(5), d.DataSource.StreamInits[0].Timestamp)
+	assert.Equal(t, maxDiagnosticStreamInits+5, d.DataSource.StreamConnectionAttempts)
+	assert.Len(t, m.CreateStatsEventAndReset(0, 0, 0).StreamInits, maxDiagnosticStreamInits)
+}
+
+func TestDiagnosticsManagerGetDiagnosticsIncludesInitEventData(t *testing.T) {
+	id := newDiagnosticId("sdkkey")
+	startTime := time.Now()
+	m := newDiagnosticsManager(id, DefaultConfig, 5*time.Second, startTime, nil)
+	initEvent := m.CreateInitEvent()
+
+	d := m.GetDiagnostics()
+	assert.Equal(t, id.DiagnosticID, d.DiagnosticID)
+	assert.Equal(t, toUnixMillis(startTime), d.CreationDate)
+	assert.Equal(t, initEvent.SDK, d.SDK)
+	assert.Equal(t, initEvent.Configuration, d.Configuration)
+	assert.Equal(t, initEvent.Platform, d.Platform)
+}
+
+func TestDiagnosticsDataStoreData(t *testing.T) {
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(
+		map[string]*FeatureFlag{"a": {Key: "a"}, "b": {Key: "b"}},
+		map[string]*Segment{"s": {Key: "s"}},
+	))
+	_ = store.Delete(Features, "b", 1)
+	m := newDiagnosticsManager(newDiagnosticId("sdkkey"), Config{FeatureStore: store}, time.Second, time.Now(), nil)
+	m.storeLatency.record(time.Millisecond)
+	m.storeLatency.record(3 * time.Millisecond)
+
+	data := m.GetDataStoreData()
+	assert.Equal(t, DiagnosticDataStoreData{
+		Type:           ldvalue.NewOptionalString("memory"),
+		FlagCount:      1,
+		SegmentCount:   1,
+		ReadCount:      2,
+		ReadLatencyP50: time.Millisecond,
+		ReadLatencyP90: 3 * time.Millisecond,
+		ReadLatencyP99: 3 * time.Millisecond,
+	}, data)
+}
+
+func TestDiagnosticsDataStoreDataIncludesCacheStats(t *testing.T) {
+	store := customStoreWithCacheStats{stats: internal.FeatureStoreCacheStats{Hits: 3, Misses: 1}}
+	m := newDiagnosticsManager(newDiagnosticId("sdkkey"), Config{FeatureStore: store}, time.Second, time.Now(), nil)
+
+	data := m.GetDataStoreData()
+	assert.Equal(t, int64(3), data.CacheHits)
+	assert.Equal(t, int64(1), data.CacheMisses)
+	assert.Equal(t, 0.75, data.CacheHitRatio)
+}
+
+func TestDiagnosticsDataStoreDataWithNoStore(t *testing.T) {
+	m := newDiagnosticsManager(newDiagnosticId("sdkkey"), Config{}, time.Second, time.Now(), nil)
+	assert.Equal(t, DiagnosticDataStoreData{}, m.GetDataStoreData())
+}
+
+func TestLatencyRecorderPercentiles(t *testing.T) {
+	r := newLatencyRecorder()
+	count, p50, p90, p99 := r.percentiles()
+	assert.Equal(t, int64(0), count)
+	assert.Equal(t, time.Duration(0), p50+p90+p99)
+
+	for i := latencySampleCount + 100; i > 0; i-- {
+		r.record(time.Duration(i))
+	}
+	// only the most recent 1000 samples, with values 1 to 1000, are retained
+	count, p50, p90, p99 = r.percentiles()
+	assert.Equal(t, int64(latencySampleCount+100), count)
+	assert.Equal(t, time.Duration(500), p50)
+	assert.Equal(t, time.Duration(900), p90)
+	assert.Equal(t, time.Duration(990), p99)
+}
+
+type customStoreWithCacheStats struct {
+	customStoreForDiagnostics
+	stats internal.FeatureStoreCacheStats
+}
+
+func (c customStoreWithCacheStats) GetCacheStats() internal.FeatureStoreCacheStats {
+	return c.stats
+}
@@ -0,0 +1,194 @@
+package ldclient
+
+import (
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/launchdarkly/go-sdk-common.v1/ldvalue"
+	"gopkg.in/launchdarkly/go-server-sdk.v4/internal"
+)
+
+// Diagnostics describes the configuration and current state of an LDClient. See LDClient.GetDiagnostics.
+//
+// This includes the same information that the SDK sends to LaunchDarkly in diagnostic events, unless
+// Config.DiagnosticOptOut is set, along with some additional details. The JSON property names are the
+// same as in diagnostic events.
+type Diagnostics struct {
+	// DiagnosticID is a randomly generated identifier for this client instance.
+	DiagnosticID string `json:"diagnosticId"`
+	// CreationDate is the time when the client was created, in Unix milliseconds.
+	CreationDate  uint64                 `json:"creationDate"`
+	SDK           DiagnosticSDKData      `json:"sdk"`
+	Configuration DiagnosticConfigData   `json:"configuration"`
+	Platform      DiagnosticPlatformData `json:"platform"`
+	// DataSource describes the connection to LaunchDarkly since the client was created.
+	DataSource DiagnosticDataSourceData `json:"dataSource"`
+	// DataStore describes the data store's current contents and performance.
+	DataStore DiagnosticDataStoreData `json:"dataStore"`
+	// Events is the same as the result of LDClient.EventStats.
+	Events EventStats `json:"events"`
+}
+
+// DiagnosticDataSourceData describes the client's connection to LaunchDarkly in Diagnostics. These
+// values are only recorded when using the streaming service.
+type DiagnosticDataSourceData struct {
+	// StreamInits describes the most recent connection attempts, oldest first. At most 100 are retained.
+	StreamInits []DiagnosticStreamInitInfo `json:"streamInits"`
+	// StreamConnectionAttempts is the total number of times the client has tried to connect.
+	StreamConnectionAttempts int `json:"streamConnectionAttempts"`
+	// StreamConnectionFailures is the number of connection attempts that failed.
+	StreamConnectionFailures int `json:"streamConnectionFailures"`
+	// ReconnectCount is the number of connection attempts after the first one, whether the previous
+	// attempt failed or the stream was interrupted.
+	ReconnectCount int `json:"reconnectCount"`
+}
+
+// DiagnosticDataStoreData describes the data store in Diagnostics.
+type DiagnosticDataStoreData struct {
+	// Type is the kind of data store, such as "memory" or "Redis".
+	Type ldvalue.OptionalString `json:"type"`
+	// FlagCount is the number of feature flags in the store, not counting deleted flags.
+	FlagCount int `json:"flagCount"`
+	// SegmentCount is the number of user segments in the store, not counting deleted segments.
+	SegmentCount int `json:"segmentCount"`
+	// ReadCount is the number of times the client has read from the store, while evaluating flags. This
+	// and the read latencies are only tracked if Config.DiagnosticOptOut is false; otherwise they are zero.
+	ReadCount int64 `json:"readCount"`
+	// ReadLatencyP50, ReadLatencyP90 and ReadLatencyP99 are percentiles of the time taken by the most
+	// recent reads (up to 1000 of them).
+	ReadLatencyP50 time.Duration `json:"readLatencyP50Nanos"`
+	ReadLatencyP90 time.Duration `json:"readLatencyP90Nanos"`
+	ReadLatencyP99 time.Duration `json:"readLatencyP99Nanos"`
+	// CacheHits and CacheMisses are the number of store queries that were or were not answered from the
+	// store's in-memory cache. These are always zero if the store does not have such a cache, as is the
+	// case for the default in-memory store.
+	CacheHits   int64 `json:"cacheHits"`
+	CacheMisses int64 `json:"cacheMisses"`
+	// CacheHitRatio is CacheHits divided by the total of CacheHits and CacheMisses, or zero if there have
+	// been no cache lookups.
+	CacheHitRatio float64 `json:"cacheHitRatio"`
+}
+
+// GetDiagnostics returns the diagnostic data for the client.
+func (m *diagnosticsManager) GetDiagnostics() Diagnostics {
+	initEvent := m.CreateInitEvent()
+	dataStore := m.GetDataStoreData()
+	m.lock.Lock()
+	dataSource := m.dataSource
+	dataSource.StreamInits = append([]DiagnosticStreamInitInfo(nil), m.dataSource.StreamInits...)
+	m.lock.Unlock()
+	return Diagnostics{
+		DiagnosticID:  m.id.DiagnosticID,
+		CreationDate:  m.startTime,
+		SDK:           initEvent.SDK,
+		Configuration: initEvent.Configuration,
+		Platform:      initEvent.Platform,
+		DataSource:    dataSource,
+		DataStore:     dataStore,
+	}
+}
+
+// GetDataStoreData returns the current state of the data store. This queries the store for the number
+// of flags and segments, which for a persistent store may require a database query if the data is not
+// cached.
+func (m *diagnosticsManager) GetDataStoreData() DiagnosticDataStoreData {
+	store := m.config.FeatureStore
+	if store == nil {
+		return DiagnosticDataStoreData{}
+	}
+	ret := DiagnosticDataStoreData{Type: getComponentTypeName(store)}
+	if flags, err := store.All(Features); err == nil {
+		ret.FlagCount = len(flags)
+	}
+	if segments, err := store.All(Segments); err == nil {
+		ret.SegmentCount = len(segments)
+	}
+	ret.ReadCount, ret.ReadLatencyP50, ret.ReadLatencyP90, ret.ReadLatencyP99 = m.storeLatency.percentiles()
+	if csp, ok := store.(internal.FeatureStoreCacheStatsProvider); ok {
+		cacheStats := csp.GetCacheStats()
+		ret.CacheHits, ret.CacheMisses = cacheStats.Hits, cacheStats.Misses
+		if total := cacheStats.Hits + cacheStats.Misses; total > 0 {
+			ret.CacheHitRatio = float64(cacheStats.Hits) / float64(total)
+		}
+	}
+	return ret
+}
+
+// The number of most recent samples that latencyRecorder uses to compute percentiles.
+const latencySampleCount = 1000
+
+// Records the durations of an operation in a fixed-size ring buffer.
+type latencyRecorder struct {
+	samples []time.Duration
+	next    int
+	count   int64
+	lock    sync.Mutex
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{samples: make([]time.Duration, 0, latencySampleCount)}
+}
+
+func (r *latencyRecorder) record(d time.Duration) {
+	r.lock.Lock()
+	if len(r.samples) < latencySampleCount {
+		r.samples = append(r.samples, d)
+	} else {
+		r.samples[r.next] = d
+	}
+	r.next = (r.next + 1) % latencySampleCount
+	r.count++
+	r.lock.Unlock()
+}
+
+// Returns the total number of recorded operations, and the 50th, 90th and 99th percentiles of the
+// retained samples using the nearest-rank method.
+func (r *latencyRecorder) percentiles() (count int64, p50, p90, p99 time.Duration) {
+	r.lock.Lock()
+	sorted := append([]time.Duration(nil), r.samples...)
+	count = r.count
+	r.lock.Unlock()
+	if len(sorted) == 0 {
+		return count, 0, 0, 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) time.Duration {
+		return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+	}
+	return count, rank(0.5), rank(0.9), rank(0.99)
+}
+
+// A FeatureStore wrapper that measures the time taken by reads. LDClient uses this for the reads it
+// does while evaluating flags; the data source uses the underlying store directly.
+type latencyTrackingFeatureStore struct {
+	FeatureStore
+	latency *latencyRecorder
+}
+
+func newLatencyTrackingFeatureStore(store FeatureStore, latency *latencyRecorder) *latencyTrackingFeatureStore {
+	return &latencyTrackingFeatureStore{FeatureStore: store, latency: latency}
+}
+
+func (s *latencyTrackingFeatureStore) Get(kind VersionedDataKind, key string) (VersionedData, error) {
+	startTime := time.Now()
+	item, err := s.FeatureStore.Get(kind, key)
+	s.latency.record(time.Since(startTime))
+	return item, err
+}
+
+func (s *latencyTrackingFeatureStore) All(kind VersionedDataKind) (map[string]VersionedData, error) {
+	startTime := time.Now()
+	items, err := s.FeatureStore.All(kind)
+	s.latency.record(time.Since(startTime))
+	return items, err
+}
+
+func (s *latencyTrackingFeatureStore) Close() error {
+	if c, ok := s.FeatureStore.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
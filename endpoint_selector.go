@@ -0,0 +1,103 @@
+package ldclient
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultEndpointFailbackInterval = time.Minute
+
+// Chooses among a list of service endpoints in order of preference. After a request to an endpoint
+// fails, that endpoint is avoided until failbackInterval has passed; then it is tried again, so that
+// the SDK returns to a preferred endpoint once it has recovered.
+type endpointSelector struct {
+	uris             []string
+	unhealthyUntil   []time.Time
+	failbackInterval time.Duration
+	lock             sync.Mutex
+}
+
+func newEndpointSelector(uris []string, failbackInterval time.Duration) *endpointSelector {
+	if failbackInterval <= 0 {
+		failbackInterval = defaultEndpointFailbackInterval
+	}
+	return &endpointSelector{
+		uris:             uris,
+		unhealthyUntil:   make([]time.Time, len(uris)),
+		failbackInterval: failbackInterval,
+	}
+}
+
+// Returns all of the endpoints in the order they should be tried: first the healthy ones in order of
+// preference, then the unhealthy ones in order of preference.
+func (s *endpointSelector) candidates(now time.Time) []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := make([]string, 0, len(s.uris))
+	for i, uri := range s.uris {
+		if !now.Before(s.unhealthyUntil[i]) {
+			ret = append(ret, uri)
+		}
+	}
+	for i, uri := range s.uris {
+		if now.Before(s.unhealthyUntil[i]) {
+			ret = append(ret, uri)
+		}
+	}
+	return ret
+}
+
+// Returns the endpoint that should be tried next.
+func (s *endpointSelector) first(now time.Time) string {
+	return s.candidates(now)[0]
+}
+
+func (s *endpointSelector) markFailed(uri string, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if i := s.indexOf(uri); i >= 0 {
+		s.unhealthyUntil[i] = now.Add(s.failbackInterval)
+	}
+}
+
+func (s *endpointSelector) markSucceeded(uri string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if i := s.indexOf(uri); i >= 0 {
+		s.unhealthyUntil[i] = time.Time{}
+	}
+}
+
+// Returns the time when an endpoint that is preferred over uri should be tried again, and false if
+// there is no such endpoint.
+func (s *endpointSelector) nextFailbackTime(uri string) (time.Time, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var ret time.Time
+	found := false
+	for i := 0; i < s.indexOf(uri); i++ {
+		if !found || s.unhealthyUntil[i].Before(ret) {
+			ret = s.unhealthyUntil[i]
+			found = true
+		}
+	}
+	return ret, found
+}
+
+func (s *endpointSelector) indexOf(uri string) int {
+	for i, u := range s.uris {
+		if u == uri {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns true if an error from a request to an endpoint means that we should try another endpoint. This
+// is not the case for errors such as an invalid SDK key, which would fail the same way anywhere.
+func isEndpointFailure(err error) bool {
+	if hse, ok := err.(HttpStatusError); ok {
+		return isHTTPErrorRecoverable(hse.Code)
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package ldclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEndpointSelectorPrefersFirstHealthyEndpoint(t *testing.T) {
+	now := time.Now()
+	s := newEndpointSelector([]string{"a", "b", "c"}, time.Minute)
+	assert.Equal(t, []string{"a", "b", "c"}, s.candidates(now))
+
+	s.markFailed("a", now)
+	assert.Equal(t, []string{"b", "c", "a"}, s.candidates(now))
+	assert.Equal(t, "b", s.first(now))
+
+	s.markFailed("b", now)
+	assert.Equal(t, "c", s.first(now))
+}
+
+func TestEndpointSelectorRetriesFailedEndpointAfterFailbackInterval(t *testing.T) {
+	now := time.Now()
+	s := newEndpointSelector([]string{"a", "b"}, time.Minute)
+	s.markFailed("a", now)
+	assert.Equal(t, "b", s.first(now.Add(59*time.Second)))
+	assert.Equal(t, "a", s.first(now.Add(time.Minute)))
+}
+
+func TestEndpointSelectorMarkSucceededRestoresEndpoint(t *testing.T) {
+	now := time.Now()
+	s := newEndpointSelector([]string{"a", "b"}, time.Minute)
+	s.markFailed("a", now)
+	s.markSucceeded("a")
+	assert.Equal(t, "a", s.first(now))
+}
+
+func TestEndpointSelectorUsesDefaultFailbackInterval(t *testing.T) {
+	s := newEndpointSelector([]string{"a"}, 0)
+	assert.Equal(t, defaultEndpointFailbackInterval, s.failbackInterval)
+}
+
+func TestEndpointSelectorNextFailbackTime(t *testing.T) {
+	now := time.Now()
+	s := newEndpointSelector([]string{"a", "b", "c"}, time.Minute)
+
+	_, ok := s.nextFailbackTime("a")
+	assert.False(t, ok) // nothing is preferred over the first endpoint
+
+	s.markFailed("a", now)
+	s.markFailed("b", now.Add(time.Second))
+	failbackTime, ok := s.nextFailbackTime("c")
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(time.Minute), failbackTime)
+}
+
+func TestIsEndpointFailure(t *testing.T) {
+	assert.True(t, isEndpointFailure(errors.New("connection refused")))
+	assert.True(t, isEndpointFailure(HttpStatusError{Code: 503}))
+	assert.True(t, isEndpointFailure(HttpStatusError{Code: 429}))
+	assert.False(t, isEndpointFailure(HttpStatusError{Code: 401}))
+	assert.False(t, isEndpointFailure(HttpStatusError{Code: 404}))
+}
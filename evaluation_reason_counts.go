@@ -0,0 +1,81 @@
+package ldclient
+
+import (
+	"sync"
+)
+
+// EvaluationReasonCount is the number of flag evaluations that produced a particular variation of a flag
+// for a particular reason. See LDClient.GetEvaluationReasonCounts.
+type EvaluationReasonCount struct {
+	// FlagKey is the key of the flag that was evaluated.
+	FlagKey string
+	// Variation is the index of the variation that was selected, or nil if the default value was returned.
+	Variation *int
+	// ReasonKind describes the general category of the reason, such as EvalReasonRuleMatch.
+	ReasonKind EvalReasonKind
+	// RuleID is the unique identifier of the rule that was matched, if ReasonKind is EvalReasonRuleMatch.
+	RuleID string
+	// PrerequisiteKey is the key of the prerequisite that failed, if ReasonKind is EvalReasonPrerequisiteFailed.
+	PrerequisiteKey string
+	// ErrorKind describes the error, if ReasonKind is EvalReasonError.
+	ErrorKind EvalErrorKind
+	// Count is the number of evaluations.
+	Count int
+}
+
+// Optional interface implemented by EventProcessors that support LDClient.GetEvaluationReasonCounts.
+type evaluationReasonCounter interface {
+	getEvaluationReasonCounts() []EvaluationReasonCount
+}
+
+type evaluationReasonCountKey struct {
+	flagKey   string
+	variation int
+	reason    flagCounterReason
+}
+
+// Cumulative evaluation counts, updated by the event processor's main goroutine and read by the application.
+// Unlike the summarizer's counters, these are never reset.
+type evaluationReasonCounts struct {
+	counts map[evaluationReasonCountKey]int
+	lock   sync.Mutex
+}
+
+func newEvaluationReasonCounts() *evaluationReasonCounts {
+	return &evaluationReasonCounts{counts: make(map[evaluationReasonCountKey]int)}
+}
+
+func (c *evaluationReasonCounts) count(fe FeatureRequestEvent) {
+	if fe.summaryReason == nil {
+		return
+	}
+	key := evaluationReasonCountKey{flagKey: fe.Key, variation: nilVariation, reason: makeFlagCounterReason(fe.summaryReason)}
+	if fe.Variation != nil {
+		key.variation = *fe.Variation
+	}
+	c.lock.Lock()
+	c.counts[key]++
+	c.lock.Unlock()
+}
+
+func (c *evaluationReasonCounts) snapshot() []EvaluationReasonCount {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := make([]EvaluationReasonCount, 0, len(c.counts))
+	for key, count := range c.counts {
+		rc := EvaluationReasonCount{
+			FlagKey:         key.flagKey,
+			ReasonKind:      key.reason.Kind,
+			RuleID:          key.reason.RuleID,
+			PrerequisiteKey: key.reason.PrerequisiteKey,
+			ErrorKind:       key.reason.ErrorKind,
+			Count:           count,
+		}
+		if key.variation != nilVariation {
+			v := key.variation
+			rc.Variation = &v
+		}
+		ret = append(ret, rc)
+	}
+	return ret
+}
@@ -0,0 +1,577 @@
+package ldclient
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Serializes analytics event payloads without reflection, writing directly into a reusable buffer. The
+// output is byte-for-byte the same as json.Marshal would produce for the output event types in
+// events_output.go; anything that this encoder does not handle itself, such as a custom attribute of an
+// unusual type or a string that needs escaping, is delegated to json.Marshal for that value only.
+type eventPayloadEncoder struct {
+	buf  []byte
+	keys []string // scratch space for sorting map keys, used as a stack for nested maps
+	err  error
+}
+
+var eventPayloadEncoderPool = sync.Pool{
+	New: func() interface{} { return &eventPayloadEncoder{} },
+}
+
+// Serializes a list of output events, as returned by eventOutputFormatter.makeOutputEvents. Like
+// json.Marshal, this builds the output in a pooled buffer and then returns a copy of it, since the HTTP
+// client may still be reading the request body after the request has completed.
+func encodeEventPayload(outputEvents []interface{}) ([]byte, error) {
+	e := eventPayloadEncoderPool.Get().(*eventPayloadEncoder)
+	defer func() {
+		e.buf = e.buf[:0]
+		e.keys = e.keys[:0]
+		e.err = nil
+		eventPayloadEncoderPool.Put(e)
+	}()
+	e.buf = append(e.buf[:0], '[')
+	for i, oe := range outputEvents {
+		if i > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		e.writeOutputEvent(oe)
+	}
+	e.buf = append(e.buf, ']')
+	if e.err != nil {
+		return nil, e.err
+	}
+	ret := make([]byte, len(e.buf))
+	copy(ret, e.buf)
+	return ret, nil
+}
+
+func (e *eventPayloadEncoder) writeOutputEvent(outputEvent interface{}) {
+	switch oe := outputEvent.(type) {
+	case featureRequestEventOutput:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", oe.Kind)
+		e.writeUint64Field("creationDate", oe.CreationDate)
+		e.writeStringField("key", oe.Key)
+		if oe.UserKey != nil {
+			e.writeStringField("userKey", *oe.UserKey)
+		}
+		if oe.User != nil {
+			e.writeFieldName("user")
+			e.writeUser(oe.User)
+		}
+		if oe.Variation != nil {
+			e.writeIntField("variation", *oe.Variation)
+		}
+		e.writeFieldName("value")
+		e.writeValue(oe.Value)
+		e.writeFieldName("default")
+		e.writeValue(oe.Default)
+		if oe.Version != nil {
+			e.writeIntField("version", *oe.Version)
+		}
+		if oe.PrereqOf != nil {
+			e.writeStringField("prereqOf", *oe.PrereqOf)
+		}
+		if oe.Reason != nil {
+			e.writeFieldName("reason")
+			e.writeReason(oe.Reason)
+		}
+		if oe.ContextKind != "" {
+			e.writeStringField("contextKind", oe.ContextKind)
+		}
+		e.buf = append(e.buf, '}')
+	case customEventOutput:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", oe.Kind)
+		e.writeUint64Field("creationDate", oe.CreationDate)
+		e.writeStringField("key", oe.Key)
+		if oe.UserKey != nil {
+			e.writeStringField("userKey", *oe.UserKey)
+		}
+		if oe.User != nil {
+			e.writeFieldName("user")
+			e.writeUser(oe.User)
+		}
+		if oe.Data != nil {
+			e.writeFieldName("data")
+			e.writeValue(oe.Data)
+		}
+		if oe.MetricValue != nil {
+			e.writeFieldName("metricValue")
+			e.writeFloat(*oe.MetricValue)
+		}
+		if oe.ContextKind != "" {
+			e.writeStringField("contextKind", oe.ContextKind)
+		}
+		e.buf = append(e.buf, '}')
+	case identifyEventOutput:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", oe.Kind)
+		e.writeUint64Field("creationDate", oe.CreationDate)
+		e.writeFieldName("key")
+		if oe.Key == nil {
+			e.buf = append(e.buf, "null"...)
+		} else {
+			e.writeString(*oe.Key)
+		}
+		e.writeFieldName("user")
+		e.writeUser(oe.User)
+		e.buf = append(e.buf, '}')
+	case indexEventOutput:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", oe.Kind)
+		e.writeUint64Field("creationDate", oe.CreationDate)
+		e.writeFieldName("user")
+		e.writeUser(oe.User)
+		e.buf = append(e.buf, '}')
+	case aliasEventOutput:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", oe.Kind)
+		e.writeUint64Field("creationDate", oe.CreationDate)
+		e.writeStringField("key", oe.Key)
+		e.writeStringField("contextKind", oe.ContextKind)
+		e.writeStringField("previousKey", oe.PreviousKey)
+		e.writeStringField("previousContextKind", oe.PreviousContextKind)
+		e.buf = append(e.buf, '}')
+	case metricSummaryEventOutput:
+		e.writeMetricSummaryEvent(oe)
+	case summaryEventOutput:
+		e.writeSummaryEvent(oe)
+	default:
+		e.writeFallback(outputEvent)
+	}
+}
+
+func (e *eventPayloadEncoder) writeMetricSummaryEvent(me metricSummaryEventOutput) {
+	e.buf = append(e.buf, '{')
+	e.writeStringField("kind", me.Kind)
+	e.writeUint64Field("startDate", me.StartDate)
+	e.writeUint64Field("endDate", me.EndDate)
+	e.writeStringField("key", me.Key)
+	if me.UserKey != nil {
+		e.writeStringField("userKey", *me.UserKey)
+	}
+	if me.User != nil {
+		e.writeFieldName("user")
+		e.writeUser(me.User)
+	}
+	if me.ContextKind != "" {
+		e.writeStringField("contextKind", me.ContextKind)
+	}
+	e.writeIntField("count", me.Count)
+	e.writeFieldName("sum")
+	e.writeFloat(me.Sum)
+	e.writeFieldName("min")
+	e.writeFloat(me.Min)
+	e.writeFieldName("max")
+	e.writeFloat(me.Max)
+	if me.Histogram != nil {
+		e.writeFieldName("histogram")
+		e.buf = append(e.buf, '{')
+		e.writeFieldName("bounds")
+		if me.Histogram.Bounds == nil {
+			e.buf = append(e.buf, "null"...)
+		} else {
+			e.buf = append(e.buf, '[')
+			for i, b := range me.Histogram.Bounds {
+				if i > 0 {
+					e.buf = append(e.buf, ',')
+				}
+				e.writeFloat(b)
+			}
+			e.buf = append(e.buf, ']')
+		}
+		e.writeFieldName("counts")
+		if me.Histogram.Counts == nil {
+			e.buf = append(e.buf, "null"...)
+		} else {
+			e.buf = append(e.buf, '[')
+			for i, c := range me.Histogram.Counts {
+				if i > 0 {
+					e.buf = append(e.buf, ',')
+				}
+				e.buf = strconv.AppendInt(e.buf, int64(c), 10)
+			}
+			e.buf = append(e.buf, ']')
+		}
+		e.buf = append(e.buf, '}')
+	}
+	e.buf = append(e.buf, '}')
+}
+
+func (e *eventPayloadEncoder) writeSummaryEvent(se summaryEventOutput) {
+	e.buf = append(e.buf, '{')
+	e.writeStringField("kind", se.Kind)
+	e.writeUint64Field("startDate", se.StartDate)
+	e.writeUint64Field("endDate", se.EndDate)
+	e.writeFieldName("features")
+	if se.Features == nil {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		keys := make([]string, 0, len(se.Features))
+		for k := range se.Features {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys) // json.Marshal always sorts map keys
+		e.buf = append(e.buf, '{')
+		for _, k := range keys {
+			e.writeFieldName(k)
+			e.writeFlagSummary(se.Features[k])
+		}
+		e.buf = append(e.buf, '}')
+	}
+	e.buf = append(e.buf, '}')
+}
+
+func (e *eventPayloadEncoder) writeFlagSummary(fs flagSummaryData) {
+	e.buf = append(e.buf, '{')
+	e.writeFieldName("default")
+	e.writeValue(fs.Default)
+	e.writeFieldName("counters")
+	if fs.Counters == nil {
+		e.buf = append(e.buf, "null"...)
+	} else {
+		e.buf = append(e.buf, '[')
+		for i, c := range fs.Counters {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.buf = append(e.buf, '{')
+			e.writeFieldName("value")
+			e.writeValue(c.Value)
+			if c.Variation != nil {
+				e.writeIntField("variation", *c.Variation)
+			}
+			if c.Version != nil {
+				e.writeIntField("version", *c.Version)
+			}
+			e.writeIntField("count", c.Count)
+			if c.Unknown != nil {
+				e.writeFieldName("unknown")
+				e.writeBool(*c.Unknown)
+			}
+			if c.Reason != nil {
+				e.writeFieldName("reason")
+				e.buf = append(e.buf, '{')
+				e.writeStringField("kind", string(c.Reason.Kind))
+				if c.Reason.RuleID != "" {
+					e.writeStringField("ruleId", c.Reason.RuleID)
+				}
+				if c.Reason.PrerequisiteKey != "" {
+					e.writeStringField("prerequisiteKey", c.Reason.PrerequisiteKey)
+				}
+				if c.Reason.ErrorKind != "" {
+					e.writeStringField("errorKind", string(c.Reason.ErrorKind))
+				}
+				e.buf = append(e.buf, '}')
+			}
+			e.buf = append(e.buf, '}')
+		}
+		e.buf = append(e.buf, ']')
+	}
+	e.buf = append(e.buf, '}')
+}
+
+//nolint:megacheck // allow deprecated usage of the concrete reason types
+func (e *eventPayloadEncoder) writeReason(reason EvaluationReason) {
+	switch r := reason.(type) {
+	case EvaluationReasonOff:
+		e.writeReasonKindOnly(r.Kind)
+	case EvaluationReasonFallthrough:
+		e.writeReasonKindOnly(r.Kind)
+	case EvaluationReasonTargetMatch:
+		e.writeReasonKindOnly(r.Kind)
+	case EvaluationReasonRuleMatch:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", string(r.Kind))
+		e.writeIntField("ruleIndex", r.RuleIndex)
+		e.writeStringField("ruleId", r.RuleID)
+		e.buf = append(e.buf, '}')
+	case EvaluationReasonPrerequisiteFailed:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", string(r.Kind))
+		e.writeStringField("prerequisiteKey", r.PrerequisiteKey)
+		e.buf = append(e.buf, '}')
+	case EvaluationReasonError:
+		e.buf = append(e.buf, '{')
+		e.writeStringField("kind", string(r.Kind))
+		e.writeStringField("errorKind", string(r.ErrorKind))
+		e.buf = append(e.buf, '}')
+	default:
+		e.writeFallback(reason)
+	}
+}
+
+func (e *eventPayloadEncoder) writeReasonKindOnly(kind EvalReasonKind) {
+	e.buf = append(e.buf, '{')
+	e.writeStringField("kind", string(kind))
+	e.buf = append(e.buf, '}')
+}
+
+// Writes a user in the same way as serializableUser.MarshalJSON, including its handling of errors in
+// custom attributes: if a custom attribute cannot be serialized, or the custom attributes map is being
+// modified concurrently, the user is written without any custom attributes.
+func (e *eventPayloadEncoder) writeUser(u *serializableUser) {
+	if u == nil {
+		e.buf = append(e.buf, "null"...)
+		return
+	}
+	start, keysStart := len(e.buf), len(e.keys)
+	prevErr := e.err
+	var problem interface{}
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				problem = r
+			}
+		}()
+		e.writeUserFields(u.User)
+	}()
+	if problem == nil && e.err != prevErr {
+		problem = e.err
+	}
+	if problem != nil {
+		u.filter.loggers.Errorf(userSerializationErrorMessage,
+			describeUserForErrorLog(&u.User, u.filter.logUserKeyInErrors), problem)
+		e.buf, e.keys = e.buf[:start], e.keys[:keysStart]
+		e.err = prevErr
+		userWithoutCustom := u.User
+		userWithoutCustom.Custom = nil
+		e.writeUserFields(userWithoutCustom)
+	}
+}
+
+func (e *eventPayloadEncoder) writeUserFields(u User) {
+	e.buf = append(e.buf, '{')
+	for _, attr := range []struct {
+		name  string
+		value *string
+	}{
+		{"key", u.Key},
+		{"secondary", u.Secondary},
+		{"ip", u.Ip},
+		{"country", u.Country},
+		{"email", u.Email},
+		{"firstName", u.FirstName},
+		{"lastName", u.LastName},
+		{"avatar", u.Avatar},
+		{"name", u.Name},
+	} {
+		if attr.value != nil {
+			e.writeStringField(attr.name, *attr.value)
+		}
+	}
+	if u.Anonymous != nil {
+		e.writeFieldName("anonymous")
+		e.writeBool(*u.Anonymous)
+	}
+	if u.Custom != nil {
+		e.writeFieldName("custom")
+		e.writeValue(*u.Custom)
+	}
+	if len(u.Derived) > 0 {
+		e.writeFieldName("derived")
+		e.writeFallback(u.Derived)
+	}
+	if len(u.PrivateAttributes) > 0 {
+		e.writeFieldName("privateAttrs")
+		e.buf = append(e.buf, '[')
+		for i, a := range u.PrivateAttributes {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.writeString(a)
+		}
+		e.buf = append(e.buf, ']')
+	}
+	e.buf = append(e.buf, '}')
+}
+
+// Writes an arbitrary value. The types that can appear in flag values and in custom attributes that
+// were set with UserBuilder are handled directly; anything else is delegated to json.Marshal.
+func (e *eventPayloadEncoder) writeValue(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		e.buf = append(e.buf, "null"...)
+	case bool:
+		e.writeBool(v)
+	case string:
+		e.writeString(v)
+	case float64:
+		e.writeFloat(v)
+	case int:
+		e.buf = strconv.AppendInt(e.buf, int64(v), 10)
+	case []interface{}:
+		if v == nil {
+			e.buf = append(e.buf, "null"...)
+			return
+		}
+		e.buf = append(e.buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			e.writeValue(item)
+		}
+		e.buf = append(e.buf, ']')
+	case map[string]interface{}:
+		if v == nil {
+			e.buf = append(e.buf, "null"...)
+			return
+		}
+		start := len(e.keys)
+		for k := range v {
+			e.keys = append(e.keys, k)
+		}
+		sort.Strings(e.keys[start:])
+		e.buf = append(e.buf, '{')
+		for i := start; i < start+len(v); i++ {
+			k := e.keys[i] // a nested map may reallocate e.keys, so we don't keep a reference to the slice
+			e.writeFieldName(k)
+			e.writeValue(v[k])
+		}
+		e.buf = append(e.buf, '}')
+		e.keys = e.keys[:start]
+	default:
+		e.writeFallback(value)
+	}
+}
+
+func (e *eventPayloadEncoder) writeFallback(value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		return
+	}
+	e.buf = append(e.buf, data...)
+}
+
+// Writes the name of an object property, preceded by a comma unless it is the first property.
+func (e *eventPayloadEncoder) writeFieldName(name string) {
+	if e.buf[len(e.buf)-1] != '{' {
+		e.buf = append(e.buf, ',')
+	}
+	e.writeString(name)
+	e.buf = append(e.buf, ':')
+}
+
+func (e *eventPayloadEncoder) writeStringField(name string, value string) {
+	e.writeFieldName(name)
+	e.writeString(value)
+}
+
+func (e *eventPayloadEncoder) writeIntField(name string, value int) {
+	e.writeFieldName(name)
+	e.buf = strconv.AppendInt(e.buf, int64(value), 10)
+}
+
+func (e *eventPayloadEncoder) writeUint64Field(name string, value uint64) {
+	e.writeFieldName(name)
+	e.buf = strconv.AppendUint(e.buf, value, 10)
+}
+
+func (e *eventPayloadEncoder) writeBool(value bool) {
+	e.buf = strconv.AppendBool(e.buf, value)
+}
+
+// Writes a string. Strings that contain only printable ASCII characters that json.Marshal would not
+// escape are written directly; any others are delegated to json.Marshal, so that control characters,
+// HTML-sensitive characters, and invalid UTF-8 are escaped in exactly the same way.
+func (e *eventPayloadEncoder) writeString(s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			e.writeFallback(s)
+			return
+		}
+	}
+	e.buf = append(e.buf, '"')
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, '"')
+}
+
+// Writes a number in the same format as json.Marshal: like strconv.FormatFloat with the 'f' format,
+// except that very large or small magnitudes use the 'e' format with a minimal exponent.
+func (e *eventPayloadEncoder) writeFloat(f float64) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		e.writeFallback(f) // produces the same error as json.Marshal
+		return
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	e.buf = strconv.AppendFloat(e.buf, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(e.buf)
+		if n >= 4 && e.buf[n-4] == 'e' && e.buf[n-3] == '-' && e.buf[n-2] == '0' {
+			e.buf[n-2] = e.buf[n-1]
+			e.buf = e.buf[:n-1]
+		}
+	}
+}
+
+// The approximate size of the parts of an output event that don't depend on its content: the kind,
+// the creation date, property names, and punctuation.
+const estimatedEventOverhead = 80
+
+// Returns a rough estimate of the serialized size of an event, without serializing it. This is used
+// for Config.FlushAfterBytes, so it only needs to be cheap and roughly proportional to the real size.
+func estimateEventSize(evt Event, inlineUsers bool) int {
+	size := estimatedEventOverhead
+	inlineUser := true
+	switch e := evt.(type) {
+	case FeatureRequestEvent:
+		size += len(e.Key) + estimateValueSize(e.Value) + estimateValueSize(e.Default)
+		inlineUser = inlineUsers || e.Debug
+	case CustomEvent:
+		size += len(e.Key) + estimateValueSize(e.Data)
+		inlineUser = inlineUsers
+	case AliasEvent:
+		return size + len(e.Key) + len(e.PreviousKey) + len(e.ContextKind) + len(e.PreviousContextKind)
+	}
+	user := evt.GetBase().User
+	if !inlineUser {
+		return size + len(user.GetKey())
+	}
+	for _, attr := range []*string{user.Key, user.Secondary, user.Ip, user.Country, user.Email,
+		user.FirstName, user.LastName, user.Avatar, user.Name} {
+		if attr != nil {
+			size += len(*attr) + 16
+		}
+	}
+	if user.Custom != nil {
+		size += estimateValueSize(*user.Custom)
+	}
+	return size
+}
+
+func estimateValueSize(value interface{}) int {
+	switch v := value.(type) {
+	case nil, bool:
+		return 5
+	case string:
+		return len(v) + 2
+	case []interface{}:
+		size := 2
+		for _, item := range v {
+			size += estimateValueSize(item) + 1
+		}
+		return size
+	case map[string]interface{}:
+		size := 2
+		for k, item := range v {
+			size += len(k) + 4 + estimateValueSize(item)
+		}
+		return size
+	default:
+		return 16 // numbers, and any other type that we don't want to inspect
+	}
+}
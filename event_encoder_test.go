@@ -0,0 +1,249 @@
+package ldclient
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/launchdarkly/go-sdk-common.v1/ldvalue"
+)
+
+func assertEncodedLikeMarshal(t *testing.T, outputEvents []interface{}) {
+	expected, err := json.Marshal(outputEvents)
+	require.NoError(t, err)
+	actual, err := encodeEventPayload(outputEvents)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(actual))
+}
+
+func makeEncoderTestFormatter(modConfig func(*Config)) eventOutputFormatter {
+	config := epDefaultConfig
+	if modConfig != nil {
+		modConfig(&config)
+	}
+	return eventOutputFormatter{userFilter: newUserFilter(config), inlineUsers: config.InlineUsersInEvents, config: config}
+}
+
+func TestEncoderMatchesMarshalForFeatureEvents(t *testing.T) {
+	ef := makeEncoderTestFormatter(nil)
+	flag := FeatureFlag{Key: "flagkey", Version: 11}
+	prereqOf := "parent"
+	reasons := []EvaluationReason{
+		nil,
+		evalReasonOffInstance,
+		evalReasonFallthroughInstance,
+		evalReasonTargetMatchInstance,
+		newEvalReasonRuleMatch(1, "rule-id"),
+		newEvalReasonPrerequisiteFailed("prereq"),
+		newEvalReasonError(EvalErrorFlagNotFound),
+	}
+	var events []Event
+	for _, r := range reasons {
+		fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(1), ldvalue.String("value"), ldvalue.Bool(true), r, true, &prereqOf)
+		events = append(events, fe)
+		de := fe
+		de.Debug = true
+		events = append(events, de)
+	}
+	anonUser := NewAnonymousUser("anon")
+	events = append(events, newUnknownFlagEvent("unknown", anonUser, ldvalue.Null(), nil, false))
+	assertEncodedLikeMarshal(t, ef.makeOutputEvents(events, eventSummary{}, nil))
+}
+
+func TestEncoderMatchesMarshalForValues(t *testing.T) {
+	ef := makeEncoderTestFormatter(nil)
+	values := []interface{}{
+		nil,
+		true,
+		0.0,
+		math.Copysign(0, -1),
+		123.456,
+		-1e21,
+		1e20,
+		1e-7,
+		0.000001,
+		float64(math.MaxInt64),
+		3,
+		int64(4),
+		float32(1.5),
+		"",
+		"plain",
+		"<a href=\"x\">&amp;</a>",
+		"tab\tnewline\nback\\slash\x01  ",
+		"café \U0001F600",
+		"invalid \xff utf-8",
+		[]interface{}{},
+		[]interface{}(nil),
+		[]interface{}{1.0, "a", nil, map[string]interface{}{"z": 1.0, "a": []interface{}{false}}},
+		map[string]interface{}{},
+		map[string]interface{}(nil),
+		map[string]interface{}{"b": "x", "a": "<y>", "é": 2.0},
+		[]string{"typed", "slice"},
+		struct {
+			Name string `json:"name"`
+		}{"struct"},
+	}
+	var events []Event
+	for _, v := range values {
+		events = append(events, NewCustomEvent("key<&>", epDefaultUser, v))
+		m := float64(len(events)) * 1.25
+		ce := newCustomEvent("metric", epDefaultUser, ldvalue.Null(), true, m)
+		events = append(events, ce)
+	}
+	assertEncodedLikeMarshal(t, ef.makeOutputEvents(events, eventSummary{}, nil))
+}
+
+func TestEncoderMatchesMarshalForUsers(t *testing.T) {
+	custom := map[string]interface{}{
+		"num":    1.5,
+		"str":    "<s>",
+		"obj":    map[string]interface{}{"nested": []interface{}{"x", 2.0}},
+		"int":    7,
+		"typed":  []int{1, 2},
+		"nilval": nil,
+	}
+	fullUser := NewUserBuilder("user<key>").
+		Secondary("s").IP("1.2.3.4").Country("us").Email("e@x").FirstName("f").LastName("l").
+		Avatar("a").Name("n").Anonymous(false).Build()
+	fullUser.Custom = &custom
+	var nilCustom map[string]interface{}
+	userWithNilCustom := NewUser("nilcustom")
+	userWithNilCustom.Custom = &nilCustom
+	userWithDerived := NewUser("derived")
+	userWithDerived.Derived = map[string]*DerivedAttribute{"attr": {Value: "v"}}
+
+	configs := map[string]func(*Config){
+		"public":  nil,
+		"private": func(c *Config) { c.PrivateAttributeNames = []string{"email", "num", "/obj/nested"} },
+		"hashed": func(c *Config) {
+			c.AllAttributesPrivate = true
+			c.PrivateAttributeHashKey = []byte("secret")
+			c.HashUserKeyInEvents = true
+		},
+	}
+	for name, modConfig := range configs {
+		t.Run(name, func(t *testing.T) {
+			ef := makeEncoderTestFormatter(modConfig)
+			var events []Event
+			for _, u := range []User{fullUser, userWithNilCustom, userWithDerived, {}} {
+				events = append(events, NewIdentifyEvent(u), IndexEvent{BaseEvent{CreationDate: 1, User: u}})
+			}
+			assertEncodedLikeMarshal(t, ef.makeOutputEvents(events, eventSummary{}, nil))
+		})
+	}
+}
+
+func TestEncoderDropsCustomAttributesThatCannotBeSerialized(t *testing.T) {
+	ef := makeEncoderTestFormatter(nil)
+	custom := map[string]interface{}{"bad": make(chan int)}
+	user := NewUser("userkey")
+	user.Custom = &custom
+
+	outputEvents := ef.makeOutputEvents([]Event{NewIdentifyEvent(user)}, eventSummary{}, nil)
+	assertEncodedLikeMarshal(t, outputEvents)
+	data, err := encodeEventPayload(outputEvents)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "custom")
+}
+
+func TestEncoderMatchesMarshalForAliasAndMetricSummaryEvents(t *testing.T) {
+	ef := makeEncoderTestFormatter(nil)
+	metrics := newMetricAggregator([]float64{1, 10})
+	metrics.add(makeMetricEvent("metric", epDefaultUser, 0.5, 1000))
+	metrics.add(makeMetricEvent("metric", epDefaultUser, 1e25, 2000))
+	noHistogram := newMetricAggregator(nil)
+	noHistogram.add(makeMetricEvent("other", NewAnonymousUser("anon"), -3, 1000))
+	aggs := append(metrics.snapshot(), noHistogram.snapshot()...)
+
+	events := []Event{NewAliasEvent(NewUser("new"), NewAnonymousUser("old"))}
+	assertEncodedLikeMarshal(t, ef.makeOutputEvents(events, eventSummary{}, aggs))
+
+	inlineEf := makeEncoderTestFormatter(func(c *Config) { c.InlineUsersInEvents = true })
+	assertEncodedLikeMarshal(t, inlineEf.makeOutputEvents(nil, eventSummary{}, aggs))
+}
+
+func TestEncoderMatchesMarshalForSummaryEvent(t *testing.T) {
+	ef := makeEncoderTestFormatter(nil)
+	es := newEventSummarizer()
+	flag1 := FeatureFlag{Key: "flag1", Version: 11}
+	flag2 := FeatureFlag{Key: "flag0", Version: 22}
+	for _, e := range []FeatureRequestEvent{
+		newSuccessfulEvalEvent(&flag1, epDefaultUser, intPtr(1), ldvalue.String("a"), ldvalue.String("d"), nil, false, nil),
+		newSuccessfulEvalEvent(&flag2, epDefaultUser, intPtr(2), ldvalue.Float64(2.5), ldvalue.Null(), nil, false, nil),
+		newUnknownFlagEvent("missing", epDefaultUser, ldvalue.String("<default>"), nil, false),
+	} {
+		es.summarizeEvent(e)
+	}
+	withReason := newSuccessfulEvalEvent(&flag1, epDefaultUser, intPtr(1), ldvalue.String("a"), ldvalue.String("d"), nil, false, nil)
+	withReason.summaryReason = newEvalReasonRuleMatch(0, "rule")
+	es.summarizeEvent(withReason)
+
+	outputEvents := ef.makeOutputEvents(nil, es.snapshot(), nil)
+	require.Len(t, outputEvents, 1)
+	// counters within a flag are in map iteration order, so compare against json.Marshal of the same output
+	assertEncodedLikeMarshal(t, outputEvents)
+}
+
+func TestEncoderReturnsErrorForUnsupportedNumber(t *testing.T) {
+	ef := makeEncoderTestFormatter(nil)
+	events := []Event{newCustomEvent("metric", epDefaultUser, ldvalue.Null(), true, math.NaN())}
+	outputEvents := ef.makeOutputEvents(events, eventSummary{}, nil)
+
+	_, expectedErr := json.Marshal(outputEvents)
+	require.Error(t, expectedErr)
+	_, err := encodeEventPayload(outputEvents)
+	assert.Equal(t, expectedErr, err)
+}
+
+func makeBenchmarkOutputEvents() []interface{} {
+	ef := makeEncoderTestFormatter(func(c *Config) { c.PrivateAttributeNames = []string{"email"} })
+	custom := map[string]interface{}{"plan": "enterprise", "seats": 25.0, "tags": []interface{}{"a", "b"}}
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true}
+	es := newEventSummarizer()
+	events := make([]Event, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		user := NewUserBuilder("user-key-" + string(rune('a'+i%26))).Email("someone@example.com").
+			Name("Some One").Build()
+		user.Custom = &custom
+		switch i % 4 {
+		case 0:
+			events = append(events, IndexEvent{BaseEvent{CreationDate: uint64(i), User: user}})
+		case 1:
+			fe := newSuccessfulEvalEvent(&flag, user, intPtr(1), ldvalue.String("value"), ldvalue.String("default"),
+				evalReasonFallthroughInstance, true, nil)
+			es.summarizeEvent(fe)
+			events = append(events, fe)
+		case 2:
+			fe := newSuccessfulEvalEvent(&flag, user, intPtr(1), ldvalue.Bool(true), ldvalue.Bool(false), nil, false, nil)
+			fe.Debug = true
+			events = append(events, fe)
+		default:
+			events = append(events, NewCustomEvent("event-key", user, map[string]interface{}{"amount": 12.5}))
+		}
+	}
+	return ef.makeOutputEvents(events, es.snapshot(), nil)
+}
+
+func BenchmarkEncodeEventPayload(b *testing.B) {
+	outputEvents := makeBenchmarkOutputEvents()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := encodeEventPayload(outputEvents); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalEventPayload(b *testing.B) {
+	outputEvents := makeBenchmarkOutputEvents()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(outputEvents); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ldclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrEventDeliveryDisabled is reported in an EventPayloadFailure if events could not be delivered because
+// LaunchDarkly rejected the SDK key, which permanently disables event delivery for the client.
+var ErrEventDeliveryDisabled = errors.New("event delivery is disabled because the SDK key was rejected")
+
+// EventFlushError is returned by LDClient.FlushAndWait if some of the events could not be delivered.
+type EventFlushError struct {
+	// Failures describes each payload of events that could not be delivered.
+	Failures []EventPayloadFailure
+}
+
+// EventPayloadFailure describes a payload of events that could not be delivered. See EventFlushError.
+type EventPayloadFailure struct {
+	// EventCount is the number of events in the payload.
+	EventCount int
+	// StatusCode is the HTTP status of the last delivery attempt, or zero if there was no response.
+	StatusCode int
+	// Err is the error that prevented delivery, if it was not an HTTP error status: for instance, a
+	// network error, or ErrEventDeliveryDisabled.
+	Err error
+}
+
+// Error returns a description of all of the failures.
+func (e EventFlushError) Error() string {
+	descriptions := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		descriptions = append(descriptions, f.String())
+	}
+	return fmt.Sprintf("failed to deliver %d event payload(s): %s", len(e.Failures), strings.Join(descriptions, "; "))
+}
+
+// String returns a description of the failure.
+func (f EventPayloadFailure) String() string {
+	if f.Err != nil {
+		return fmt.Sprintf("%d events: %s", f.EventCount, f.Err)
+	}
+	return fmt.Sprintf("%d events: HTTP error %d", f.EventCount, f.StatusCode)
+}
+
+// Optional interface implemented by EventProcessors that support LDClient.FlushAndWait.
+type eventFlusher interface {
+	flushAndWait(ctx context.Context) error
+}
+
+type flushAndWaitMessage struct {
+	replyCh chan flushAndWaitReply
+}
+
+type flushAndWaitReply struct {
+	// The payloads that were being delivered, including the one that was just started, if any.
+	payloads []*flushPayload
+	// Events that were discarded instead of being delivered.
+	failures []EventPayloadFailure
+	// False if there were events to flush but no flush worker was available.
+	complete bool
+}
+
+func (ep *defaultEventProcessor) flushAndWait(ctx context.Context) error {
+	var failures []EventPayloadFailure
+	for {
+		m := flushAndWaitMessage{replyCh: make(chan flushAndWaitReply, 1)}
+		var reply flushAndWaitReply
+		select {
+		case ep.inboxCh <- m:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ep.stoppedCh:
+			return nil // Close has already delivered all events
+		}
+		select {
+		case reply = <-m.replyCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ep.stoppedCh:
+			return nil
+		}
+		failures = append(failures, reply.failures...)
+		for _, p := range reply.payloads {
+			select {
+			case <-p.done:
+				if p.failure != nil {
+					failures = append(failures, *p.failure)
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if reply.complete {
+			break
+		}
+		// All of the flush workers were busy, so the events are still buffered. Now that the payloads we
+		// were waiting for have been delivered, a worker should be available.
+	}
+	if len(failures) > 0 {
+		return EventFlushError{Failures: failures}
+	}
+	return nil
+}
+
+// Starts a flush on behalf of flushAndWait. This is called from the event processor's main goroutine.
+func (ed *eventDispatcher) startFlushForWaiter(outbox *eventBuffer, flushCh chan<- *flushPayload,
+	workersGroup *sync.WaitGroup) flushAndWaitReply {
+	var reply flushAndWaitReply
+	// Payloads that were already delivered before this point are not of interest. We must not prune the
+	// list after starting the flush, or we could miss the result of the new payload.
+	ed.pruneInFlightPayloads()
+	if ed.isDisabled() {
+		if n := outbox.queueDepth(); n > 0 {
+			reply.failures = append(reply.failures, EventPayloadFailure{EventCount: n, Err: ErrEventDeliveryDisabled})
+		}
+	}
+	reply.complete = ed.triggerFlush(outbox, flushCh, workersGroup)
+	reply.payloads = make([]*flushPayload, len(ed.inFlightPayloads))
+	copy(reply.payloads, ed.inFlightPayloads)
+	return reply
+}
+
+// Removes payloads that have been delivered from the list of payloads in flight.
+func (ed *eventDispatcher) pruneInFlightPayloads() {
+	remaining := ed.inFlightPayloads[:0]
+	for _, p := range ed.inFlightPayloads {
+		select {
+		case <-p.done:
+		default:
+			remaining = append(remaining, p)
+		}
+	}
+	for i := len(remaining); i < len(ed.inFlightPayloads); i++ {
+		ed.inFlightPayloads[i] = nil
+	}
+	ed.inFlightPayloads = remaining
+}
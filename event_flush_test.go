@@ -0,0 +1,111 @@
+package ldclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlushAndWaitReturnsAfterEventsAreDelivered(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	err := ep.flushAndWait(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, getEventsFromRequest(st), 1)
+}
+
+func TestFlushAndWaitWithNoEventsReturnsImmediately(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+
+	assert.NoError(t, ep.flushAndWait(context.Background()))
+	assert.Nil(t, st.getNextRequest())
+}
+
+func TestFlushAndWaitReportsHTTPError(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+	st.statusCode = 403
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	err := ep.flushAndWait(context.Background())
+	require.Error(t, err)
+	var flushErr EventFlushError
+	require.True(t, errors.As(err, &flushErr))
+	assert.Equal(t, []EventPayloadFailure{{EventCount: 1, StatusCode: 403}}, flushErr.Failures)
+
+	// event delivery is now disabled, so subsequent events are discarded
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	err = ep.flushAndWait(context.Background())
+	require.True(t, errors.As(err, &flushErr))
+	assert.Equal(t, []EventPayloadFailure{{EventCount: 1, Err: ErrEventDeliveryDisabled}}, flushErr.Failures)
+}
+
+func TestFlushAndWaitReportsNetworkError(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+	st.error = errors.New("sorry")
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	err := ep.flushAndWait(context.Background())
+	var flushErr EventFlushError
+	require.True(t, errors.As(err, &flushErr))
+	require.Len(t, flushErr.Failures, 1)
+	assert.Equal(t, 1, flushErr.Failures[0].EventCount)
+	assert.Equal(t, 0, flushErr.Failures[0].StatusCode)
+	assert.Error(t, flushErr.Failures[0].Err)
+}
+
+type blockingTransport struct {
+	releaseCh chan struct{}
+}
+
+func (t blockingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	<-t.releaseCh
+	return &http.Response{StatusCode: 202, Header: make(http.Header), Request: request}, nil
+}
+
+func TestFlushAndWaitReturnsContextErrorIfContextExpires(t *testing.T) {
+	transport := blockingTransport{releaseCh: make(chan struct{})}
+	ep := NewDefaultEventProcessor(sdkKey, epDefaultConfig, &http.Client{Transport: transport}).(*defaultEventProcessor)
+	defer ep.Close()
+	defer close(transport.releaseCh)
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := ep.flushAndWait(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestFlushAndWaitAfterCloseReturnsImmediately(t *testing.T) {
+	ep, _ := createEventProcessor(epDefaultConfig)
+	ep.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, ep.flushAndWait(ctx))
+}
+
+func TestClientFlushAndWaitWithCustomEventProcessorReturnsNil(t *testing.T) {
+	client := makeTestClient()
+	defer client.Close()
+
+	assert.NoError(t, client.FlushAndWait(context.Background()))
+}
+
+func TestEventFlushErrorDescribesFailures(t *testing.T) {
+	err := EventFlushError{Failures: []EventPayloadFailure{
+		{EventCount: 3, StatusCode: 503},
+		{EventCount: 2, Err: ErrEventDeliveryDisabled},
+	}}
+	assert.Equal(t, "failed to deliver 2 event payload(s): 3 events: HTTP error 503; "+
+		"2 events: event delivery is disabled because the SDK key was rejected", err.Error())
+}
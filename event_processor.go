@@ -32,6 +32,10 @@ type nullEventProcessor struct{}
 
 type defaultEventProcessor struct {
 	inboxCh       chan eventDispatcherMessage
+	dispatcher    *eventDispatcher
+	tap           *eventTap
+	stats         *eventStatsRecorder
+	stoppedCh     chan struct{} // closed when Close has finished
 	inboxFullOnce sync.Once
 	closeOnce     sync.Once
 	loggers       ldlog.Loggers
@@ -45,14 +49,23 @@ type eventDispatcher struct {
 	eventsInLastBatch int
 	disabled          bool
 	stateLock         sync.Mutex
+	reasonCounts      *evaluationReasonCounts // nil unless Config.SummarizeEvaluationReasons is enabled
+	debugUsers        debugUserMatcher
+	stats             *eventStatsRecorder
+	inFlightPayloads  []*flushPayload // only accessed from the main goroutine; see pruneInFlightPayloads
 }
 
 type eventBuffer struct {
 	events           []Event
 	summarizer       eventSummarizer
+	metrics          metricAggregator
 	capacity         int
 	capacityExceeded bool
 	droppedEvents    int
+	estimateSize     bool // true if Config.FlushAfterBytes is set
+	estimatedSize    int  // approximate serialized size of the events
+	inlineUsers      bool
+	stats            *eventStatsRecorder
 	loggers          ldlog.Loggers
 }
 
@@ -60,6 +73,9 @@ type flushPayload struct {
 	diagnosticEvent interface{}
 	events          []Event
 	summary         eventSummary
+	metrics         []*metricAggregate
+	done            chan struct{}        // for an events payload, closed by the flush worker when it is finished
+	failure         *EventPayloadFailure // set by the flush worker before closing done, if delivery failed
 }
 
 type sendEventsTask struct {
@@ -69,6 +85,8 @@ type sendEventsTask struct {
 	sdkKey        string
 	config        Config
 	formatter     eventOutputFormatter
+	tap           *eventTap
+	stats         *eventStatsRecorder
 }
 
 // Payload of the inboxCh channel.
@@ -117,18 +135,26 @@ func NewDefaultEventProcessor(sdkKey string, config Config, client *http.Client)
 		client = config.newHTTPClient()
 	}
 	inboxCh := make(chan eventDispatcherMessage, config.Capacity)
-	startEventDispatcher(sdkKey, config, client, inboxCh)
+	tap := newEventTap(config.Loggers)
+	stats := newEventStatsRecorder()
+	dispatcher := startEventDispatcher(sdkKey, config, client, inboxCh, tap, stats)
 	if config.SamplingInterval > 0 {
 		config.Loggers.Warn("Config.SamplingInterval is deprecated")
 	}
 	return &defaultEventProcessor{
-		inboxCh: inboxCh,
-		loggers: config.Loggers,
+		inboxCh:    inboxCh,
+		dispatcher: dispatcher,
+		tap:        tap,
+		stats:      stats,
+		stoppedCh:  make(chan struct{}),
+		loggers:    config.Loggers,
 	}
 }
 
 func (ep *defaultEventProcessor) SendEvent(e Event) {
-	ep.postNonBlockingMessageToInbox(sendEventMessage{event: e})
+	if !ep.postNonBlockingMessageToInbox(sendEventMessage{event: e}) {
+		ep.stats.recordDropped(EventDropInboxFull, 1)
+	}
 }
 
 func (ep *defaultEventProcessor) Flush() {
@@ -160,19 +186,49 @@ func (ep *defaultEventProcessor) Close() error {
 		m := shutdownEventsMessage{replyCh: make(chan struct{})}
 		ep.inboxCh <- m
 		<-m.replyCh
+		ep.tap.close()
+		close(ep.stoppedCh)
 	})
 	return nil
 }
 
+func (ep *defaultEventProcessor) subscribeEvents(filter EventFilter) EventSubscription {
+	return ep.tap.subscribe(filter)
+}
+
+func (ep *defaultEventProcessor) getEvaluationReasonCounts() []EvaluationReasonCount {
+	if ep.dispatcher.reasonCounts == nil {
+		return nil
+	}
+	return ep.dispatcher.reasonCounts.snapshot()
+}
+
+func (ep *defaultEventProcessor) getEventStats() EventStats {
+	stats := ep.stats.snapshot()
+	stats.InboxDepth = len(ep.inboxCh)
+	return stats
+}
+
 func startEventDispatcher(
 	sdkKey string,
 	config Config,
 	client *http.Client,
 	inboxCh <-chan eventDispatcherMessage,
-) {
+	tap *eventTap,
+	stats *eventStatsRecorder,
+) *eventDispatcher {
+	if config.DiagnosticOptOut {
+		// The client still gathers diagnostic data for LDClient.GetDiagnostics, but we must not send it.
+		config.diagnosticsManager = nil
+	}
 	ed := &eventDispatcher{
-		sdkKey: sdkKey,
-		config: config,
+		sdkKey:     sdkKey,
+		config:     config,
+		debugUsers: newDebugUserMatcher(config),
+		stats:      stats,
+	}
+	if config.SummarizeEvaluationReasons {
+		ed.reasonCounts = newEvaluationReasonCounts()
 	}
 
 	// Start a fixed-size pool of workers that wait on flushTriggerCh. This is the
@@ -180,7 +236,7 @@ func startEventDispatcher(
 	flushCh := make(chan *flushPayload, 1)
 	var workersGroup sync.WaitGroup
 	for i := 0; i < maxFlushWorkers; i++ {
-		startFlushTask(sdkKey, config, client, flushCh, &workersGroup,
+		startFlushTask(sdkKey, config, client, flushCh, tap, stats, &workersGroup,
 			func(r *http.Response) { ed.handleResponse(r) })
 	}
 	if config.diagnosticsManager != nil {
@@ -188,6 +244,7 @@ func startEventDispatcher(
 		ed.sendDiagnosticsEvent(event, client, flushCh, &workersGroup)
 	}
 	go ed.runMainLoop(inboxCh, flushCh, &workersGroup, client)
+	return ed
 }
 
 func (ed *eventDispatcher) runMainLoop(
@@ -201,12 +258,17 @@ func (ed *eventDispatcher) runMainLoop(
 	}
 
 	outbox := eventBuffer{
-		events:     make([]Event, 0, ed.config.Capacity),
-		summarizer: newEventSummarizer(),
-		capacity:   ed.config.Capacity,
-		loggers:    ed.config.Loggers,
+		events:       make([]Event, 0, ed.config.Capacity),
+		summarizer:   newEventSummarizer(),
+		metrics:      newMetricAggregator(ed.config.MetricHistogramBounds),
+		capacity:     ed.config.Capacity,
+		estimateSize: ed.config.FlushAfterBytes > 0,
+		inlineUsers:  ed.config.InlineUsersInEvents,
+		stats:        ed.stats,
+		loggers:      ed.config.Loggers,
 	}
 	userKeys := newLruCache(ed.config.UserKeysCapacity)
+	userLimiter := newUserEventLimiter(ed.config.UserEventsLimit, ed.config.UserKeysCapacity)
 
 	flushInterval := ed.config.FlushInterval
 	if flushInterval <= 0 {
@@ -219,6 +281,17 @@ func (ed *eventDispatcher) runMainLoop(
 	flushTicker := time.NewTicker(flushInterval)
 	usersResetTicker := time.NewTicker(userKeysFlushInterval)
 
+	var userLimiterTicker *time.Ticker
+	var userLimiterTickerCh <-chan time.Time
+	if ed.config.UserEventsLimit > 0 {
+		interval := ed.config.UserEventsLimitInterval
+		if interval <= 0 {
+			interval = DefaultConfig.UserEventsLimitInterval
+		}
+		userLimiterTicker = time.NewTicker(interval)
+		userLimiterTickerCh = userLimiterTicker.C
+	}
+
 	var diagnosticsTicker *time.Ticker
 	var diagnosticsTickerCh <-chan time.Time
 	diagnosticsManager := ed.config.diagnosticsManager
@@ -238,18 +311,27 @@ func (ed *eventDispatcher) runMainLoop(
 		case message := <-inboxCh:
 			switch m := message.(type) {
 			case sendEventMessage:
-				ed.processEvent(m.event, &outbox, &userKeys)
+				ed.processEvent(m.event, &outbox, &userKeys, &userLimiter)
+				ed.stats.setQueueDepth(outbox.queueDepth())
+				if ed.shouldFlushEarly(m.event, &outbox) {
+					ed.triggerFlush(&outbox, flushCh, workersGroup)
+				}
 			case flushEventsMessage:
 				ed.triggerFlush(&outbox, flushCh, workersGroup)
 			case syncEventsMessage:
 				workersGroup.Wait()
 				m.replyCh <- struct{}{}
+			case flushAndWaitMessage:
+				m.replyCh <- ed.startFlushForWaiter(&outbox, flushCh, workersGroup)
 			case shutdownEventsMessage:
 				flushTicker.Stop()
 				usersResetTicker.Stop()
 				if diagnosticsTicker != nil {
 					diagnosticsTicker.Stop()
 				}
+				if userLimiterTicker != nil {
+					userLimiterTicker.Stop()
+				}
 				workersGroup.Wait() // Wait for all in-progress flushes to complete
 				close(flushCh)      // Causes all idle flush workers to terminate
 				m.replyCh <- struct{}{}
@@ -259,6 +341,8 @@ func (ed *eventDispatcher) runMainLoop(
 			ed.triggerFlush(&outbox, flushCh, workersGroup)
 		case <-usersResetTicker.C:
 			userKeys.clear()
+		case <-userLimiterTickerCh:
+			userLimiter.clear()
 		case <-diagnosticsTickerCh:
 			if diagnosticsManager == nil || !diagnosticsManager.CanSendStatsEvent() {
 				break
@@ -276,7 +360,10 @@ func (ed *eventDispatcher) runMainLoop(
 	}
 }
 
-func (ed *eventDispatcher) processEvent(evt Event, outbox *eventBuffer, userKeys *lruCache) {
+func (ed *eventDispatcher) processEvent(evt Event, outbox *eventBuffer, userKeys *lruCache,
+	userLimiter *userEventLimiter) {
+
+	ed.stats.recordProcessed(evt)
 
 	// Always record the event in the summarizer.
 	outbox.addToSummary(evt)
@@ -286,14 +373,77 @@ func (ed *eventDispatcher) processEvent(evt Event, outbox *eventBuffer, userKeys
 	willAddFullEvent := false
 	var debugEvent Event
 	switch evt := evt.(type) {
+	case AliasEvent:
+		// An alias event refers to two users only by key, so it never needs an index event. We don't
+		// apply sampling to it, since dropping it would leave the two users' histories unmerged.
+		outbox.addEvent(evt)
+		return
 	case FeatureRequestEvent:
-		if ed.shouldSampleEvent() {
-			willAddFullEvent = evt.TrackEvents
-			if ed.shouldDebugEvent(&evt) {
-				de := evt
-				de.Debug = true
-				debugEvent = de
+		if ed.reasonCounts != nil {
+			ed.reasonCounts.count(evt)
+		}
+		sampling := ed.config.EventSampling
+		forcedDebug := ed.isDebugUser(&evt)
+		wantsDebug := forcedDebug || ed.shouldDebugEvent(&evt)
+		flagInterval := sampling.FlagKeyIntervals[evt.Key]
+		if flagInterval <= 0 && sampling.FeatureEventsInterval <= 0 && sampling.DebugEventsInterval <= 0 {
+			// Only the deprecated SamplingInterval applies. It has always been a single roll that decides
+			// both the full event and the debug event, so we keep it that way.
+			candidates := 0
+			if evt.TrackEvents {
+				candidates++
+			}
+			if wantsDebug && !forcedDebug {
+				candidates++
+			}
+			sampled := candidates == 0 || ed.isSampled()
+			if !sampled {
+				ed.stats.recordDropped(EventDropSampled, candidates)
+			}
+			willAddFullEvent = evt.TrackEvents && sampled
+			wantsDebug = wantsDebug && (forcedDebug || sampled)
+		} else {
+			willAddFullEvent = evt.TrackEvents && ed.shouldSampleEvent(flagInterval, sampling.FeatureEventsInterval)
+			wantsDebug = wantsDebug && (forcedDebug || ed.shouldSampleEvent(flagInterval, sampling.DebugEventsInterval))
+		}
+		if wantsDebug {
+			de := evt
+			de.Debug = true
+			if forcedDebug && de.Reason.Reason == nil {
+				de.Reason.Reason = evt.evalReason // see Config.DebugEventsUserKeys
 			}
+			debugEvent = de
+		}
+		// If the user is over the event limit, the evaluation is still counted in the summary. Debug
+		// events for a user who was selected for debugging are kept regardless.
+		if (willAddFullEvent || (debugEvent != nil && !forcedDebug)) && !userLimiter.allow(evt.User) {
+			if willAddFullEvent {
+				ed.stats.recordDropped(EventDropUserLimit, 1)
+			}
+			willAddFullEvent = false
+			if debugEvent != nil && !forcedDebug {
+				ed.stats.recordDropped(EventDropUserLimit, 1)
+				debugEvent = nil
+			}
+		}
+	case CustomEvent:
+		if evt.MetricValue != nil && ed.config.AggregateMetrics {
+			// Aggregated metrics are exact, so they are not subject to sampling or to the per-user limit.
+			outbox.addMetric(evt)
+			break
+		}
+		if evt.urgent {
+			willAddFullEvent = true // see LDClient.TrackDataUrgent
+			break
+		}
+		sampling := ed.config.EventSampling
+		willAddFullEvent = ed.shouldSampleEvent(sampling.CustomEventKeyIntervals[evt.Key], sampling.CustomEventsInterval)
+		if willAddFullEvent && !userLimiter.allow(evt.User) {
+			// Unlike evaluations, custom events have no summary counters, so this is reported as a dropped
+			// event in diagnostic events as well as in EventStats.
+			willAddFullEvent = false
+			ed.stats.recordDropped(EventDropUserLimit, 1)
+			outbox.droppedEvents++
 		}
 	default:
 		willAddFullEvent = ed.shouldSampleEvent()
@@ -306,6 +456,7 @@ func (ed *eventDispatcher) processEvent(evt Event, outbox *eventBuffer, userKeys
 		user := evt.GetBase().User
 		if noticeUser(userKeys, &user) {
 			ed.deduplicatedUsers++
+			ed.stats.recordDeduplicatedUser()
 		} else {
 			if _, ok := evt.(IdentifyEvent); !ok {
 				indexEvent := IndexEvent{
@@ -331,8 +482,34 @@ func noticeUser(userKeys *lruCache, user *User) bool {
 	return userKeys.add(*user.Key)
 }
 
-func (ed *eventDispatcher) shouldSampleEvent() bool {
-	return ed.config.SamplingInterval == 0 || rand.Int31n(ed.config.SamplingInterval) == 0
+// Decides whether to send an event, using the first of the given sampling intervals that is set, or
+// else the deprecated global SamplingInterval. More specific intervals should be passed first.
+func (ed *eventDispatcher) shouldSampleEvent(intervals ...int32) bool {
+	if ed.isSampled(intervals...) {
+		return true
+	}
+	ed.stats.recordDropped(EventDropSampled, 1)
+	return false
+}
+
+// Makes the sampling decision for shouldSampleEvent, without recording a dropped event.
+func (ed *eventDispatcher) isSampled(intervals ...int32) bool {
+	interval := ed.config.SamplingInterval
+	for _, i := range intervals {
+		if i > 0 {
+			interval = i
+			break
+		}
+	}
+	return interval <= 0 || rand.Int31n(interval) == 0
+}
+
+// Returns true if the event's user was selected for debug events, using the client's answer if it has one.
+func (ed *eventDispatcher) isDebugUser(evt *FeatureRequestEvent) bool {
+	if evt.debugUser != nil {
+		return *evt.debugUser
+	}
+	return ed.debugUsers.matches(evt.User)
 }
 
 func (ed *eventDispatcher) shouldDebugEvent(evt *FeatureRequestEvent) bool {
@@ -349,23 +526,40 @@ func (ed *eventDispatcher) shouldDebugEvent(evt *FeatureRequestEvent) bool {
 		*evt.DebugEventsUntilDate > now()
 }
 
-// Signal that we would like to do a flush as soon as possible.
+// Returns true if a flush should be started now, rather than at the next flush interval: because the
+// event was urgent, or because the buffered events have reached Config.FlushAfterEventCount or
+// Config.FlushAfterBytes.
+func (ed *eventDispatcher) shouldFlushEarly(evt Event, outbox *eventBuffer) bool {
+	if ce, ok := evt.(CustomEvent); ok && ce.urgent {
+		return true
+	}
+	if ed.config.FlushAfterEventCount > 0 && outbox.queueDepth() >= ed.config.FlushAfterEventCount {
+		return true
+	}
+	return ed.config.FlushAfterBytes > 0 && outbox.estimatedSize >= ed.config.FlushAfterBytes
+}
+
+// Signal that we would like to do a flush as soon as possible. Returns false if there were events to
+// flush but no flush worker was available, in which case the events remain in the buffer.
 func (ed *eventDispatcher) triggerFlush(outbox *eventBuffer, flushCh chan<- *flushPayload,
-	workersGroup *sync.WaitGroup) {
+	workersGroup *sync.WaitGroup) bool {
 	if ed.isDisabled() {
+		ed.stats.recordDropped(EventDropDisabled, outbox.queueDepth())
 		outbox.clear()
-		return
+		ed.stats.setQueueDepth(0)
+		return true
 	}
 	// Is there anything to flush?
 	payload := outbox.getPayload()
-	totalEventCount := len(payload.events)
-	if len(payload.summary.counters) > 0 {
+	totalEventCount := len(payload.events) + len(payload.metrics)
+	if payload.summary.hasData() {
 		totalEventCount++
 	}
 	if totalEventCount == 0 {
 		ed.eventsInLastBatch = 0
-		return
+		return true
 	}
+	payload.done = make(chan struct{})
 	workersGroup.Add(1) // Increment the count of active flushes
 	select {
 	case flushCh <- &payload:
@@ -374,10 +568,15 @@ func (ed *eventDispatcher) triggerFlush(outbox *eventBuffer, flushCh chan<- *flu
 		// cleared from the main goroutine.
 		ed.eventsInLastBatch = totalEventCount
 		outbox.clear()
+		ed.stats.setQueueDepth(0)
+		ed.pruneInFlightPayloads()
+		ed.inFlightPayloads = append(ed.inFlightPayloads, &payload)
+		return true
 	default:
 		// We can't start a flush right now because we're waiting for one of the workers
 		// to pick up the last one.  Do not reset the event outbox or summary state.
 		workersGroup.Done()
+		return false
 	}
 }
 
@@ -428,36 +627,64 @@ func (ed *eventDispatcher) sendDiagnosticsEvent(
 }
 
 func (b *eventBuffer) addEvent(event Event) {
-	if len(b.events) >= b.capacity {
+	if !b.checkCapacity() {
+		return
+	}
+	b.events = append(b.events, event)
+	if b.estimateSize {
+		b.estimatedSize += estimateEventSize(event, b.inlineUsers)
+	}
+}
+
+func (b *eventBuffer) addMetric(event CustomEvent) {
+	// Each metric aggregate will become an event, so a new one takes up room in the buffer.
+	if !b.metrics.hasAggregateFor(event) && !b.checkCapacity() {
+		return
+	}
+	b.metrics.add(event)
+}
+
+// Returns true if there is room for another output event; otherwise, counts the event as dropped.
+func (b *eventBuffer) checkCapacity() bool {
+	if b.queueDepth() >= b.capacity {
 		if !b.capacityExceeded {
 			b.capacityExceeded = true
 			b.loggers.Warn("Exceeded event queue capacity. Increase capacity to avoid dropping events.")
 		}
 		b.droppedEvents++
-		return
+		b.stats.recordDropped(EventDropCapacityExceeded, 1)
+		return false
 	}
 	b.capacityExceeded = false
-	b.events = append(b.events, event)
+	return true
 }
 
 func (b *eventBuffer) addToSummary(event Event) {
 	b.summarizer.summarizeEvent(event)
 }
 
+// Returns the number of output events in the buffer, not counting the summary event.
+func (b *eventBuffer) queueDepth() int {
+	return len(b.events) + len(b.metrics.aggregates)
+}
+
 func (b *eventBuffer) getPayload() flushPayload {
 	return flushPayload{
 		events:  b.events,
 		summary: b.summarizer.snapshot(),
+		metrics: b.metrics.snapshot(),
 	}
 }
 
 func (b *eventBuffer) clear() {
 	b.events = make([]Event, 0, b.capacity)
+	b.estimatedSize = 0
 	b.summarizer.reset()
+	b.metrics.reset()
 }
 
 func startFlushTask(sdkKey string, config Config, client *http.Client, flushCh <-chan *flushPayload,
-	workersGroup *sync.WaitGroup, responseFn func(*http.Response)) {
+	tap *eventTap, stats *eventStatsRecorder, workersGroup *sync.WaitGroup, responseFn func(*http.Response)) {
 	ef := eventOutputFormatter{
 		userFilter:  newUserFilter(config),
 		inlineUsers: config.InlineUsersInEvents,
@@ -474,6 +701,8 @@ func startFlushTask(sdkKey string, config Config, client *http.Client, flushCh <
 		sdkKey:        sdkKey,
 		config:        config,
 		formatter:     ef,
+		tap:           tap,
+		stats:         stats,
 	}
 	go t.run(flushCh, responseFn, workersGroup)
 }
@@ -487,26 +716,54 @@ func (t *sendEventsTask) run(flushCh <-chan *flushPayload, responseFn func(*http
 			break
 		}
 		if payload.diagnosticEvent != nil {
-			t.postEvents(t.diagnosticURI, payload.diagnosticEvent, "diagnostic event")
+			jsonPayload, marshalErr := json.Marshal(payload.diagnosticEvent)
+			if marshalErr != nil {
+				t.config.Loggers.Errorf("Unexpected error marshalling event json: %+v", marshalErr)
+			} else {
+				_, _ = t.postEvents(t.diagnosticURI, jsonPayload, "diagnostic event")
+			}
 		} else {
-			outputEvents := t.formatter.makeOutputEvents(payload.events, payload.summary)
+			outputEvents := t.formatter.makeOutputEvents(payload.events, payload.summary, payload.metrics)
 			if len(outputEvents) > 0 {
-				resp := t.postEvents(t.eventsURI, outputEvents, fmt.Sprintf("%d events", len(outputEvents)))
-				if resp != nil {
-					responseFn(resp)
-				}
+				t.tap.publish(outputEvents)
+				payload.failure = t.sendOutputEvents(outputEvents, responseFn)
 			}
 		}
+		if payload.done != nil {
+			close(payload.done)
+		}
 		workersGroup.Done() // Decrement the count of in-progress flushes
 	}
 }
 
-func (t *sendEventsTask) postEvents(uri string, outputData interface{}, description string) *http.Response {
-	jsonPayload, marshalErr := json.Marshal(outputData)
-	if marshalErr != nil {
-		t.config.Loggers.Errorf("Unexpected error marshalling event json: %+v", marshalErr)
+// Delivers a payload of events. Returns a description of the failure, if it could not be delivered.
+func (t *sendEventsTask) sendOutputEvents(outputEvents []interface{}, responseFn func(*http.Response)) *EventPayloadFailure {
+	startTime := time.Now()
+	var resp *http.Response
+	jsonPayload, err := encodeEventPayload(outputEvents)
+	if err != nil {
+		t.config.Loggers.Errorf("Unexpected error marshalling event json: %+v", err)
+	} else {
+		resp, err = t.postEvents(t.eventsURI, jsonPayload, fmt.Sprintf("%d events", len(outputEvents)))
+	}
+	statusCode := 0
+	if resp != nil {
+		statusCode = resp.StatusCode
+	}
+	success := statusCode >= 200 && statusCode < 300
+	t.stats.recordFlush(len(outputEvents), len(jsonPayload), statusCode, time.Since(startTime), success)
+	if resp != nil {
+		responseFn(resp)
+	}
+	if success {
 		return nil
 	}
+	return &EventPayloadFailure{EventCount: len(outputEvents), StatusCode: statusCode, Err: err}
+}
+
+// Posts the serialized data, retrying once if necessary. Returns the last response, if any, or else
+// the last error.
+func (t *sendEventsTask) postEvents(uri string, jsonPayload []byte, description string) (*http.Response, error) {
 	payloadUUID, _ := uuid.NewRandom()
 	payloadID := payloadUUID.String() // if NewRandom somehow failed, we'll just proceed with an empty string
 
@@ -522,7 +779,7 @@ func (t *sendEventsTask) postEvents(uri string, outputData interface{}, descript
 		req, reqErr := http.NewRequest("POST", uri, bytes.NewReader(jsonPayload))
 		if reqErr != nil {
 			t.config.Loggers.Errorf("Unexpected error while creating event request: %+v", reqErr)
-			return nil
+			return nil, reqErr
 		}
 
 		addBaseHeaders(req, t.sdkKey, t.config)
@@ -547,5 +804,5 @@ func (t *sendEventsTask) postEvents(uri string, outputData interface{}, descript
 			break
 		}
 	}
-	return resp
+	return resp, respErr
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"sort"
@@ -260,6 +261,67 @@ func TestDebugEventIsAddedIfFlagIsTemporarilyInDebugMode(t *testing.T) {
 	}
 }
 
+func TestDebugEventIsAddedForSelectedUsers(t *testing.T) {
+	otherUser := NewUser("otherKey")
+	configs := map[string]func(*Config){
+		"by key": func(c *Config) { c.DebugEventsUserKeys = []string{*epDefaultUser.Key} },
+		"by filter": func(c *Config) {
+			c.DebugEventsUserFilter = func(u User) bool { return u.GetName().StringValue() == "Red" }
+		},
+	}
+	for name, modConfig := range configs {
+		t.Run(name, func(t *testing.T) {
+			config := epDefaultConfig
+			config.EventSampling.DebugEventsInterval = neverSampledInterval
+			modConfig(&config)
+			ep, st := createEventProcessor(config)
+			defer ep.Close()
+
+			flag := FeatureFlag{Key: "flagkey", Version: 11}
+			value := ldvalue.String("value")
+			fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), evalReasonFallthroughInstance, true, nil)
+			ep.SendEvent(fe)
+			ep.SendEvent(newSuccessfulEvalEvent(&flag, otherUser, intPtr(2), value, ldvalue.Null(), nil, false, nil))
+
+			output := flushAndGetEvents(ep, st)
+			if assert.Equal(t, 4, len(output)) {
+				assertIndexEventMatches(t, fe, userJson, output[0])
+				assertFeatureEventMatches(t, fe, flag, value, true, &userJson, output[1])
+				assert.Equal(t, "index", output[2]["kind"])
+				assertSummaryEventHasCounter(t, flag, intPtr(2), value, 2, output[3])
+			}
+		})
+	}
+}
+
+func TestDebugEventForSelectedUserHasReasonButFullEventDoesNot(t *testing.T) {
+	filterCalls := 0
+	config := epDefaultConfig
+	config.DebugEventsUserFilter = func(u User) bool {
+		filterCalls++
+		return true
+	}
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true}
+	value := ldvalue.String("value")
+	fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), evalReasonFallthroughInstance, false, nil)
+	debugUser := true
+	fe.debugUser = &debugUser
+	ep.SendEvent(fe)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 4, len(output)) {
+		assertIndexEventMatches(t, fe, userJson, output[0])
+		assertFeatureEventMatches(t, fe, flag, value, false, nil, output[1])
+		assert.Nil(t, output[1]["reason"])
+		assert.Equal(t, "debug", output[2]["kind"])
+		assert.Equal(t, "FALLTHROUGH", output[2]["reason"].(map[string]interface{})["kind"])
+	}
+	assert.Equal(t, 0, filterCalls) // the client has already checked the user
+}
+
 func TestEventCanBeBothTrackedAndDebugged(t *testing.T) {
 	ep, st := createEventProcessor(epDefaultConfig)
 	defer ep.Close()
@@ -418,6 +480,313 @@ func TestNonTrackedEventsAreSummarized(t *testing.T) {
 	}
 }
 
+// An interval this large means that, in practice, an event will never be sampled.
+const neverSampledInterval = math.MaxInt32
+
+func TestFeatureEventIsSampledOutByFlagKeyButStillSummarized(t *testing.T) {
+	config := epDefaultConfig
+	config.EventSampling.FlagKeyIntervals = map[string]int32{"flagkey": neverSampledInterval}
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true}
+	value := ldvalue.String("value")
+	fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), nil, false, nil)
+	ep.SendEvent(fe)
+	ep.SendEvent(fe)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 2, len(output)) {
+		assertIndexEventMatches(t, fe, userJson, output[0])
+		assertSummaryEventHasCounter(t, flag, intPtr(2), value, 2, output[1])
+	}
+}
+
+func TestFlagKeySamplingIntervalOverridesEventKindInterval(t *testing.T) {
+	config := epDefaultConfig
+	config.EventSampling.FeatureEventsInterval = neverSampledInterval
+	config.EventSampling.FlagKeyIntervals = map[string]int32{"flagkey": 1}
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true}
+	otherFlag := FeatureFlag{Key: "otherkey", Version: 11, TrackEvents: true}
+	value := ldvalue.String("value")
+	fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), nil, false, nil)
+	ep.SendEvent(fe)
+	ep.SendEvent(newSuccessfulEvalEvent(&otherFlag, epDefaultUser, intPtr(2), value, ldvalue.Null(), nil, false, nil))
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 3, len(output)) {
+		assertIndexEventMatches(t, fe, userJson, output[0])
+		assertFeatureEventMatches(t, fe, flag, value, false, nil, output[1])
+		assertSummaryEventHasCounter(t, otherFlag, intPtr(2), value, 1, output[2])
+	}
+}
+
+func TestDebugEventIsSampledOutByEventKindInterval(t *testing.T) {
+	config := epDefaultConfig
+	config.EventSampling.DebugEventsInterval = neverSampledInterval
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	futureTime := now() + 1000000
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true, DebugEventsUntilDate: &futureTime}
+	value := ldvalue.String("value")
+	fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), nil, false, nil)
+	ep.SendEvent(fe)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 3, len(output)) {
+		assertIndexEventMatches(t, fe, userJson, output[0])
+		assertFeatureEventMatches(t, fe, flag, value, false, nil, output[1])
+		assertSummaryEventHasCounter(t, flag, intPtr(2), value, 1, output[2])
+	}
+}
+
+func TestDeprecatedSamplingIntervalUsesOneRollForFullAndDebugEvents(t *testing.T) {
+	config := epDefaultConfig
+	config.SamplingInterval = 2
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	futureTime := now() + 1000000
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true, DebugEventsUntilDate: &futureTime}
+	value := ldvalue.String("value")
+	for i := 0; i < 20; i++ {
+		ep.SendEvent(newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), nil, false, nil))
+		kinds := make(map[interface{}]int)
+		for _, e := range flushAndGetEvents(ep, st) {
+			kinds[e["kind"]]++
+		}
+		assert.Equal(t, kinds["feature"], kinds["debug"], "full and debug events should be sampled together")
+	}
+}
+
+func TestCustomEventIsSampledOutByEventKey(t *testing.T) {
+	config := epDefaultConfig
+	config.EventSampling.CustomEventKeyIntervals = map[string]int32{"noisy": neverSampledInterval}
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	ce1 := newCustomEvent("noisy", epDefaultUser, ldvalue.Null(), false, 0)
+	ce2 := newCustomEvent("interesting", epDefaultUser, ldvalue.Null(), false, 0)
+	ep.SendEvent(ce1)
+	ep.SendEvent(ce2)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 2, len(output)) {
+		assertIndexEventMatches(t, ce1, userJson, output[0])
+		assert.Equal(t, "interesting", output[1]["key"])
+	}
+}
+
+func TestFeatureEventsOverUserLimitAreOnlySummarized(t *testing.T) {
+	config := epDefaultConfig
+	config.UserEventsLimit = 1
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true}
+	value := ldvalue.String("value")
+	fe1 := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), nil, false, nil)
+	fe2 := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), value, ldvalue.Null(), nil, false, nil)
+	ep.SendEvent(fe1)
+	ep.SendEvent(fe2)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 3, len(output)) {
+		assertIndexEventMatches(t, fe1, userJson, output[0])
+		assertFeatureEventMatches(t, fe1, flag, value, false, nil, output[1])
+		assertSummaryEventHasCounter(t, flag, intPtr(2), value, 2, output[2])
+	}
+}
+
+func TestCustomEventsOverUserLimitAreReportedAsDropped(t *testing.T) {
+	config := epDefaultConfig
+	config.UserEventsLimit = 1
+	config.DiagnosticRecordingInterval = 100 * time.Millisecond
+	periodicEventGate := make(chan struct{})
+	config.diagnosticsManager = newDiagnosticsManager(newDiagnosticId("sdkkey"), config, time.Second, time.Now(), periodicEventGate)
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+	st.awaitRequest() // diagnostic init event
+
+	otherUser := NewUser("otherKey")
+	ce1 := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0)
+	ce2 := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0)
+	ce3 := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0)
+	ce4 := newCustomEvent("eventkey", otherUser, ldvalue.Null(), false, 0)
+	ep.SendEvent(ce1)
+	ep.SendEvent(ce2)
+	ep.SendEvent(ce3)
+	ep.SendEvent(ce4)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 4, len(output)) { // no summary event, since there were no evaluations
+		assertIndexEventMatches(t, ce1, userJson, output[0])
+		assert.Equal(t, "custom", output[1]["kind"])
+		assert.Equal(t, "index", output[2]["kind"])
+		assert.Equal(t, "custom", output[3]["kind"])
+		assert.Equal(t, "otherKey", output[3]["userKey"])
+	}
+	assert.Equal(t, map[EventDropReason]int{EventDropUserLimit: 2}, ep.getEventStats().EventsDropped)
+
+	periodicEventGate <- struct{}{}
+	_, bytes := st.awaitRequest()
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal(bytes, &event))
+	assert.Equal(t, "diagnostic", event["kind"])
+	assert.Equal(t, float64(2), event["droppedEvents"])
+}
+
+func TestSummaryEventCountersHaveReasonsIfEnabled(t *testing.T) {
+	config := epDefaultConfig
+	config.SummarizeEvaluationReasons = true
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	flag := FeatureFlag{Key: "flagkey", Version: 11}
+	value := ldvalue.String("value")
+	fe1 := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(1), value, ldvalue.Null(), nil, false, nil)
+	fe1.summaryReason = newEvalReasonRuleMatch(0, "rule-id")
+	fe2 := newUnknownFlagEvent("badkey", epDefaultUser, ldvalue.Null(), nil, false)
+	fe2.summaryReason = newEvalReasonError(EvalErrorFlagNotFound)
+	ep.SendEvent(fe1)
+	ep.SendEvent(fe1)
+	ep.SendEvent(fe2)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 2, len(output)) {
+		features := output[1]["features"].(map[string]interface{})
+		counters1 := features["flagkey"].(map[string]interface{})["counters"]
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"value":     "value",
+			"variation": float64(1),
+			"version":   float64(11),
+			"count":     float64(2),
+			"reason":    map[string]interface{}{"kind": "RULE_MATCH", "ruleId": "rule-id"},
+		}}, counters1)
+		counters2 := features["badkey"].(map[string]interface{})["counters"]
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"value":   nil,
+			"unknown": true,
+			"count":   float64(1),
+			"reason":  map[string]interface{}{"kind": "ERROR", "errorKind": "FLAG_NOT_FOUND"},
+		}}, counters2)
+	}
+
+	counts := ep.getEvaluationReasonCounts()
+	assert.Len(t, counts, 2)
+	assert.Contains(t, counts, EvaluationReasonCount{FlagKey: "flagkey", Variation: intPtr(1),
+		ReasonKind: EvalReasonRuleMatch, RuleID: "rule-id", Count: 2})
+	assert.Contains(t, counts, EvaluationReasonCount{FlagKey: "badkey",
+		ReasonKind: EvalReasonError, ErrorKind: EvalErrorFlagNotFound, Count: 1})
+}
+
+func TestEvaluationReasonCountsAreNotResetByFlush(t *testing.T) {
+	config := epDefaultConfig
+	config.SummarizeEvaluationReasons = true
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	flag := FeatureFlag{Key: "flagkey", Version: 11}
+	fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(1), ldvalue.String("value"), ldvalue.Null(), nil, false, nil)
+	fe.summaryReason = evalReasonFallthroughInstance
+	ep.SendEvent(fe)
+	flushAndGetEvents(ep, st)
+	ep.SendEvent(fe)
+	ep.waitUntilInactive()
+
+	assert.Equal(t, []EvaluationReasonCount{{FlagKey: "flagkey", Variation: intPtr(1),
+		ReasonKind: EvalReasonFallthrough, Count: 2}}, ep.getEvaluationReasonCounts())
+}
+
+func TestEvaluationReasonCountsAreNilIfNotEnabled(t *testing.T) {
+	ep, _ := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+
+	assert.Nil(t, ep.getEvaluationReasonCounts())
+}
+
+func TestMetricEventsAreAggregatedIfEnabled(t *testing.T) {
+	config := epDefaultConfig
+	config.AggregateMetrics = true
+	config.MetricHistogramBounds = []float64{5}
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	ce1 := newCustomEvent("eventkey", epDefaultUser, ldvalue.String("ignored"), true, 2)
+	ce1.CreationDate = 1000
+	ce2 := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), true, 8)
+	ce2.CreationDate = 2000
+	ce3 := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0)
+	ep.SendEvent(ce1)
+	ep.SendEvent(ce2)
+	ep.SendEvent(ce3)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 3, len(output)) {
+		assertIndexEventMatches(t, ce1, userJson, output[0])
+		assert.Equal(t, "custom", output[1]["kind"])
+		expected := map[string]interface{}{
+			"kind":      MetricSummaryEventKind,
+			"startDate": float64(1000),
+			"endDate":   float64(2000),
+			"key":       "eventkey",
+			"userKey":   *epDefaultUser.Key,
+			"count":     float64(2),
+			"sum":       float64(10),
+			"min":       float64(2),
+			"max":       float64(8),
+			"histogram": map[string]interface{}{
+				"bounds": []interface{}{float64(5)},
+				"counts": []interface{}{float64(1), float64(1)},
+			},
+		}
+		assert.Equal(t, expected, output[2])
+	}
+
+	ce4 := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), true, 1)
+	ep.SendEvent(ce4)
+	output = flushAndGetEvents(ep, st)
+	if assert.Equal(t, 1, len(output)) {
+		assert.Equal(t, float64(1), output[0]["count"])
+	}
+}
+
+func TestMetricAggregatesCountTowardEventCapacity(t *testing.T) {
+	config := epDefaultConfig
+	config.AggregateMetrics = true
+	config.Capacity = 3
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	// the inbox has the same capacity as the buffer, so we wait for each event to be processed
+	for _, e := range []Event{
+		newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0), // index event + custom event
+		newCustomEvent("metric1", epDefaultUser, ldvalue.Null(), true, 1),   // new aggregate
+		newCustomEvent("metric2", epDefaultUser, ldvalue.Null(), true, 1),   // no room for another aggregate
+		newCustomEvent("metric1", epDefaultUser, ldvalue.Null(), true, 2),   // added to existing aggregate
+		newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0), // no room
+	} {
+		ep.SendEvent(e)
+		ep.waitUntilInactive()
+	}
+
+	stats := ep.getEventStats()
+	assert.Equal(t, map[EventDropReason]int{EventDropCapacityExceeded: 2}, stats.EventsDropped)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 3, len(output)) {
+		assert.Equal(t, "index", output[0]["kind"])
+		assert.Equal(t, "custom", output[1]["kind"])
+		assert.Equal(t, MetricSummaryEventKind, output[2]["kind"])
+		assert.Equal(t, "metric1", output[2]["key"])
+		assert.Equal(t, float64(2), output[2]["count"])
+	}
+}
+
 func TestCustomEventIsQueuedWithUser(t *testing.T) {
 	ep, st := createEventProcessor(epDefaultConfig)
 	defer ep.Close()
@@ -444,6 +813,85 @@ func TestCustomEventIsQueuedWithUser(t *testing.T) {
 	}
 }
 
+func TestCustomEventForAnonymousUserHasContextKind(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+
+	user := NewAnonymousUser("anonKey")
+	ce := newCustomEvent("eventkey", user, ldvalue.Null(), false, 0)
+	ep.SendEvent(ce)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 2, len(output)) {
+		ceo := output[1]
+		expected := map[string]interface{}{
+			"kind":         "custom",
+			"creationDate": float64(ce.CreationDate),
+			"key":          ce.Key,
+			"userKey":      "anonKey",
+			"contextKind":  "anonymousUser",
+		}
+		assert.Equal(t, expected, ceo)
+	}
+}
+
+func TestFeatureEventForAnonymousUserHasContextKind(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+
+	user := NewAnonymousUser("anonKey")
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true}
+	fe := newSuccessfulEvalEvent(&flag, user, intPtr(2), ldvalue.String("value"), ldvalue.Null(), nil, false, nil)
+	ep.SendEvent(fe)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 3, len(output)) {
+		assert.Equal(t, "feature", output[1]["kind"])
+		assert.Equal(t, "anonymousUser", output[1]["contextKind"])
+	}
+}
+
+func TestAliasEventIsQueued(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+
+	ae := NewAliasEvent(NewUser("userKey"), NewAnonymousUser("anonKey"))
+	ep.SendEvent(ae)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 1, len(output)) { // no index event
+		expected := map[string]interface{}{
+			"kind":                "alias",
+			"creationDate":        float64(ae.CreationDate),
+			"key":                 "userKey",
+			"contextKind":         "user",
+			"previousKey":         "anonKey",
+			"previousContextKind": "anonymousUser",
+		}
+		assert.Equal(t, expected, output[0])
+	}
+}
+
+func TestUserKeyIsHashedInCustomEventIfEnabled(t *testing.T) {
+	config := epDefaultConfig
+	config.PrivateAttributeHashKey = []byte("secret")
+	config.HashUserKeyInEvents = true
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	ce := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0)
+	ep.SendEvent(ce)
+
+	output := flushAndGetEvents(ep, st)
+	if assert.Equal(t, 2, len(output)) {
+		filter := newUserFilter(config)
+		hashedKey := filter.hashString(*epDefaultUser.Key)
+		hashedUserJSON := map[string]interface{}{"key": hashedKey, "name": "Red", "privateAttrs": []interface{}{"key"}}
+		assertIndexEventMatches(t, ce, hashedUserJSON, output[0])
+		assert.Equal(t, hashedKey, output[1]["userKey"])
+	}
+}
+
 func TestCustomEventCanContainInlineUser(t *testing.T) {
 	config := epDefaultConfig
 	config.InlineUsersInEvents = true
@@ -729,6 +1177,21 @@ func TestDiagnosticInitEventIsSent(t *testing.T) {
 	assert.Equal(t, float64(toUnixMillis(startTime)), event["creationDate"])
 }
 
+func TestDiagnosticEventsAreNotSentIfOptedOut(t *testing.T) {
+	diagnosticsManager := newDiagnosticsManager(newDiagnosticId("sdkkey"), DefaultConfig, time.Second, time.Now(), nil)
+	config := epDefaultConfig
+	config.diagnosticsManager = diagnosticsManager
+	config.DiagnosticOptOut = true
+
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	ep.Flush()
+	req, _ := st.awaitRequest()
+	assert.Equal(t, "/bulk", req.URL.Path)
+}
+
 func TestDiagnosticPeriodicEventsAreSent(t *testing.T) {
 	id := newDiagnosticId("sdkkey")
 	startTime := time.Now()
@@ -886,6 +1349,125 @@ func assertSummaryEventHasCounter(t *testing.T, flag FeatureFlag, variation *int
 	}
 }
 
+func TestFlushIsTriggeredByEventCount(t *testing.T) {
+	config := epDefaultConfig
+	config.FlushAfterEventCount = 2
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	ep.waitUntilInactive()
+	assert.Nil(t, st.getNextRequest())
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	ep.waitUntilInactive()
+	assert.Len(t, getEventsFromRequest(st), 2)
+}
+
+func TestFlushIsTriggeredByEstimatedSize(t *testing.T) {
+	ie := NewIdentifyEvent(epDefaultUser)
+	config := epDefaultConfig
+	config.FlushAfterBytes = estimateEventSize(ie, false)*2 - 1
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	ep.SendEvent(ie)
+	ep.waitUntilInactive()
+	assert.Nil(t, st.getNextRequest())
+
+	ep.SendEvent(ie)
+	ep.waitUntilInactive()
+	assert.Len(t, getEventsFromRequest(st), 2)
+}
+
+func TestUrgentEventTriggersFlushAndIsNotSampled(t *testing.T) {
+	config := epDefaultConfig
+	config.EventSampling.CustomEventsInterval = neverSampledInterval
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	ce := newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0)
+	ce.urgent = true
+	ep.SendEvent(ce)
+	ep.waitUntilInactive()
+
+	output := getEventsFromRequest(st)
+	if assert.Equal(t, 2, len(output)) {
+		assertIndexEventMatches(t, ce, userJson, output[0])
+		assert.Equal(t, "custom", output[1]["kind"])
+		assert.Nil(t, output[1]["urgent"])
+	}
+}
+
+func TestEventStatsCountProcessedAndDroppedEvents(t *testing.T) {
+	config := epDefaultConfig
+	config.Capacity = 2
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+
+	// the inbox has the same capacity as the buffer, so we wait for each event to be processed
+	for _, e := range []Event{
+		NewIdentifyEvent(epDefaultUser),
+		newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0),
+		newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0),
+	} {
+		ep.SendEvent(e)
+		ep.waitUntilInactive()
+	}
+
+	stats := ep.getEventStats()
+	assert.Equal(t, map[string]int{IdentifyEventKind: 1, CustomEventKind: 2}, stats.EventsProcessed)
+	assert.Equal(t, map[EventDropReason]int{EventDropCapacityExceeded: 1}, stats.EventsDropped)
+	assert.Equal(t, 2, stats.DeduplicatedUsers)
+	assert.Equal(t, 2, stats.QueueDepth)
+
+	output := flushAndGetEvents(ep, st)
+	assert.Equal(t, 2, len(output))
+
+	stats = ep.getEventStats()
+	assert.Equal(t, 0, stats.QueueDepth)
+	assert.Equal(t, 1, stats.FlushesAttempted)
+	assert.Equal(t, 1, stats.FlushesSucceeded)
+	assert.Equal(t, 200, stats.LastHTTPStatus)
+	assert.True(t, stats.BytesSent > 0)
+}
+
+func TestEventStatsCountFailedFlush(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+	st.statusCode = 503
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	ep.Flush()
+	ep.waitUntilInactive()
+
+	stats := ep.getEventStats()
+	assert.Equal(t, 1, stats.FlushesAttempted)
+	assert.Equal(t, 0, stats.FlushesSucceeded)
+	assert.Equal(t, 503, stats.LastHTTPStatus)
+	assert.Equal(t, int64(0), stats.BytesSent)
+	assert.Equal(t, map[EventDropReason]int{EventDropSendFailed: 1}, stats.EventsDropped)
+}
+
+func TestEventStatsCountSampledEvents(t *testing.T) {
+	config := epDefaultConfig
+	config.EventSampling.CustomEventsInterval = neverSampledInterval
+	ep, _ := createEventProcessor(config)
+	defer ep.Close()
+
+	ep.SendEvent(newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0))
+	ep.waitUntilInactive()
+
+	assert.Equal(t, map[EventDropReason]int{EventDropSampled: 1}, ep.getEventStats().EventsDropped)
+}
+
+func TestEventStatsAreZeroWithoutDefaultEventProcessor(t *testing.T) {
+	client := makeTestClient()
+	defer client.Close()
+
+	assert.Equal(t, EventStats{}, client.EventStats())
+}
+
 func createEventProcessor(config Config) (*defaultEventProcessor, *stubTransport) {
 	transport := &stubTransport{
 		statusCode:  200,
@@ -0,0 +1,151 @@
+package ldclient
+
+import (
+	"sync"
+	"time"
+)
+
+// EventDropReason describes why an analytics event was not sent. See EventStats.
+type EventDropReason string
+
+const (
+	// EventDropInboxFull means the event was produced faster than the event processor could accept it.
+	EventDropInboxFull EventDropReason = "inboxFull"
+	// EventDropCapacityExceeded means the event buffer was full; see Config.Capacity.
+	EventDropCapacityExceeded EventDropReason = "capacityExceeded"
+	// EventDropSampled means the event was excluded by sampling; see Config.EventSampling.
+	EventDropSampled EventDropReason = "sampled"
+	// EventDropUserLimit means the user had exceeded Config.UserEventsLimit. The event may still have
+	// been counted in a summary event.
+	EventDropUserLimit EventDropReason = "userLimit"
+	// EventDropDisabled means event sending had been disabled because LaunchDarkly rejected the SDK key.
+	EventDropDisabled EventDropReason = "disabled"
+	// EventDropSendFailed means the events were in a payload that could not be delivered.
+	EventDropSendFailed EventDropReason = "sendFailed"
+)
+
+// EventStats contains statistics about the activity of the event processor since the client was
+// created. See LDClient.EventStats.
+type EventStats struct {
+	// InboxDepth is the number of events that have been sent to the event processor but not yet
+	// processed.
+	InboxDepth int `json:"inboxDepth"`
+	// QueueDepth is the number of output events that are waiting for the next flush, not counting
+	// the summary event.
+	QueueDepth int `json:"queueDepth"`
+	// EventsProcessed is the number of events that the event processor has received, by kind (such
+	// as FeatureRequestEventKind or CustomEventKind). A feature event that produces both a full event
+	// and a debug event is counted once.
+	EventsProcessed map[string]int `json:"eventsProcessed"`
+	// EventsDropped is the number of output events that were not sent, by reason.
+	EventsDropped map[EventDropReason]int `json:"eventsDropped"`
+	// DeduplicatedUsers is the number of times that an index event was not needed because the user
+	// had already been seen.
+	DeduplicatedUsers int `json:"deduplicatedUsers"`
+	// FlushesAttempted is the number of event payloads that the event processor has tried to deliver.
+	FlushesAttempted int `json:"flushesAttempted"`
+	// FlushesSucceeded is the number of event payloads that were delivered successfully.
+	FlushesSucceeded int `json:"flushesSucceeded"`
+	// LastFlushLatency is the time taken by the most recent delivery attempt, including any retry.
+	LastFlushLatency time.Duration `json:"lastFlushLatencyNanos"`
+	// LastHTTPStatus is the HTTP status of the most recent delivery attempt, or zero if there was no
+	// response.
+	LastHTTPStatus int `json:"lastHttpStatus"`
+	// BytesSent is the total size of all event payloads that were delivered successfully.
+	BytesSent int64 `json:"bytesSent"`
+}
+
+// Optional interface implemented by EventProcessors that support LDClient.EventStats.
+type eventStatsProvider interface {
+	getEventStats() EventStats
+}
+
+// Cumulative counters for EventStats. Unlike the counters used for diagnostic events, these are never
+// reset. This is shared between the event processor's main goroutine and its flush workers.
+type eventStatsRecorder struct {
+	stats EventStats
+	lock  sync.Mutex
+}
+
+func newEventStatsRecorder() *eventStatsRecorder {
+	return &eventStatsRecorder{
+		stats: EventStats{
+			EventsProcessed: make(map[string]int),
+			EventsDropped:   make(map[EventDropReason]int),
+		},
+	}
+}
+
+func (r *eventStatsRecorder) recordProcessed(evt Event) {
+	r.lock.Lock()
+	r.stats.EventsProcessed[inputEventKind(evt)]++
+	r.lock.Unlock()
+}
+
+func (r *eventStatsRecorder) recordDropped(reason EventDropReason, count int) {
+	if count <= 0 {
+		return
+	}
+	r.lock.Lock()
+	r.stats.EventsDropped[reason] += count
+	r.lock.Unlock()
+}
+
+func (r *eventStatsRecorder) recordDeduplicatedUser() {
+	r.lock.Lock()
+	r.stats.DeduplicatedUsers++
+	r.lock.Unlock()
+}
+
+func (r *eventStatsRecorder) setQueueDepth(depth int) {
+	r.lock.Lock()
+	r.stats.QueueDepth = depth
+	r.lock.Unlock()
+}
+
+func (r *eventStatsRecorder) recordFlush(eventCount int, bytes int, statusCode int, latency time.Duration,
+	success bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.stats.FlushesAttempted++
+	r.stats.LastFlushLatency = latency
+	r.stats.LastHTTPStatus = statusCode
+	if success {
+		r.stats.FlushesSucceeded++
+		r.stats.BytesSent += int64(bytes)
+	} else {
+		r.stats.EventsDropped[EventDropSendFailed] += eventCount
+	}
+}
+
+func (r *eventStatsRecorder) snapshot() EventStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	ret := r.stats
+	ret.EventsProcessed = make(map[string]int, len(r.stats.EventsProcessed))
+	for k, v := range r.stats.EventsProcessed {
+		ret.EventsProcessed[k] = v
+	}
+	ret.EventsDropped = make(map[EventDropReason]int, len(r.stats.EventsDropped))
+	for k, v := range r.stats.EventsDropped {
+		ret.EventsDropped[k] = v
+	}
+	return ret
+}
+
+// Returns the kind of an input event, as it would appear in the output.
+func inputEventKind(evt Event) string {
+	switch evt.(type) {
+	case FeatureRequestEvent:
+		return FeatureRequestEventKind
+	case CustomEvent:
+		return CustomEventKind
+	case IdentifyEvent:
+		return IdentifyEventKind
+	case IndexEvent:
+		return IndexEventKind
+	case AliasEvent:
+		return AliasEventKind
+	}
+	return ""
+}
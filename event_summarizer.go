@@ -18,6 +18,7 @@ type counterKey struct {
 	key       string
 	variation int
 	version   int
+	reason    flagCounterReason // zero value unless Config.SummarizeEvaluationReasons is enabled
 }
 
 const (
@@ -57,6 +58,7 @@ func (s *eventSummarizer) summarizeEvent(evt Event) {
 	if fe.Version != nil {
 		key.version = *fe.Version
 	}
+	key.reason = makeFlagCounterReason(fe.summaryReason)
 
 	if value, ok := s.eventsState.counters[key]; ok {
 		value.count++
@@ -82,6 +84,25 @@ func (s *eventSummarizer) snapshot() eventSummary {
 	return s.eventsState
 }
 
+// Returns the properties of an evaluation reason that are used for summary counters. Rule indexes are
+// left out, since a rule ID identifies a rule even if the rules have been reordered.
+func makeFlagCounterReason(reason EvaluationReason) flagCounterReason {
+	if reason == nil {
+		return flagCounterReason{}
+	}
+	return flagCounterReason{
+		Kind:            reason.GetKind(),
+		RuleID:          reason.GetRuleID(),
+		PrerequisiteKey: reason.GetPrerequisiteKey(),
+		ErrorKind:       reason.GetErrorKind(),
+	}
+}
+
+// Returns true if there is anything to send in a summary event.
+func (s eventSummary) hasData() bool {
+	return len(s.counters) > 0
+}
+
 func (s *eventSummarizer) reset() {
 	s.eventsState = newEventSummary()
 }
@@ -74,10 +74,10 @@ func TestSummarizeEventIncrementsCounters(t *testing.T) {
 	data := es.snapshot()
 
 	expectedCounters := map[counterKey]*counterValue{
-		counterKey{flag1.Key, variation1, flag1.Version}: &counterValue{2, "value1", "default1"},
-		counterKey{flag1.Key, variation2, flag1.Version}: &counterValue{1, "value2", "default1"},
-		counterKey{flag2.Key, variation1, flag2.Version}: &counterValue{1, "value99", "default2"},
-		counterKey{unknownFlagKey, -1, 0}:                &counterValue{1, "default3", "default3"},
+		counterKey{flag1.Key, variation1, flag1.Version, flagCounterReason{}}: &counterValue{2, "value1", "default1"},
+		counterKey{flag1.Key, variation2, flag1.Version, flagCounterReason{}}: &counterValue{1, "value2", "default1"},
+		counterKey{flag2.Key, variation1, flag2.Version, flagCounterReason{}}: &counterValue{1, "value99", "default2"},
+		counterKey{unknownFlagKey, -1, 0, flagCounterReason{}}:                &counterValue{1, "default3", "default3"},
 	}
 	assert.Equal(t, expectedCounters, data.counters)
 }
@@ -99,9 +99,36 @@ func TestCounterForNilVariationIsDistinctFromOthers(t *testing.T) {
 	data := es.snapshot()
 
 	expectedCounters := map[counterKey]*counterValue{
-		counterKey{flag.Key, variation1, flag.Version}: &counterValue{1, "value1", "default1"},
-		counterKey{flag.Key, variation2, flag.Version}: &counterValue{1, "value2", "default1"},
-		counterKey{flag.Key, -1, flag.Version}:         &counterValue{1, "default1", "default1"},
+		counterKey{flag.Key, variation1, flag.Version, flagCounterReason{}}: &counterValue{1, "value1", "default1"},
+		counterKey{flag.Key, variation2, flag.Version, flagCounterReason{}}: &counterValue{1, "value2", "default1"},
+		counterKey{flag.Key, -1, flag.Version, flagCounterReason{}}:         &counterValue{1, "default1", "default1"},
+	}
+	assert.Equal(t, expectedCounters, data.counters)
+}
+
+func TestCountersAreKeyedByReasonIfPresent(t *testing.T) {
+	es := newEventSummarizer()
+	flag := FeatureFlag{
+		Key:     "key1",
+		Version: 11,
+	}
+	variation := 1
+	event1 := newSuccessfulEvalEvent(&flag, user, &variation, ldvalue.String("value1"), ldvalue.String("default1"), nil, false, nil)
+	event1.summaryReason = newEvalReasonRuleMatch(0, "rule-a")
+	event2 := event1
+	event2.summaryReason = newEvalReasonRuleMatch(1, "rule-b")
+	event3 := event1
+	event3.summaryReason = evalReasonFallthroughInstance
+	es.summarizeEvent(event1)
+	es.summarizeEvent(event1)
+	es.summarizeEvent(event2)
+	es.summarizeEvent(event3)
+	data := es.snapshot()
+
+	expectedCounters := map[counterKey]*counterValue{
+		counterKey{flag.Key, variation, flag.Version, flagCounterReason{Kind: EvalReasonRuleMatch, RuleID: "rule-a"}}: &counterValue{2, "value1", "default1"},
+		counterKey{flag.Key, variation, flag.Version, flagCounterReason{Kind: EvalReasonRuleMatch, RuleID: "rule-b"}}: &counterValue{1, "value1", "default1"},
+		counterKey{flag.Key, variation, flag.Version, flagCounterReason{Kind: EvalReasonFallthrough}}:                 &counterValue{1, "value1", "default1"},
 	}
 	assert.Equal(t, expectedCounters, data.counters)
 }
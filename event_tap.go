@@ -0,0 +1,200 @@
+package ldclient
+
+import (
+	"encoding/json"
+	"sync"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v4/ldlog"
+)
+
+// EventFilter describes which analytics events should be delivered to an EventSubscription.
+type EventFilter struct {
+	// Kinds restricts the subscription to events of the given kinds, such as FeatureRequestEventKind
+	// or SummaryEventKind. If empty, events of all kinds are delivered.
+	Kinds []string
+	// Keys restricts the subscription to events that refer to one of the given keys. For feature and
+	// debug events this is the flag key, and for custom and metric-summary events it is the event key.
+	// Summary events are delivered with only the counters for matching flags, and are not delivered if
+	// none match. Identify, index, and alias events have no such key, so they are
+	// not delivered if Keys is non-empty.
+	Keys []string
+}
+
+// PublishedEvent is a copy of an analytics event, in the same form that will be sent to LaunchDarkly.
+type PublishedEvent struct {
+	// Kind is the event kind, such as FeatureRequestEventKind.
+	Kind string
+	// Key is the flag key for feature and debug events, or the event key for custom and metric-summary
+	// events. It is empty for all other kinds.
+	Key string
+	// JSON is the serialized event, after private user attributes have been removed.
+	JSON json.RawMessage
+}
+
+// EventSubscription represents a subscription to analytics events. See LDClient.SubscribeEvents.
+type EventSubscription interface {
+	// Channel returns the channel for receiving events.
+	Channel() <-chan PublishedEvent
+	// Close stops the subscription, closing the channel.
+	Close()
+}
+
+// Optional interface implemented by EventProcessors that support LDClient.SubscribeEvents.
+type eventSubscriber interface {
+	subscribeEvents(filter EventFilter) EventSubscription
+}
+
+// The maximum number of events that can be waiting in a subscriber's channel. If the subscriber
+// falls further behind than this, events are dropped rather than holding up event delivery.
+const eventSubscriptionCapacity = 1000
+
+// Delivers copies of output events to subscribers. This is shared between the event processor and
+// its flush workers; publish is called by the workers just before a payload is posted.
+type eventTap struct {
+	broadcaster
+	loggers ldlog.Loggers
+}
+
+type eventTapSubscription struct {
+	ch       chan PublishedEvent
+	filter   EventFilter
+	owner    *eventTap
+	fullOnce sync.Once
+}
+
+func newEventTap(loggers ldlog.Loggers) *eventTap {
+	return &eventTap{loggers: loggers}
+}
+
+func (t *eventTap) subscribe(filter EventFilter) EventSubscription {
+	sub := &eventTapSubscription{
+		ch:     make(chan PublishedEvent, eventSubscriptionCapacity),
+		filter: filter,
+		owner:  t,
+	}
+	t.broadcaster.subscribe(sub)
+	return sub
+}
+
+// Delivers the given output events to every subscriber whose filter matches them. This never blocks:
+// if a subscriber's channel is full, the event is dropped for that subscriber.
+func (t *eventTap) publish(outputEvents []interface{}) {
+	t.withSubscriptions(func(subs []broadcastSubscription) {
+		for _, oe := range outputEvents {
+			kind, key := describeOutputEvent(oe)
+			var data json.RawMessage // serialized lazily, since there may be no subscribers for this kind
+			for _, s := range subs {
+				sub := s.(*eventTapSubscription)
+				if !sub.filter.matchesKind(kind) {
+					continue
+				}
+				if se, ok := oe.(summaryEventOutput); ok && len(sub.filter.Keys) > 0 {
+					filtered := sub.filter.filterSummary(se)
+					if len(filtered.Features) > 0 {
+						sub.send(t.marshal(kind, key, filtered))
+					}
+					continue
+				}
+				if !sub.filter.matchesKey(key) {
+					continue
+				}
+				if data == nil {
+					data = t.marshal(kind, key, oe).JSON
+				}
+				sub.send(PublishedEvent{Kind: kind, Key: key, JSON: data})
+			}
+		}
+	})
+}
+
+func (t *eventTap) marshal(kind, key string, outputEvent interface{}) PublishedEvent {
+	data, err := json.Marshal(outputEvent)
+	if err != nil {
+		t.loggers.Errorf("Unexpected error marshalling event json: %+v", err)
+	}
+	return PublishedEvent{Kind: kind, Key: key, JSON: data}
+}
+
+func (s *eventTapSubscription) send(e PublishedEvent) {
+	if e.JSON == nil {
+		return
+	}
+	select {
+	case s.ch <- e:
+	default:
+		s.fullOnce.Do(func() {
+			s.owner.loggers.Warn("An event subscriber is not keeping up with analytics events; some events will not be delivered to it")
+		})
+	}
+}
+
+func (s *eventTapSubscription) closeChannel() {
+	close(s.ch)
+}
+
+func (s *eventTapSubscription) Channel() <-chan PublishedEvent {
+	return s.ch
+}
+
+func (s *eventTapSubscription) Close() {
+	s.owner.unsubscribe(s)
+}
+
+func (f EventFilter) matchesKind(kind string) bool {
+	if len(f.Kinds) == 0 {
+		return true
+	}
+	for _, k := range f.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (f EventFilter) matchesKey(key string) bool {
+	if len(f.Keys) == 0 {
+		return true
+	}
+	if key == "" {
+		return false
+	}
+	for _, k := range f.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (f EventFilter) filterSummary(se summaryEventOutput) summaryEventOutput {
+	ret := se
+	ret.Features = make(map[string]flagSummaryData)
+	for key, data := range se.Features {
+		if f.matchesKey(key) {
+			ret.Features[key] = data
+		}
+	}
+	return ret
+}
+
+// Returns the kind and (if applicable) the flag or event key of an output event.
+func describeOutputEvent(outputEvent interface{}) (kind string, key string) {
+	switch e := outputEvent.(type) {
+	case featureRequestEventOutput:
+		return e.Kind, e.Key
+	case customEventOutput:
+		return e.Kind, e.Key
+	case metricSummaryEventOutput:
+		return e.Kind, e.Key
+	case identifyEventOutput:
+		return e.Kind, ""
+	case indexEventOutput:
+		return e.Kind, ""
+	case summaryEventOutput:
+		return e.Kind, ""
+	case aliasEventOutput:
+		return e.Kind, ""
+	}
+	return "", ""
+}
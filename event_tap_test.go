@@ -0,0 +1,129 @@
+package ldclient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/launchdarkly/go-sdk-common.v1/ldvalue"
+)
+
+func TestSubscriberReceivesAllEventKinds(t *testing.T) {
+	config := epDefaultConfig
+	config.PrivateAttributeNames = []string{"name"}
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+	sub := ep.subscribeEvents(EventFilter{})
+	defer sub.Close()
+
+	flag := FeatureFlag{Key: "flagkey", Version: 11, TrackEvents: true}
+	fe := newSuccessfulEvalEvent(&flag, epDefaultUser, intPtr(2), ldvalue.String("value"), ldvalue.Null(), nil, false, nil)
+	ep.SendEvent(fe)
+	ep.SendEvent(newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0))
+
+	output := flushAndGetEvents(ep, st)
+	require.Equal(t, 4, len(output))
+
+	for i, expectedKind := range []string{IndexEventKind, FeatureRequestEventKind, CustomEventKind, SummaryEventKind} {
+		e := <-sub.Channel()
+		assert.Equal(t, expectedKind, e.Kind)
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal(e.JSON, &m))
+		assert.Equal(t, output[i], m)
+	}
+	assert.Equal(t, 0, len(sub.Channel()))
+}
+
+func TestSubscriberReceivesScrubbedUser(t *testing.T) {
+	config := epDefaultConfig
+	config.AllAttributesPrivate = true
+	ep, st := createEventProcessor(config)
+	defer ep.Close()
+	sub := ep.subscribeEvents(EventFilter{Kinds: []string{IdentifyEventKind}})
+	defer sub.Close()
+
+	ie := NewIdentifyEvent(epDefaultUser)
+	ep.SendEvent(ie)
+	flushAndGetEvents(ep, st)
+
+	e := <-sub.Channel()
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(e.JSON, &m))
+	assertIdentifyEventMatches(t, ie, filteredUserJson, m)
+}
+
+func TestSubscriberFilterByKind(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+	sub := ep.subscribeEvents(EventFilter{Kinds: []string{CustomEventKind}})
+	defer sub.Close()
+
+	ep.SendEvent(newCustomEvent("eventkey", epDefaultUser, ldvalue.Null(), false, 0))
+	flushAndGetEvents(ep, st)
+
+	e := <-sub.Channel()
+	assert.Equal(t, CustomEventKind, e.Kind)
+	assert.Equal(t, "eventkey", e.Key)
+	assert.Equal(t, 0, len(sub.Channel()))
+}
+
+func TestSubscriberFilterByKeyTrimsSummary(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+	sub := ep.subscribeEvents(EventFilter{Keys: []string{"flagkey1"}})
+	defer sub.Close()
+
+	flag1 := FeatureFlag{Key: "flagkey1", Version: 11, TrackEvents: true}
+	flag2 := FeatureFlag{Key: "flagkey2", Version: 22, TrackEvents: true}
+	value := ldvalue.String("value")
+	ep.SendEvent(newSuccessfulEvalEvent(&flag1, epDefaultUser, intPtr(1), value, ldvalue.Null(), nil, false, nil))
+	ep.SendEvent(newSuccessfulEvalEvent(&flag2, epDefaultUser, intPtr(1), value, ldvalue.Null(), nil, false, nil))
+	flushAndGetEvents(ep, st)
+
+	e1 := <-sub.Channel()
+	assert.Equal(t, FeatureRequestEventKind, e1.Kind)
+	assert.Equal(t, "flagkey1", e1.Key)
+
+	e2 := <-sub.Channel()
+	assert.Equal(t, SummaryEventKind, e2.Kind)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(e2.JSON, &m))
+	assertSummaryEventHasCounter(t, flag1, intPtr(1), value, 1, m)
+	assert.Nil(t, m["features"].(map[string]interface{})["flagkey2"])
+	assert.Equal(t, 0, len(sub.Channel()))
+}
+
+func TestClosedSubscriptionReceivesNoEvents(t *testing.T) {
+	ep, st := createEventProcessor(epDefaultConfig)
+	defer ep.Close()
+	sub := ep.subscribeEvents(EventFilter{})
+	sub.Close()
+
+	ep.SendEvent(NewIdentifyEvent(epDefaultUser))
+	flushAndGetEvents(ep, st)
+
+	_, ok := <-sub.Channel()
+	assert.False(t, ok)
+}
+
+func TestSubscriptionIsClosedWhenEventProcessorIsClosed(t *testing.T) {
+	ep, _ := createEventProcessor(epDefaultConfig)
+	sub := ep.subscribeEvents(EventFilter{})
+	ep.Close()
+
+	_, ok := <-sub.Channel()
+	assert.False(t, ok)
+	sub.Close() // should not panic
+}
+
+func TestSubscribeEventsWithoutEventProcessorReturnsClosedChannel(t *testing.T) {
+	client := makeTestClientWithConfig(func(c *Config) {
+		c.EventProcessor = newNullEventProcessor()
+	})
+	defer client.Close()
+
+	sub := client.SubscribeEvents(EventFilter{})
+	_, ok := <-sub.Channel()
+	assert.False(t, ok)
+}
@@ -34,6 +34,14 @@ type FeatureRequestEvent struct {
 	TrackEvents          bool
 	Debug                bool
 	DebugEventsUntilDate *uint64
+	// The evaluation reason to use for summary counters. Unlike Reason, this does not affect the output
+	// event; it is only set if Config.SummarizeEvaluationReasons is enabled.
+	summaryReason EvaluationReason
+	// Set by the client to whether the user was selected by Config.DebugEventsUserKeys or
+	// DebugEventsUserFilter, so the event processor does not have to check again; nil if not known.
+	debugUser *bool
+	// The evaluation reason, which is added to the debug event for a selected user even if Reason is not set.
+	evalReason EvaluationReason
 }
 
 // CustomEvent is generated by calling the client's Track method.
@@ -42,6 +50,8 @@ type CustomEvent struct {
 	Key         string
 	Data        interface{}
 	MetricValue *float64
+	// Set by LDClient.TrackDataUrgent. This is not part of the output event.
+	urgent bool
 }
 
 // IdentifyEvent is generated by calling the client's Identify method.
@@ -54,6 +64,16 @@ type IndexEvent struct {
 	BaseEvent
 }
 
+// AliasEvent is generated by calling the client's Alias method. Unlike other events, it does not
+// retain the User objects; it only records the keys and context kinds of the two users.
+type AliasEvent struct {
+	CreationDate        uint64
+	Key                 string
+	ContextKind         string
+	PreviousKey         string
+	PreviousContextKind string
+}
+
 // NewFeatureRequestEvent creates a feature request event. Normally, you don't need to call this;
 // the event is created and queued automatically during feature flag evaluation.
 //
@@ -86,9 +106,10 @@ func newUnknownFlagEvent(key string, user User, defaultVal ldvalue.Value, reason
 			CreationDate: now(),
 			User:         user,
 		},
-		Key:     key,
-		Value:   defaultAsIntf,
-		Default: defaultAsIntf,
+		Key:        key,
+		Value:      defaultAsIntf,
+		Default:    defaultAsIntf,
+		evalReason: reason,
 	}
 	if includeReason {
 		fre.Reason.Reason = reason
@@ -112,6 +133,7 @@ func newSuccessfulEvalEvent(flag *FeatureFlag, user User, variation *int, value,
 		PrereqOf:             prereqOf,
 		TrackEvents:          requireExperimentData || flag.TrackEvents,
 		DebugEventsUntilDate: flag.DebugEventsUntilDate,
+		evalReason:           reason,
 	}
 	if includeReason || requireExperimentData {
 		fre.Reason.Reason = reason
@@ -190,6 +212,32 @@ func (evt IndexEvent) GetBase() BaseEvent {
 	return evt.BaseEvent
 }
 
+// NewAliasEvent constructs a new alias event, but does not send it. Typically, Alias should be used to both create the
+// event and send it to LaunchDarkly.
+func NewAliasEvent(user User, previousUser User) AliasEvent {
+	return AliasEvent{
+		CreationDate:        now(),
+		Key:                 user.GetKey(),
+		ContextKind:         userContextKind(user),
+		PreviousKey:         previousUser.GetKey(),
+		PreviousContextKind: userContextKind(previousUser),
+	}
+}
+
+// GetBase returns a BaseEvent containing only the creation date, since an alias event does not
+// refer to a single user.
+func (evt AliasEvent) GetBase() BaseEvent {
+	return BaseEvent{CreationDate: evt.CreationDate}
+}
+
+// Returns the context kind that LaunchDarkly uses to distinguish anonymous users from other users.
+func userContextKind(user User) string {
+	if user.GetAnonymous() {
+		return anonymousUserContextKind
+	}
+	return userContextKindDefault
+}
+
 func now() uint64 {
 	return toUnixMillis(time.Now())
 }
@@ -17,6 +17,7 @@ type featureRequestEventOutput struct {
 	Version      *int              `json:"version,omitempty"`
 	PrereqOf     *string           `json:"prereqOf,omitempty"`
 	Reason       EvaluationReason  `json:"reason,omitempty"`
+	ContextKind  string            `json:"contextKind,omitempty"`
 }
 
 // Serializable form of an identify event.
@@ -36,6 +37,44 @@ type customEventOutput struct {
 	User         *serializableUser `json:"user,omitempty"`
 	Data         interface{}       `json:"data,omitempty"`
 	MetricValue  *float64          `json:"metricValue,omitempty"`
+	ContextKind  string            `json:"contextKind,omitempty"`
+}
+
+// Serializable form of the aggregated statistics for the metric values of custom events with the same
+// event key and user, if Config.AggregateMetrics is enabled. Like a custom event, it has a user key
+// instead of a user object unless Config.InlineUsersInEvents is enabled.
+type metricSummaryEventOutput struct {
+	Kind        string                 `json:"kind"`
+	StartDate   uint64                 `json:"startDate"`
+	EndDate     uint64                 `json:"endDate"`
+	Key         string                 `json:"key"`
+	UserKey     *string                `json:"userKey,omitempty"`
+	User        *serializableUser      `json:"user,omitempty"`
+	ContextKind string                 `json:"contextKind,omitempty"`
+	Count       int                    `json:"count"`
+	Sum         float64                `json:"sum"`
+	Min         float64                `json:"min"`
+	Max         float64                `json:"max"`
+	Histogram   *metricHistogramOutput `json:"histogram,omitempty"`
+}
+
+// Histogram of metric values, if Config.MetricHistogramBounds is set. Counts[i] is the number of values
+// that were less than or equal to Bounds[i] (and greater than Bounds[i-1]); the last element of Counts,
+// which has no corresponding bound, is the number of values greater than all of the bounds.
+type metricHistogramOutput struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int     `json:"counts"`
+}
+
+// Serializable form of an alias event, which associates two users (typically an anonymous user
+// and the identified user that it became) so their analytics data can be merged.
+type aliasEventOutput struct {
+	Kind                string `json:"kind"`
+	CreationDate        uint64 `json:"creationDate"`
+	Key                 string `json:"key"`
+	ContextKind         string `json:"contextKind"`
+	PreviousKey         string `json:"previousKey"`
+	PreviousContextKind string `json:"previousContextKind"`
 }
 
 // Serializable form of an index event. This is not generated by an explicit client call,
@@ -61,11 +100,21 @@ type flagSummaryData struct {
 }
 
 type flagCounterData struct {
-	Value     interface{} `json:"value"`
-	Variation *int        `json:"variation,omitempty"`
-	Version   *int        `json:"version,omitempty"`
-	Count     int         `json:"count"`
-	Unknown   *bool       `json:"unknown,omitempty"`
+	Value     interface{}        `json:"value"`
+	Variation *int               `json:"variation,omitempty"`
+	Version   *int               `json:"version,omitempty"`
+	Count     int                `json:"count"`
+	Unknown   *bool              `json:"unknown,omitempty"`
+	Reason    *flagCounterReason `json:"reason,omitempty"`
+}
+
+// Serializable form of the evaluation reason for a summary counter, if Config.SummarizeEvaluationReasons
+// is enabled.
+type flagCounterReason struct {
+	Kind            EvalReasonKind `json:"kind"`
+	RuleID          string         `json:"ruleId,omitempty"`
+	PrerequisiteKey string         `json:"prerequisiteKey,omitempty"`
+	ErrorKind       EvalErrorKind  `json:"errorKind,omitempty"`
 }
 
 // Event types
@@ -76,6 +125,14 @@ const (
 	IdentifyEventKind       = "identify"
 	IndexEventKind          = "index"
 	SummaryEventKind        = "summary"
+	AliasEventKind          = "alias"
+	MetricSummaryEventKind  = "metric-summary"
+)
+
+// Context kinds, used in alias events and in any event that contains a user key instead of a user.
+const (
+	userContextKindDefault   = "user"
+	anonymousUserContextKind = "anonymousUser"
 )
 
 type eventOutputFormatter struct {
@@ -84,15 +141,19 @@ type eventOutputFormatter struct {
 	config      Config
 }
 
-func (ef eventOutputFormatter) makeOutputEvents(events []Event, summary eventSummary) []interface{} {
-	out := make([]interface{}, 0, len(events)+1) // leave room for summary, if any
+func (ef eventOutputFormatter) makeOutputEvents(events []Event, summary eventSummary,
+	metrics []*metricAggregate) []interface{} {
+	out := make([]interface{}, 0, len(events)+len(metrics)+1) // leave room for summary, if any
 	for _, e := range events {
 		oe := ef.makeOutputEvent(e)
 		if oe != nil {
 			out = append(out, oe)
 		}
 	}
-	if len(summary.counters) > 0 {
+	for _, m := range metrics {
+		out = append(out, ef.makeMetricSummaryEvent(m))
+	}
+	if summary.hasData() {
 		out = append(out, ef.makeSummaryEvent(summary))
 	}
 	return out
@@ -114,7 +175,8 @@ func (ef eventOutputFormatter) makeOutputEvent(evt interface{}) interface{} {
 		if ef.inlineUsers || evt.Debug {
 			fe.User = ef.userFilter.scrubUser(evt.User)
 		} else {
-			fe.UserKey = evt.User.Key
+			fe.UserKey = ef.userFilter.filterUserKey(evt.User.Key)
+			fe.ContextKind = anonymousContextKindOrEmpty(evt.User)
 		}
 		if evt.Debug {
 			fe.Kind = FeatureDebugEventKind
@@ -133,14 +195,15 @@ func (ef eventOutputFormatter) makeOutputEvent(evt interface{}) interface{} {
 		if ef.inlineUsers {
 			ce.User = ef.userFilter.scrubUser(evt.User)
 		} else {
-			ce.UserKey = evt.User.Key
+			ce.UserKey = ef.userFilter.filterUserKey(evt.User.Key)
+			ce.ContextKind = anonymousContextKindOrEmpty(evt.User)
 		}
 		return ce
 	case IdentifyEvent:
 		return identifyEventOutput{
 			Kind:         IdentifyEventKind,
 			CreationDate: evt.BaseEvent.CreationDate,
-			Key:          evt.User.Key,
+			Key:          ef.userFilter.filterUserKey(evt.User.Key),
 			User:         ef.userFilter.scrubUser(evt.User),
 		}
 	case IndexEvent:
@@ -149,11 +212,56 @@ func (ef eventOutputFormatter) makeOutputEvent(evt interface{}) interface{} {
 			CreationDate: evt.BaseEvent.CreationDate,
 			User:         ef.userFilter.scrubUser(evt.User),
 		}
+	case AliasEvent:
+		return aliasEventOutput{
+			Kind:                AliasEventKind,
+			CreationDate:        evt.CreationDate,
+			Key:                 *ef.userFilter.filterUserKey(&evt.Key),
+			ContextKind:         evt.ContextKind,
+			PreviousKey:         *ef.userFilter.filterUserKey(&evt.PreviousKey),
+			PreviousContextKind: evt.PreviousContextKind,
+		}
 	default:
 		return nil
 	}
 }
 
+// When an event refers to a user only by key, LaunchDarkly needs to be told if that user is anonymous,
+// since it can't see the user's properties. We omit the property for non-anonymous users, which is
+// the default.
+func anonymousContextKindOrEmpty(user User) string {
+	if user.GetAnonymous() {
+		return anonymousUserContextKind
+	}
+	return ""
+}
+
+func (ef eventOutputFormatter) makeMetricSummaryEvent(m *metricAggregate) metricSummaryEventOutput {
+	me := metricSummaryEventOutput{
+		Kind:      MetricSummaryEventKind,
+		StartDate: m.startDate,
+		EndDate:   m.endDate,
+		Key:       m.eventKey,
+		Count:     m.count,
+		Sum:       m.sum,
+		Min:       m.min,
+		Max:       m.max,
+	}
+	if ef.inlineUsers {
+		me.User = ef.userFilter.scrubUser(m.user)
+	} else {
+		me.UserKey = ef.userFilter.filterUserKey(m.user.Key)
+		me.ContextKind = anonymousContextKindOrEmpty(m.user)
+	}
+	if m.histogram != nil {
+		me.Histogram = &metricHistogramOutput{
+			Bounds: m.bounds,
+			Counts: m.histogram,
+		}
+	}
+	return me
+}
+
 // Transforms the summary data into the format used for event sending.
 func (ef eventOutputFormatter) makeSummaryEvent(snapshot eventSummary) summaryEventOutput {
 	features := make(map[string]flagSummaryData, len(snapshot.counters))
@@ -181,6 +289,10 @@ func (ef eventOutputFormatter) makeSummaryEvent(snapshot eventSummary) summaryEv
 			version := key.version
 			data.Version = &version
 		}
+		if key.reason.Kind != "" {
+			reason := key.reason
+			data.Reason = &reason
+		}
 		flagData.Counters = append(flagData.Counters, data)
 		features[key.key] = flagData
 	}
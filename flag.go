@@ -209,8 +209,15 @@ type EvalResult struct {
 //
 // Deprecated: this method is for internal use and will be moved to another package in a future version.
 func (f FeatureFlag) EvaluateDetail(user User, store FeatureStore, sendReasonsInEvents bool) (EvaluationDetail, []FeatureRequestEvent) {
+	return f.evaluateDetail(user, store, sendReasonsInEvents, false)
+}
+
+// Same as EvaluateDetail, but if summarizeReasons is true, the prerequisite events will also carry the
+// evaluation reason for use in summary counters (see Config.SummarizeEvaluationReasons).
+func (f FeatureFlag) evaluateDetail(user User, store FeatureStore, sendReasonsInEvents bool,
+	summarizeReasons bool) (EvaluationDetail, []FeatureRequestEvent) {
 	if f.On {
-		prereqErrorReason, prereqEvents := f.checkPrerequisites(user, store, sendReasonsInEvents)
+		prereqErrorReason, prereqEvents := f.checkPrerequisites(user, store, sendReasonsInEvents, summarizeReasons)
 		if prereqErrorReason != nil {
 			return f.getOffValue(prereqErrorReason), prereqEvents
 		}
@@ -255,7 +262,8 @@ func (f FeatureFlag) EvaluateExplain(user User, store FeatureStore) (*EvalResult
 }
 
 // Returns nil if all prerequisites are OK, otherwise constructs an error reason that describes the failure
-func (f FeatureFlag) checkPrerequisites(user User, store FeatureStore, sendReasonsInEvents bool) (EvaluationReason, []FeatureRequestEvent) {
+func (f FeatureFlag) checkPrerequisites(user User, store FeatureStore, sendReasonsInEvents bool,
+	summarizeReasons bool) (EvaluationReason, []FeatureRequestEvent) {
 	if len(f.Prerequisites) == 0 {
 		return nil, nil
 	}
@@ -269,7 +277,7 @@ func (f FeatureFlag) checkPrerequisites(user User, store FeatureStore, sendReaso
 		prereqFeatureFlag, _ := data.(*FeatureFlag)
 		prereqOK := true
 
-		prereqResult, moreEvents := prereqFeatureFlag.EvaluateDetail(user, store, sendReasonsInEvents)
+		prereqResult, moreEvents := prereqFeatureFlag.evaluateDetail(user, store, sendReasonsInEvents, summarizeReasons)
 		if !prereqFeatureFlag.On || prereqResult.VariationIndex == nil || *prereqResult.VariationIndex != prereq.Variation {
 			// Note that if the prerequisite flag is off, we don't consider it a match no matter what its
 			// off variation was. But we still need to evaluate it in order to generate an event.
@@ -282,6 +290,9 @@ func (f FeatureFlag) checkPrerequisites(user User, store FeatureStore, sendReaso
 		if sendReasonsInEvents {
 			prereqEvent.Reason.Reason = prereqResult.Reason
 		}
+		if summarizeReasons {
+			prereqEvent.summaryReason = prereqResult.Reason
+		}
 		events = append(events, prereqEvent)
 
 		if !prereqOK {
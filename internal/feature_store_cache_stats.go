@@ -0,0 +1,17 @@
+package internal
+
+// FeatureStoreCacheStats contains cumulative counts of lookups in a feature store's in-memory cache.
+type FeatureStoreCacheStats struct {
+	// The number of queries that were answered from the cache.
+	Hits int64
+	// The number of queries that had to be passed to the underlying data store.
+	Misses int64
+}
+
+// FeatureStoreCacheStatsProvider is an optional interface that can be implemented by a FeatureStore
+// that has an in-memory cache. It allows the SDK to report the effectiveness of the cache in
+// diagnostic data.
+type FeatureStoreCacheStatsProvider interface {
+	// GetCacheStats returns the cache statistics since the store was created.
+	GetCacheStats() FeatureStoreCacheStats
+}
@@ -0,0 +1,17 @@
+package internal
+
+import "time"
+
+// FeatureStoreHeartbeatProvider is an optional interface that can be implemented by a FeatureStore
+// for a data store that is shared between processes. It allows the process that updates the store to
+// record that the data is still current, so that processes which only read from the store can tell
+// how old the data is.
+type FeatureStoreHeartbeatProvider interface {
+	// IsHeartbeatSupported returns false if the underlying data store cannot record heartbeats.
+	IsHeartbeatSupported() bool
+	// WriteHeartbeat records the time when the data was last known to be current.
+	WriteHeartbeat(t time.Time) error
+	// ReadHeartbeat returns the time that was most recently written by WriteHeartbeat, or a zero time
+	// if there is none.
+	ReadHeartbeat() (time.Time, error)
+}
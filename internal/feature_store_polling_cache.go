@@ -0,0 +1,15 @@
+package internal
+
+// FeatureStorePollingCacheProvider is an optional interface that can be implemented by a FeatureStore
+// for a data store that is shared between processes. It allows the SDK to save the last response from
+// the LaunchDarkly polling service, so that other processes, or the same process after a restart, can
+// make a conditional request instead of downloading and parsing all of the data again.
+type FeatureStorePollingCacheProvider interface {
+	// IsPollingCacheSupported returns false if the underlying data store cannot save the response.
+	IsPollingCacheSupported() bool
+	// WritePollingCache saves the response data, replacing any previous data.
+	WritePollingCache(data []byte) error
+	// ReadPollingCache returns the data that was most recently written by WritePollingCache, or nil if
+	// there is none.
+	ReadPollingCache() ([]byte, error)
+}
@@ -6,6 +6,7 @@
 package ldclient
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -13,6 +14,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strings"
 	"time"
@@ -32,6 +34,9 @@ type LDClient struct {
 	eventProcessor  EventProcessor
 	updateProcessor UpdateProcessor
 	store           FeatureStore
+	debugUsers      debugUserMatcher
+	diagnostics     *diagnosticsManager
+	heartbeat       *storeHeartbeat
 }
 
 // Logger is a generic logger interface.
@@ -81,6 +86,8 @@ func MakeCustomClient(sdkKey string, config Config, waitFor time.Duration) (*LDC
 	closeWhenReady := make(chan struct{})
 
 	config.BaseUri = strings.TrimRight(config.BaseUri, "/")
+	config.BaseUris = trimTrailingSlashes(config.BaseUris)
+	config.StreamUris = trimTrailingSlashes(config.StreamUris)
 	config.EventsUri = strings.TrimRight(config.EventsUri, "/")
 	if config.PollInterval < MinimumPollInterval {
 		config.PollInterval = MinimumPollInterval
@@ -112,17 +119,30 @@ func MakeCustomClient(sdkKey string, config Config, waitFor time.Duration) (*LDC
 		config.FeatureStore = store
 	}
 
+	if config.SnapshotFile != "" {
+		config.snapshot = newSnapshotFile(config.SnapshotFile, config.Loggers, newDataFilter(config))
+		loadSnapshotIfAppropriate(config)
+	}
+
 	defaultHTTPClient := config.newHTTPClient()
 
-	client := LDClient{
-		sdkKey: sdkKey,
-		config: config,
-		store:  config.FeatureStore,
+	// The diagnostics manager is created even if DiagnosticOptOut is set, so that GetDiagnostics works;
+	// the event processor is responsible for not sending the data in that case.
+	diagnostics := newDiagnosticsManager(newDiagnosticId(sdkKey), config, waitFor, time.Now(), nil)
+	config.diagnosticsManager = diagnostics
+
+	// Timing store reads has a cost on every evaluation, so we only do it if diagnostics are enabled.
+	store := config.FeatureStore
+	if !config.DiagnosticOptOut {
+		store = newLatencyTrackingFeatureStore(store, diagnostics.storeLatency)
 	}
 
-	if !config.DiagnosticOptOut && config.SendEvents && !config.Offline {
-		id := newDiagnosticId(sdkKey)
-		config.diagnosticsManager = newDiagnosticsManager(id, config, waitFor, time.Now(), nil)
+	client := LDClient{
+		sdkKey:      sdkKey,
+		config:      config,
+		store:       store,
+		debugUsers:  newDebugUserMatcher(config),
+		diagnostics: diagnostics,
 	}
 
 	if config.EventProcessor != nil {
@@ -146,6 +166,16 @@ func MakeCustomClient(sdkKey string, config Config, waitFor time.Duration) (*LDC
 			return nil, err
 		}
 	}
+	if config.FeatureStoreHeartbeatInterval > 0 && (!config.Offline || config.OfflineUseFeatureStore) {
+		isReader := config.UseLdd || config.Offline
+		client.heartbeat = newStoreHeartbeat(config, isReader, client.isUpdateProcessorCurrent)
+		if client.heartbeat != nil {
+			client.heartbeat.start()
+			if p, ok := client.updateProcessor.(*lddUpdateProcessor); ok {
+				p.heartbeat = client.heartbeat
+			}
+		}
+	}
 	client.updateProcessor.Start(closeWhenReady)
 	if waitFor > 0 && !config.Offline && !config.UseLdd {
 		config.Loggers.Infof("Waiting up to %d milliseconds for LaunchDarkly client to start...",
@@ -174,6 +204,17 @@ func MakeCustomClient(sdkKey string, config Config, waitFor time.Duration) (*LDC
 	}
 }
 
+func trimTrailingSlashes(uris []string) []string {
+	if len(uris) == 0 {
+		return uris
+	}
+	ret := make([]string, len(uris))
+	for i, uri := range uris {
+		ret[i] = strings.TrimRight(uri, "/")
+	}
+	return ret
+}
+
 func createDefaultUpdateProcessor(httpClient *http.Client) func(string, Config) (UpdateProcessor, error) {
 	return func(sdkKey string, config Config) (UpdateProcessor, error) {
 		if config.Offline {
@@ -182,10 +223,16 @@ func createDefaultUpdateProcessor(httpClient *http.Client) func(string, Config)
 		}
 		if config.UseLdd {
 			config.Loggers.Info("Started LaunchDarkly client in LDD mode")
+			if config.LddChangeDetectionInterval > 0 {
+				return newLddUpdateProcessor(config), nil
+			}
 			return nullUpdateProcessor{}, nil
 		}
 		requestor := newRequestor(sdkKey, config, httpClient)
 		if config.Stream {
+			if config.StreamFallbackFailureCount > 0 || config.StreamFallbackTimeout > 0 {
+				return newStreamingWithFallbackProcessor(sdkKey, config, requestor), nil
+			}
 			return newStreamProcessor(sdkKey, config, requestor), nil
 		}
 		config.Loggers.Warn("You should only disable the streaming API if instructed to do so by LaunchDarkly support")
@@ -204,6 +251,20 @@ func (client *LDClient) Identify(user User) error {
 	return nil
 }
 
+// Alias associates two users for analytics purposes, so that LaunchDarkly can merge the history of
+// previousUser (typically an anonymous user) into that of user (typically the same person after they
+// have logged in).
+//
+// This only sends an alias event; it does not affect flag evaluation.
+func (client *LDClient) Alias(user User, previousUser User) error {
+	if user.Key == nil || *user.Key == "" || previousUser.Key == nil || *previousUser.Key == "" {
+		client.config.Loggers.Warn("Alias called with empty/nil user key!")
+		return nil // Don't return an error value, for consistency with Identify and Track
+	}
+	client.eventProcessor.SendEvent(NewAliasEvent(user, previousUser))
+	return nil
+}
+
 // TrackEvent reports that a user has performed an event.
 //
 // The eventName parameter is defined by the application and will be shown in analytics reports;
@@ -232,6 +293,21 @@ func (client *LDClient) TrackData(eventName string, user User, data ldvalue.Valu
 	return nil
 }
 
+// TrackDataUrgent is the same as TrackData, but the event processor starts a flush as soon as it
+// receives the event, rather than waiting for the next flush interval. Use this for events that should
+// reach LaunchDarkly promptly, such as business-critical conversions, especially in short-lived
+// processes. Urgent events are not subject to Config.EventSampling or Config.UserEventsLimit.
+func (client *LDClient) TrackDataUrgent(eventName string, user User, data ldvalue.Value) error {
+	if user.Key == nil || *user.Key == "" {
+		client.config.Loggers.Warn("Track called with empty/nil user key!")
+		return nil // Don't return an error value because we didn't in the past and it might confuse users
+	}
+	evt := newCustomEvent(eventName, user, data, false, 0)
+	evt.urgent = true
+	client.eventProcessor.SendEvent(evt)
+	return nil
+}
+
 // TrackMetric reports that a user has performed an event, and associates it with a numeric value.
 // This value is used by the LaunchDarkly experimentation feature in numeric custom metrics, and will also
 // be returned as part of the custom event for Data Export.
@@ -242,11 +318,19 @@ func (client *LDClient) TrackData(eventName string, user User, data ldvalue.Valu
 //
 // The data parameter is a value of any JSON type, represented with the ldvalue.Value type, that
 // will be sent with the event. If no such value is needed, use ldvalue.Null().
+//
+// The metric value must be a finite number; if it is NaN or infinite, which cannot be represented in
+// JSON, a warning is logged and no event is sent.
 func (client *LDClient) TrackMetric(eventName string, user User, metricValue float64, data ldvalue.Value) error {
 	if user.Key == nil || *user.Key == "" {
 		client.config.Loggers.Warn("Track called with empty/nil user key!")
 		return nil // Don't return an error value because we didn't in the past and it might confuse users
 	}
+	if math.IsNaN(metricValue) || math.IsInf(metricValue, 0) {
+		client.config.Loggers.Warnf("TrackMetric called with invalid metric value %v for event %q; event will not be sent",
+			metricValue, eventName)
+		return nil
+	}
 	client.eventProcessor.SendEvent(newCustomEvent(eventName, user, data, true, metricValue))
 	return nil
 }
@@ -305,11 +389,14 @@ func (client *LDClient) Initialized() bool {
 // been sent.
 func (client *LDClient) Close() error {
 	client.config.Loggers.Info("Closing LaunchDarkly client")
-	if client.IsOffline() {
+	if client.IsOffline() && !client.config.OfflineUseFeatureStore {
 		return nil
 	}
 	_ = client.eventProcessor.Close()
 	_ = client.updateProcessor.Close()
+	if client.heartbeat != nil {
+		client.heartbeat.close()
+	}
 	if c, ok := client.store.(io.Closer); ok { // not all FeatureStores implement Closer
 		_ = c.Close()
 	}
@@ -323,6 +410,127 @@ func (client *LDClient) Flush() {
 	client.eventProcessor.Flush()
 }
 
+// FlushAndWait is the same as Flush, but blocks until all of the events that were pending at the time
+// of the call have been delivered, or until the context is done. This is useful in short-lived processes
+// such as serverless functions, which need to be sure that events have been sent before they exit.
+//
+// It returns ctx.Err() if the context was done first, or an EventFlushError if any payload of events
+// could not be delivered. If the client is not sending events, or is using a custom EventProcessor,
+// this calls Flush and returns nil immediately.
+func (client *LDClient) FlushAndWait(ctx context.Context) error {
+	if f, ok := client.eventProcessor.(eventFlusher); ok {
+		return f.flushAndWait(ctx)
+	}
+	client.eventProcessor.Flush()
+	return nil
+}
+
+// GetEvaluationReasonCounts returns the number of flag evaluations that produced each variation of each
+// flag for each evaluation reason, since the client was started. These are cumulative totals; to find out
+// how many evaluations matched a rule within some period, compare the counts from two calls.
+//
+// Counts are only kept if Config.SummarizeEvaluationReasons is enabled and the client is sending events;
+// otherwise this returns nil. Evaluations are counted as the event processor receives them, so the most
+// recent evaluations may not be included yet.
+func (client *LDClient) GetEvaluationReasonCounts() []EvaluationReasonCount {
+	if c, ok := client.eventProcessor.(evaluationReasonCounter); ok {
+		return c.getEvaluationReasonCounts()
+	}
+	return nil
+}
+
+// EventStats returns statistics about the activity of the event processor since the client was started,
+// such as how many events were processed and dropped, and the outcome of the most recent flush. These
+// are cumulative totals, unlike the diagnostic events that the SDK sends to LaunchDarkly.
+//
+// If the client is not sending events, or is using a custom EventProcessor, this returns a zero value.
+func (client *LDClient) EventStats() EventStats {
+	if p, ok := client.eventProcessor.(eventStatsProvider); ok {
+		return p.getEventStats()
+	}
+	return EventStats{}
+}
+
+// GetDiagnostics returns information about the client's configuration and state: the same information
+// that the SDK periodically sends to LaunchDarkly unless Config.DiagnosticOptOut is set, plus the state
+// of the data store (its type, the number of flags and segments, read latency, and cache effectiveness),
+// connection statistics for the streaming service, and the result of EventStats.
+//
+// This is intended for applications that want to export SDK metrics to their own monitoring systems.
+// Note that for a persistent data store, counting the flags and segments may require a database query
+// if the store's cache has expired.
+func (client *LDClient) GetDiagnostics() Diagnostics {
+	d := client.diagnostics.GetDiagnostics()
+	d.Events = client.EventStats()
+	return d
+}
+
+// SubscribeEvents returns a subscription that receives a copy of every analytics event that the
+// client sends to LaunchDarkly, after private user attributes have been removed. Events are delivered
+// when they are flushed, just before they are posted. Use the filter to restrict the subscription to
+// particular event kinds or keys; a zero EventFilter matches all events.
+//
+// If the subscriber does not read from the channel quickly enough, some events will not be delivered
+// to it; this does not affect what is sent to LaunchDarkly. Call Close on the subscription when it is
+// no longer needed. If the client is not sending events (because it is offline, SendEvents is false,
+// or a custom EventProcessor was configured), the channel is closed immediately.
+func (client *LDClient) SubscribeEvents(filter EventFilter) EventSubscription {
+	if s, ok := client.eventProcessor.(eventSubscriber); ok {
+		return s.subscribeEvents(filter)
+	}
+	tap := newEventTap(client.config.Loggers)
+	tap.close()
+	return tap.subscribe(filter)
+}
+
+// GetDataStoreFreshness returns information about how up to date the data in a shared feature store is,
+// based on the heartbeat written by the process that updates the store. The second return value is false
+// if the client is not reading the heartbeat; see Config.FeatureStoreHeartbeatInterval.
+func (client *LDClient) GetDataStoreFreshness() (DataStoreFreshness, bool) {
+	if client.heartbeat == nil || !client.heartbeat.isReader {
+		return DataStoreFreshness{}, false
+	}
+	return client.heartbeat.getFreshness(time.Now()), true
+}
+
+// Returns true if the update processor is receiving data from LaunchDarkly, as far as we can tell.
+func (client *LDClient) isUpdateProcessorCurrent(now time.Time) bool {
+	if p, ok := client.updateProcessor.(connectionHealthProvider); ok {
+		return p.isConnectionHealthy(now)
+	}
+	return client.updateProcessor.Initialized()
+}
+
+// Returns true if Config.UseDefaultsForStaleData is set and the data is stale.
+func (client *LDClient) isDataStale() bool {
+	return client.config.UseDefaultsForStaleData && client.heartbeat != nil && client.heartbeat.isReader &&
+		client.heartbeat.isStale(time.Now())
+}
+
+// SubscribeDataChanges returns a subscription that receives an event whenever the client detects that a
+// feature flag or segment has been added, updated, or deleted. Currently, this is supported only in daemon
+// mode (Config.UseLdd) with Config.LddChangeDetectionInterval set; otherwise, the channel is closed
+// immediately. If the subscriber does not read from the channel quickly enough, some events will not be
+// delivered to it. Call Close on the subscription when it is no longer needed.
+func (client *LDClient) SubscribeDataChanges() DataChangeSubscription {
+	if s, ok := client.updateProcessor.(dataChangeSubscriber); ok {
+		return s.subscribeDataChanges()
+	}
+	b := newDataChangeBroadcaster(client.config.Loggers)
+	b.close()
+	return b.subscribe()
+}
+
+// GetDaemonModeStatus returns the state of change detection in daemon mode, which tells you whether the
+// data in the feature store may be out of date. The second return value is false if the client is not
+// in daemon mode with Config.LddChangeDetectionInterval set.
+func (client *LDClient) GetDaemonModeStatus() (DaemonModeStatus, bool) {
+	if p, ok := client.updateProcessor.(*lddUpdateProcessor); ok {
+		return p.getStatus(), true
+	}
+	return DaemonModeStatus{}, false
+}
+
 // AllFlags returns a map from feature flag keys to values for
 // a given user. If the result of the flag's evaluation would
 // result in the default value, `nil` will be returned. This method
@@ -344,13 +552,16 @@ func (client *LDClient) AllFlags(user User) map[string]interface{} {
 // from a back-end service.
 func (client *LDClient) AllFlagsState(user User, options ...FlagsStateOption) FeatureFlagsState {
 	valid := true
-	if client.IsOffline() {
+	if client.IsOffline() && !client.config.OfflineUseFeatureStore {
 		client.config.Loggers.Warn("Called AllFlagsState in offline mode. Returning empty state")
 		valid = false
 	} else if user.Key == nil {
 		client.config.Loggers.Warn("Called AllFlagsState with nil user key. Returning empty state")
 		valid = false
-	} else if !client.Initialized() {
+	} else if client.isDataStale() {
+		client.config.Loggers.Warn("Called AllFlagsState while feature store data is stale. Returning empty state")
+		valid = false
+	} else if !client.Initialized() || (client.IsOffline() && !client.store.Initialized()) {
 		if client.store.Initialized() {
 			client.config.Loggers.Warn("Called AllFlagsState before client initialization; using last known values from feature store")
 		} else {
@@ -507,10 +718,11 @@ func (client *LDClient) JSONVariationDetail(key string, user User, defaultVal ld
 
 // Generic method for evaluating a feature flag for a given user.
 func (client *LDClient) variation(key string, user User, defaultVal ldvalue.Value, checkType bool, sendReasonsInEvents bool) (EvaluationDetail, error) {
-	if client.IsOffline() {
+	if client.IsOffline() && !client.config.OfflineUseFeatureStore {
 		return NewEvaluationError(defaultVal, EvalErrorClientNotReady), nil
 	}
-	result, flag, err := client.evaluateInternal(key, user, defaultVal, sendReasonsInEvents)
+	debugUser := client.debugUsers.matches(user)
+	result, flag, err := client.evaluateInternal(key, user, defaultVal, sendReasonsInEvents, debugUser)
 	if err != nil {
 		result.Value = defaultVal.UnsafeArbitraryValue() //nolint // allow deprecated usage
 		result.JSONValue = defaultVal
@@ -528,6 +740,10 @@ func (client *LDClient) variation(key string, user User, defaultVal ldvalue.Valu
 		evt = newSuccessfulEvalEvent(flag, user, result.VariationIndex, result.JSONValue, defaultVal,
 			result.Reason, sendReasonsInEvents, nil)
 	}
+	if client.config.SummarizeEvaluationReasons {
+		evt.summaryReason = result.Reason
+	}
+	evt.debugUser = &debugUser
 	client.eventProcessor.SendEvent(evt)
 
 	return result, err
@@ -537,13 +753,15 @@ func (client *LDClient) variation(key string, user User, defaultVal ldvalue.Valu
 //
 // Deprecated: Use one of the Variation methods (JSONVariation if you do not need a specific type).
 func (client *LDClient) Evaluate(key string, user User, defaultVal interface{}) (interface{}, *int, error) {
-	result, _, err := client.evaluateInternal(key, user, ldvalue.UnsafeUseArbitraryValue(defaultVal), false) //nolint // allow deprecated usage
-	return result.JSONValue.UnsafeArbitraryValue(), result.VariationIndex, err                               //nolint // allow deprecated usage
+	debugUser := client.debugUsers.matches(user)
+	result, _, err := client.evaluateInternal(key, user, ldvalue.UnsafeUseArbitraryValue(defaultVal), false, debugUser) //nolint // allow deprecated usage
+	return result.JSONValue.UnsafeArbitraryValue(), result.VariationIndex, err                                          //nolint // allow deprecated usage
 }
 
 // Performs all the steps of evaluation except for sending the feature request event (the main one;
 // events for prerequisites will be sent).
-func (client *LDClient) evaluateInternal(key string, user User, defaultVal ldvalue.Value, sendReasonsInEvents bool) (EvaluationDetail, *FeatureFlag, error) {
+func (client *LDClient) evaluateInternal(key string, user User, defaultVal ldvalue.Value, sendReasonsInEvents bool,
+	debugUser bool) (EvaluationDetail, *FeatureFlag, error) {
 	if user.Key != nil && *user.Key == "" {
 		client.config.Loggers.Warnf("User.Key is blank when evaluating flag: %s. Flag evaluation will proceed, but the user will not be stored in LaunchDarkly.", key)
 	}
@@ -560,13 +778,18 @@ func (client *LDClient) evaluateInternal(key string, user User, defaultVal ldval
 		return detail, flag, err
 	}
 
-	if !client.Initialized() {
+	// An offline client is always considered initialized, but if it is using the feature store, the store
+	// might not have been populated yet
+	if !client.Initialized() || (client.IsOffline() && !client.store.Initialized()) {
 		if client.store.Initialized() {
 			client.config.Loggers.Warn("Feature flag evaluation called before LaunchDarkly client initialization completed; using last known values from feature store")
 		} else {
 			return evalErrorResult(EvalErrorClientNotReady, nil, ErrClientNotInitialized)
 		}
 	}
+	if client.isDataStale() {
+		return evalErrorResult(EvalErrorClientNotReady, nil, ErrStaleData)
+	}
 
 	data, storeErr := client.store.Get(Features, key)
 
@@ -592,7 +815,8 @@ func (client *LDClient) evaluateInternal(key string, user User, defaultVal ldval
 			fmt.Errorf("user.Key cannot be nil when evaluating flag: %s. Returning default value", key))
 	}
 
-	detail, prereqEvents := feature.EvaluateDetail(user, client.store, sendReasonsInEvents)
+	detail, prereqEvents := feature.evaluateDetail(user, client.store, sendReasonsInEvents,
+		client.config.SummarizeEvaluationReasons)
 	if detail.Reason != nil && detail.Reason.GetKind() == EvalReasonError && client.config.LogEvaluationErrors {
 		client.config.Loggers.Warnf("flag evaluation for %s failed with error %s, default value was returned",
 			key, detail.Reason.GetErrorKind())
@@ -602,6 +826,7 @@ func (client *LDClient) evaluateInternal(key string, user User, defaultVal ldval
 		detail.JSONValue = defaultVal
 	}
 	for _, event := range prereqEvents {
+		event.debugUser = &debugUser
 		client.eventProcessor.SendEvent(event)
 	}
 	return detail, feature, nil
@@ -26,6 +26,8 @@ func makeMalformedFlag(key string) *FeatureFlag {
 	return &FeatureFlag{Key: key, On: false, OffVariation: intPtr(-1)}
 }
 
+var notDebugUser = false
+
 func assertEvalEvent(t *testing.T, client *LDClient, flag *FeatureFlag, user User, value ldvalue.Value,
 	variation int, defaultVal ldvalue.Value, reason EvaluationReason) {
 	events := client.eventProcessor.(*testEventProcessor).events
@@ -36,12 +38,14 @@ func assertEvalEvent(t *testing.T, client *LDClient, flag *FeatureFlag, user Use
 			CreationDate: e.CreationDate,
 			User:         user,
 		},
-		Key:       flag.Key,
-		Version:   &flag.Version,
-		Value:     value.UnsafeArbitraryValue(),
-		Variation: intPtr(variation),
-		Default:   defaultVal.UnsafeArbitraryValue(),
-		Reason:    EvaluationReasonContainer{reason},
+		Key:        flag.Key,
+		Version:    &flag.Version,
+		Value:      value.UnsafeArbitraryValue(),
+		Variation:  intPtr(variation),
+		Default:    defaultVal.UnsafeArbitraryValue(),
+		Reason:     EvaluationReasonContainer{reason},
+		debugUser:  &notDebugUser,
+		evalReason: evalReasonFallthroughInstance,
 	}
 	assert.Equal(t, expectedEvent, e)
 }
@@ -449,6 +453,57 @@ func TestEventTrackingAndReasonCanBeForcedForRule(t *testing.T) {
 	assert.Equal(t, newEvalReasonRuleMatch(0, "rule-id"), e.Reason.Reason)
 }
 
+func TestSummaryReasonIsSetOnlyIfEnabled(t *testing.T) {
+	flag0 := makeTestFlag("flag0", 1, "a", "b")
+	flag0.Prerequisites = []Prerequisite{
+		Prerequisite{Key: "flag1", Variation: 1},
+	}
+	flag1 := makeTestFlag("flag1", 1, "c", "d")
+
+	for _, enabled := range []bool{false, true} {
+		client := makeTestClientWithConfig(func(c *Config) { c.SummarizeEvaluationReasons = enabled })
+		client.store.Upsert(Features, flag0)
+		client.store.Upsert(Features, flag1)
+
+		_, err := client.StringVariation(flag0.Key, evalTestUser, "x")
+		assert.NoError(t, err)
+
+		events := client.eventProcessor.(*testEventProcessor).events
+		if assert.Equal(t, 2, len(events)) {
+			for _, e := range events {
+				if enabled {
+					assert.Equal(t, evalReasonFallthroughInstance, e.(FeatureRequestEvent).summaryReason)
+				} else {
+					assert.Nil(t, e.(FeatureRequestEvent).summaryReason)
+				}
+				assert.Nil(t, e.(FeatureRequestEvent).Reason.Reason)
+			}
+		}
+		client.Close()
+	}
+}
+
+func TestEventsRecordWhetherUserIsSelectedForDebugging(t *testing.T) {
+	flag := makeTestFlag("flag", 1, "a", "b")
+	client := makeTestClientWithConfig(func(c *Config) { c.DebugEventsUserKeys = []string{*evalTestUser.Key} })
+	defer client.Close()
+	client.store.Upsert(Features, flag)
+
+	_, err := client.StringVariation(flag.Key, evalTestUser, "x")
+	assert.NoError(t, err)
+	_, err = client.StringVariation(flag.Key, NewUser("otherKey"), "x")
+	assert.NoError(t, err)
+
+	events := client.eventProcessor.(*testEventProcessor).events
+	if assert.Equal(t, 2, len(events)) {
+		e0, e1 := events[0].(FeatureRequestEvent), events[1].(FeatureRequestEvent)
+		assert.True(t, *e0.debugUser)
+		assert.Nil(t, e0.Reason.Reason) // the reason is only added to the debug event
+		assert.Equal(t, evalReasonFallthroughInstance, e0.evalReason)
+		assert.False(t, *e1.debugUser)
+	}
+}
+
 func TestEventTrackingAndReasonAreNotForcedIfFlagIsNotSetForMatchingRule(t *testing.T) {
 	flag := FeatureFlag{
 		Key: "flagKey",
@@ -580,12 +635,14 @@ func TestEvaluatingUnknownFlagSendsEvent(t *testing.T) {
 			CreationDate: e.CreationDate,
 			User:         evalTestUser,
 		},
-		Key:       "flagKey",
-		Version:   nil,
-		Value:     "x",
-		Variation: nil,
-		Default:   "x",
-		PrereqOf:  nil,
+		Key:        "flagKey",
+		Version:    nil,
+		Value:      "x",
+		Variation:  nil,
+		Default:    "x",
+		PrereqOf:   nil,
+		debugUser:  &notDebugUser,
+		evalReason: newEvalReasonError(EvalErrorFlagNotFound),
 	}
 	assert.Equal(t, expectedEvent, e)
 }
@@ -608,12 +665,14 @@ func TestEvaluatingFlagWithNilUserKeySendsEvent(t *testing.T) {
 			CreationDate: e.CreationDate,
 			User:         evalTestUserWithNilKey,
 		},
-		Key:       flag.Key,
-		Version:   &flag.Version,
-		Value:     "x",
-		Variation: nil,
-		Default:   "x",
-		PrereqOf:  nil,
+		Key:        flag.Key,
+		Version:    &flag.Version,
+		Value:      "x",
+		Variation:  nil,
+		Default:    "x",
+		PrereqOf:   nil,
+		debugUser:  &notDebugUser,
+		evalReason: newEvalReasonError(EvalErrorUserNotSpecified),
 	}
 	assert.Equal(t, expectedEvent, e)
 }
@@ -643,12 +702,14 @@ func TestEvaluatingFlagWithPrerequisiteSendsPrerequisiteEvent(t *testing.T) {
 			CreationDate: e0.CreationDate,
 			User:         user,
 		},
-		Key:       flag1.Key,
-		Version:   &flag1.Version,
-		Value:     "d",
-		Variation: intPtr(1),
-		Default:   nil,
-		PrereqOf:  &flag0.Key,
+		Key:        flag1.Key,
+		Version:    &flag1.Version,
+		Value:      "d",
+		Variation:  intPtr(1),
+		Default:    nil,
+		PrereqOf:   &flag0.Key,
+		debugUser:  &notDebugUser,
+		evalReason: evalReasonFallthroughInstance,
 	}
 	assert.Equal(t, expected0, e0)
 
@@ -658,12 +719,14 @@ func TestEvaluatingFlagWithPrerequisiteSendsPrerequisiteEvent(t *testing.T) {
 			CreationDate: e1.CreationDate,
 			User:         user,
 		},
-		Key:       flag0.Key,
-		Version:   &flag0.Version,
-		Value:     "b",
-		Variation: intPtr(1),
-		Default:   "x",
-		PrereqOf:  nil,
+		Key:        flag0.Key,
+		Version:    &flag0.Version,
+		Value:      "b",
+		Variation:  intPtr(1),
+		Default:    "x",
+		PrereqOf:   nil,
+		debugUser:  &notDebugUser,
+		evalReason: evalReasonFallthroughInstance,
 	}
 	assert.Equal(t, expected1, e1)
 }
@@ -114,3 +114,54 @@ func TestAllFlagsReturnsNilOffline(t *testing.T) {
 	result := client.AllFlags(evalTestUser)
 	assert.Nil(t, result)
 }
+
+func makeOfflineClientWithStore(store FeatureStore) *LDClient {
+	config := Config{
+		BaseUri:                "https://localhost:3000",
+		Offline:                true,
+		OfflineUseFeatureStore: true,
+		FeatureStore:           store,
+		Logger:                 newMockLogger(""),
+	}
+	client, _ := MakeCustomClient("api_key", config, 0)
+	return client
+}
+
+func TestVariationEvaluatesFromFeatureStoreOffline(t *testing.T) {
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(map[string]*FeatureFlag{
+		"flag": {Key: "flag", OffVariation: intPtr(1), Variations: []interface{}{"a", "b"}},
+	}, nil))
+	client := makeOfflineClientWithStore(store)
+	defer client.Close()
+
+	assert.True(t, client.Initialized())
+	value, detail, err := client.StringVariationDetail("flag", evalTestUser, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", value)
+	assert.Equal(t, evalReasonOffInstance, detail.Reason)
+
+	state := client.AllFlagsState(evalTestUser)
+	assert.True(t, state.IsValid())
+	assert.Equal(t, "b", state.GetFlagValue("flag"))
+}
+
+func TestVariationReturnsClientNotReadyOfflineIfFeatureStoreIsNotInitialized(t *testing.T) {
+	client := makeOfflineClientWithStore(NewInMemoryFeatureStore(nil))
+	defer client.Close()
+
+	value, detail, err := client.StringVariationDetail("flag", evalTestUser, "default")
+	assert.Error(t, err)
+	assert.Equal(t, "default", value)
+	assert.Equal(t, newEvalReasonError(EvalErrorClientNotReady), detail.Reason)
+	assert.False(t, client.AllFlagsState(evalTestUser).IsValid())
+}
+
+func TestOfflineClientUsingFeatureStoreSendsNoEvents(t *testing.T) {
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(map[string]*FeatureFlag{"flag": {Key: "flag"}}, nil))
+	client := makeOfflineClientWithStore(store)
+	defer client.Close()
+
+	assert.IsType(t, &nullEventProcessor{}, client.eventProcessor)
+}
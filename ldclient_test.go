@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"strings"
 	"testing"
 	"time"
@@ -105,6 +106,33 @@ func TestIdentifyWithEmptyUserKeySendsNoEvent(t *testing.T) {
 	assert.Equal(t, 0, len(events))
 }
 
+func TestAliasSendsAliasEvent(t *testing.T) {
+	client := makeTestClient()
+	defer client.Close()
+
+	err := client.Alias(NewUser("userKey"), NewAnonymousUser("anonKey"))
+	assert.NoError(t, err)
+
+	events := client.eventProcessor.(*testEventProcessor).events
+	assert.Equal(t, 1, len(events))
+	e := events[0].(AliasEvent)
+	assert.Equal(t, "userKey", e.Key)
+	assert.Equal(t, "user", e.ContextKind)
+	assert.Equal(t, "anonKey", e.PreviousKey)
+	assert.Equal(t, "anonymousUser", e.PreviousContextKind)
+}
+
+func TestAliasWithEmptyUserKeySendsNoEvent(t *testing.T) {
+	client := makeTestClient()
+	defer client.Close()
+
+	err := client.Alias(NewUser("userKey"), NewUser(""))
+	assert.NoError(t, err) // we don't return an error for this, we just log it
+
+	events := client.eventProcessor.(*testEventProcessor).events
+	assert.Equal(t, 0, len(events))
+}
+
 func TestTrackEventSendsCustomEvent(t *testing.T) {
 	client := makeTestClient()
 	defer client.Close()
@@ -142,6 +170,23 @@ func TestTrackDataSendsCustomEventWithData(t *testing.T) {
 	assert.Nil(t, e.MetricValue)
 }
 
+func TestTrackDataUrgentSendsUrgentCustomEvent(t *testing.T) {
+	client := makeTestClient()
+	defer client.Close()
+
+	user := NewUser("userKey")
+	data := ldvalue.String("purchase")
+	err := client.TrackDataUrgent("eventKey", user, data)
+	assert.NoError(t, err)
+
+	events := client.eventProcessor.(*testEventProcessor).events
+	assert.Equal(t, 1, len(events))
+	e := events[0].(CustomEvent)
+	assert.Equal(t, "eventKey", e.Key)
+	assert.Equal(t, data.AsArbitraryValue(), e.Data)
+	assert.True(t, e.urgent)
+}
+
 func TestTrackMetricSendsCustomEventWithMetricAndData(t *testing.T) {
 	client := makeTestClient()
 	defer client.Close()
@@ -162,6 +207,23 @@ func TestTrackMetricSendsCustomEventWithMetricAndData(t *testing.T) {
 	assert.Equal(t, &metric, e.MetricValue)
 }
 
+func TestTrackMetricDoesNotSendEventForNonFiniteValue(t *testing.T) {
+	for _, metric := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		t.Run(fmt.Sprintf("%v", metric), func(t *testing.T) {
+			logger := newMockLogger("WARN:")
+			client := makeTestClientWithConfig(func(c *Config) { c.Logger = logger })
+			defer client.Close()
+
+			err := client.TrackMetric("eventKey", NewUser("userKey"), metric, ldvalue.Null())
+			assert.NoError(t, err)
+
+			assert.Equal(t, 0, len(client.eventProcessor.(*testEventProcessor).events))
+			assert.Equal(t, []string{fmt.Sprintf(`WARN: TrackMetric called with invalid metric value %v for event "eventKey"; event will not be sent`,
+				metric)}, logger.output)
+		})
+	}
+}
+
 func TestDeprecatedTrackSendsCustomEvent(t *testing.T) {
 	client := makeTestClient()
 	defer client.Close()
@@ -284,6 +346,60 @@ func TestMakeCustomClient_WithFailedInitialization(t *testing.T) {
 	assert.Equal(t, err, ErrInitializationFailed)
 }
 
+func TestGetDiagnosticsDescribesClientState(t *testing.T) {
+	client := makeTestClientWithConfig(nil)
+	defer client.Close()
+	_ = client.store.Upsert(Features, &FeatureFlag{Key: "flag", On: false, OffVariation: intPtr(0),
+		Variations: []interface{}{true}})
+	_ = client.store.Upsert(Segments, &Segment{Key: "segment"})
+	value, _ := client.BoolVariation("flag", evalTestUser, false)
+	assert.True(t, value)
+
+	d := client.GetDiagnostics()
+	assert.NotEqual(t, "", d.DiagnosticID)
+	assert.Equal(t, "go-server-sdk", d.SDK.Name)
+	assert.Equal(t, ldvalue.NewOptionalString("memory"), d.Configuration.DataStoreType)
+	assert.Equal(t, ldvalue.NewOptionalString("memory"), d.DataStore.Type)
+	assert.Equal(t, 1, d.DataStore.FlagCount)
+	assert.Equal(t, 1, d.DataStore.SegmentCount)
+	assert.Equal(t, int64(1), d.DataStore.ReadCount)
+	assert.Equal(t, EventStats{}, d.Events) // testEventProcessor does not provide stats
+}
+
+func TestGetDiagnosticsDoesNotTrackStoreReadsIfDiagnosticsAreDisabled(t *testing.T) {
+	store := NewInMemoryFeatureStore(nil)
+	client := makeTestClientWithConfig(func(c *Config) {
+		c.FeatureStore = store
+		c.DiagnosticOptOut = true
+	})
+	defer client.Close()
+	_ = store.Upsert(Features, &FeatureFlag{Key: "flag", On: false, OffVariation: intPtr(0),
+		Variations: []interface{}{true}})
+	value, _ := client.BoolVariation("flag", evalTestUser, false)
+	assert.True(t, value)
+
+	assert.Equal(t, store, client.store)
+	d := client.GetDiagnostics()
+	assert.Equal(t, 1, d.DataStore.FlagCount)
+	assert.Equal(t, int64(0), d.DataStore.ReadCount)
+}
+
+func TestGetDiagnosticsJSONPropertiesAreCamelCase(t *testing.T) {
+	client := makeTestClientWithConfig(func(c *Config) { c.DiagnosticOptOut = true })
+	defer client.Close()
+
+	d := jsonMap(client.GetDiagnostics())
+	assert.Equal(t, "go-server-sdk", d["sdk"].(map[string]interface{})["name"])
+	assert.Equal(t, "memory", d["dataStore"].(map[string]interface{})["type"])
+	var eventsKeys []string
+	for key := range d["events"].(map[string]interface{}) {
+		eventsKeys = append(eventsKeys, key)
+	}
+	assert.ElementsMatch(t, []string{"inboxDepth", "queueDepth", "eventsProcessed", "eventsDropped",
+		"deduplicatedUsers", "flushesAttempted", "flushesSucceeded", "lastFlushLatencyNanos", "lastHttpStatus",
+		"bytesSent"}, eventsKeys)
+}
+
 func makeTestClient() *LDClient {
 	return makeTestClientWithConfig(nil)
 }
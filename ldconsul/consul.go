@@ -24,6 +24,7 @@ package ldconsul
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -39,6 +40,11 @@ import (
 // to store, are stored as individual items with the key "{prefix}/features/{flag-key}",
 // "{prefix}/segments/{segment-key}", etc.
 // - The special key "{prefix}/$inited" indicates that the store contains a complete data set.
+// - The special key "{prefix}/$heartbeat" contains the time, in Unix milliseconds, when the data
+// was last known to be current (see ldclient.Config.FeatureStoreHeartbeatInterval).
+// - The special key "{prefix}/$pollingCache" contains the last response from the polling service
+// (see ldclient.Config.PollCacheInFeatureStore). Consul limits values to 512KB by default, so this
+// cannot be saved for a very large data set.
 // - Since Consul has limited support for transactions (they can't contain more than 64
 // operations), the Init method-- which replaces the entire data store-- is not guaranteed to
 // be atomic, so there can be a race condition if another process is adding new data via
@@ -58,7 +64,9 @@ const (
 )
 
 const (
-	initedKey = "$inited"
+	initedKey       = "$inited"
+	heartbeatKey    = "$heartbeat"
+	pollingCacheKey = "$pollingCache"
 )
 
 type featureStoreOptions struct {
@@ -312,7 +320,7 @@ func (store *featureStore) InitCollectionsInternal(allData []utils.StoreCollecti
 
 	// Now delete any previously existing items whose keys were not in the current data
 	for k, v := range oldKeys {
-		if v && k != store.initedKey() {
+		if v && k != store.initedKey() && k != store.heartbeatKey() && k != store.pollingCacheKey() {
 			op := &c.KVTxnOp{Verb: c.KVDelete, Key: k}
 			ops = append(ops, op)
 		}
@@ -393,6 +401,41 @@ func (store *featureStore) IsStoreAvailable() bool {
 	return err == nil
 }
 
+func (store *featureStore) WriteHeartbeatInternal(t time.Time) error {
+	kv := store.client.KV()
+	millis := t.UnixNano() / int64(time.Millisecond)
+	_, err := kv.Put(&c.KVPair{Key: store.heartbeatKey(), Value: []byte(strconv.FormatInt(millis, 10))}, nil)
+	return err
+}
+
+func (store *featureStore) ReadHeartbeatInternal() (time.Time, error) {
+	kv := store.client.KV()
+	pair, _, err := kv.Get(store.heartbeatKey(), nil)
+	if err != nil || pair == nil {
+		return time.Time{}, err
+	}
+	millis, err := strconv.ParseInt(string(pair.Value), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid heartbeat value: %s", err)
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)), nil
+}
+
+func (store *featureStore) WritePollingCacheInternal(data []byte) error {
+	kv := store.client.KV()
+	_, err := kv.Put(&c.KVPair{Key: store.pollingCacheKey(), Value: data}, nil)
+	return err
+}
+
+func (store *featureStore) ReadPollingCacheInternal() ([]byte, error) {
+	kv := store.client.KV()
+	pair, _, err := kv.Get(store.pollingCacheKey(), nil)
+	if err != nil || pair == nil {
+		return nil, err
+	}
+	return pair.Value, nil
+}
+
 // Used internally to describe this component in diagnostic data.
 func (store *featureStore) GetDiagnosticsComponentTypeName() string {
 	return "Consul"
@@ -453,3 +496,11 @@ func (store *featureStore) featureKeyFor(kind ld.VersionedDataKind, k string) st
 func (store *featureStore) initedKey() string {
 	return store.options.prefix + "/" + initedKey
 }
+
+func (store *featureStore) heartbeatKey() string {
+	return store.options.prefix + "/" + heartbeatKey
+}
+
+func (store *featureStore) pollingCacheKey() string {
+	return store.options.prefix + "/" + pollingCacheKey
+}
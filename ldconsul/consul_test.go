@@ -27,6 +27,14 @@ func TestConsulFeatureStorePrefixes(t *testing.T) {
 		}, clearExistingData)
 }
 
+func TestConsulFeatureStoreHeartbeat(t *testing.T) {
+	ldtest.RunFeatureStoreHeartbeatTests(t, makeConsulStoreWithCacheTTL(0), clearExistingData)
+}
+
+func TestConsulFeatureStorePollingCache(t *testing.T) {
+	ldtest.RunFeatureStorePollingCacheTests(t, makeConsulStoreWithCacheTTL(0), clearExistingData)
+}
+
 func TestConsulFeatureStoreConcurrentModification(t *testing.T) {
 	options, _ := validateOptions()
 	store1Core, err := newConsulFeatureStoreInternal(options, ld.Config{}) // we need the underlying implementation object so we can set testTxHook
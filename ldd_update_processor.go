@@ -0,0 +1,168 @@
+package ldclient
+
+import (
+	"sync"
+	"time"
+)
+
+// DaemonModeStatus describes the state of change detection in daemon mode. See LDClient.GetDaemonModeStatus.
+type DaemonModeStatus struct {
+	// LastScanTime is the time when the client last tried to read the data from the feature store, or
+	// zero if it has not done so yet.
+	LastScanTime time.Time
+	// LastChangeTime is the time when the client last detected a change in the data. Until it has seen
+	// a change, this is the time of the first successful scan.
+	LastChangeTime time.Time
+	// LastError is the error from the most recent scan, or nil if it succeeded.
+	LastError error
+	// Stale is true if the most recent scan failed, or if the Relay Proxy may have stopped updating the
+	// store. If Config.FeatureStoreHeartbeatInterval is set, the latter is based on the store's heartbeat,
+	// as described for Config.FeatureStoreMaxDataAge. Otherwise, it is true if Config.LddStaleAfter is set
+	// and no changes have been detected for that long; this may just mean that nobody has changed any flags.
+	Stale bool
+}
+
+// Optional interface implemented by FeatureStores, such as utils.FeatureStoreWrapper, that cache data from
+// an underlying store. RefreshAll bypasses the cache, and replaces the cached data with what it read.
+type featureStoreCacheRefresher interface {
+	RefreshAll(kind VersionedDataKind) (map[string]VersionedData, error)
+}
+
+// An UpdateProcessor for daemon mode (Config.UseLdd), in which the Relay Proxy populates the feature
+// store. Instead of receiving data, it scans the store every Config.LddChangeDetectionInterval and
+// compares the item versions with the previous scan, so that it can report changes.
+type lddUpdateProcessor struct {
+	store       FeatureStore
+	config      Config
+	interval    time.Duration
+	versions    map[VersionedDataKind]map[string]int // versions from the last scan; nil before the first one
+	changes     *dataChangeBroadcaster
+	heartbeat   *storeHeartbeat // set by LDClient before Start, if it reads the store's heartbeat
+	status      DaemonModeStatus
+	staleLogged bool
+	lock        sync.Mutex
+	quit        chan struct{}
+	closeOnce   sync.Once
+}
+
+func newLddUpdateProcessor(config Config) *lddUpdateProcessor {
+	return &lddUpdateProcessor{
+		store:    config.FeatureStore,
+		config:   config,
+		interval: config.LddChangeDetectionInterval,
+		changes:  newDataChangeBroadcaster(config.Loggers),
+		quit:     make(chan struct{}),
+	}
+}
+
+// In daemon mode, the client is always considered initialized, since there is nothing to wait for.
+func (p *lddUpdateProcessor) Initialized() bool {
+	return true
+}
+
+func (p *lddUpdateProcessor) Start(closeWhenReady chan<- struct{}) {
+	close(closeWhenReady)
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		p.scan(time.Now())
+		for {
+			select {
+			case <-p.quit:
+				return
+			case <-ticker.C:
+				p.scan(time.Now())
+			}
+		}
+	}()
+}
+
+func (p *lddUpdateProcessor) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.quit)
+		p.changes.close()
+	})
+	return nil
+}
+
+func (p *lddUpdateProcessor) subscribeDataChanges() DataChangeSubscription {
+	return p.changes.subscribe()
+}
+
+func (p *lddUpdateProcessor) getStatus() DaemonModeStatus {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.status
+}
+
+// Reads all of the data from the store and publishes an event for each item that was added, updated, or
+// deleted since the last scan. The first scan only records the versions.
+func (p *lddUpdateProcessor) scan(now time.Time) {
+	versions := make(map[VersionedDataKind]map[string]int)
+	var err error
+	for _, kind := range []VersionedDataKind{Features, Segments} {
+		var items map[string]VersionedData
+		if r, ok := p.store.(featureStoreCacheRefresher); ok {
+			items, err = r.RefreshAll(kind)
+		} else {
+			items, err = p.store.All(kind)
+		}
+		if err != nil {
+			break
+		}
+		versions[kind] = make(map[string]int, len(items))
+		for key, item := range items {
+			versions[kind][key] = item.GetVersion()
+		}
+	}
+
+	var changes []DataChangeEvent
+	if err == nil && p.versions != nil {
+		changes = compareVersions(p.versions, versions)
+	}
+
+	p.lock.Lock()
+	p.status.LastScanTime = now
+	p.status.LastError = err
+	if err == nil {
+		if p.versions == nil || len(changes) > 0 {
+			p.status.LastChangeTime = now
+		}
+		p.versions = versions
+	}
+	lastChangeTime := p.status.LastChangeTime
+	// If the store has a heartbeat, that tells us whether the Relay Proxy is still updating it, so we don't
+	// need to guess based on how long it has been since a change. The heartbeat reader logs its own warning.
+	unchanged := p.heartbeat == nil && p.config.LddStaleAfter > 0 && now.Sub(lastChangeTime) >= p.config.LddStaleAfter
+	p.status.Stale = err != nil || unchanged || (p.heartbeat != nil && p.heartbeat.isStale(now))
+	p.lock.Unlock()
+
+	if err != nil {
+		p.config.Loggers.Warnf("Unable to read flag data from feature store: %s", err)
+	} else if unchanged && !p.staleLogged {
+		p.config.Loggers.Warnf("Flag data in feature store has not changed since %s; the Relay Proxy may have stopped updating it",
+			lastChangeTime.Format(time.RFC3339))
+	} else if !unchanged && p.staleLogged {
+		p.config.Loggers.Info("Flag data in feature store has changed")
+	}
+	p.staleLogged = unchanged && err == nil
+	p.changes.publish(changes)
+}
+
+// Returns an event for each item whose version differs between the two scans.
+func compareVersions(oldVersions, newVersions map[VersionedDataKind]map[string]int) []DataChangeEvent {
+	var changes []DataChangeEvent
+	for _, kind := range []VersionedDataKind{Features, Segments} {
+		for key, version := range newVersions[kind] {
+			if oldVersion, ok := oldVersions[kind][key]; !ok || oldVersion != version {
+				changes = append(changes, DataChangeEvent{Kind: kind, Key: key, Version: version})
+			}
+		}
+		for key := range oldVersions[kind] {
+			if _, ok := newVersions[kind][key]; !ok {
+				changes = append(changes, DataChangeEvent{Kind: kind, Key: key})
+			}
+		}
+	}
+	return changes
+}
@@ -0,0 +1,250 @@
+package ldclient
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeLddTestProcessor(store FeatureStore, modConfig func(*Config)) *lddUpdateProcessor {
+	config := Config{
+		FeatureStore:               store,
+		Logger:                     newMockLogger(""),
+		UseLdd:                     true,
+		LddChangeDetectionInterval: time.Hour,
+	}
+	if modConfig != nil {
+		modConfig(&config)
+	}
+	return newLddUpdateProcessor(config)
+}
+
+func readDataChanges(sub DataChangeSubscription) []DataChangeEvent {
+	var ret []DataChangeEvent
+	for {
+		select {
+		case e := <-sub.Channel():
+			ret = append(ret, e)
+		default:
+			sort.Slice(ret, func(i, j int) bool { return ret[i].Key < ret[j].Key })
+			return ret
+		}
+	}
+}
+
+func TestLddUpdateProcessorReportsChangedItems(t *testing.T) {
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(
+		map[string]*FeatureFlag{
+			"flag1": {Key: "flag1", Version: 1},
+			"flag2": {Key: "flag2", Version: 1},
+			"flag3": {Key: "flag3", Version: 1},
+		},
+		map[string]*Segment{"segment1": {Key: "segment1", Version: 1}},
+	))
+	p := makeLddTestProcessor(store, nil)
+	defer p.Close()
+	sub := p.subscribeDataChanges()
+
+	p.scan(time.Now())
+	assert.Nil(t, readDataChanges(sub)) // the first scan only records the versions
+
+	_ = store.Upsert(Features, &FeatureFlag{Key: "flag2", Version: 2})
+	_ = store.Delete(Features, "flag3", 2)
+	_ = store.Upsert(Features, &FeatureFlag{Key: "flag4", Version: 1})
+	_ = store.Upsert(Segments, &Segment{Key: "segment1", Version: 2})
+	p.scan(time.Now())
+
+	assert.Equal(t, []DataChangeEvent{
+		{Kind: Features, Key: "flag2", Version: 2},
+		{Kind: Features, Key: "flag3"},
+		{Kind: Features, Key: "flag4", Version: 1},
+		{Kind: Segments, Key: "segment1", Version: 2},
+	}, readDataChanges(sub))
+
+	p.scan(time.Now())
+	assert.Nil(t, readDataChanges(sub))
+}
+
+func TestLddUpdateProcessorReportsStaleDataIfNothingChanges(t *testing.T) {
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(map[string]*FeatureFlag{"flag": {Key: "flag", Version: 1}}, nil))
+	p := makeLddTestProcessor(store, func(c *Config) { c.LddStaleAfter = time.Minute })
+	defer p.Close()
+	start := time.Now()
+
+	p.scan(start)
+	status := p.getStatus()
+	assert.Equal(t, start, status.LastScanTime)
+	assert.Equal(t, start, status.LastChangeTime)
+	assert.False(t, status.Stale)
+
+	p.scan(start.Add(time.Minute))
+	assert.True(t, p.getStatus().Stale)
+
+	_ = store.Upsert(Features, &FeatureFlag{Key: "flag", Version: 2})
+	p.scan(start.Add(2 * time.Minute))
+	status = p.getStatus()
+	assert.Equal(t, start.Add(2*time.Minute), status.LastChangeTime)
+	assert.False(t, status.Stale)
+}
+
+func TestLddUpdateProcessorUsesHeartbeatToDetermineStalenessIfAvailable(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	_ = store.Init(MakeAllVersionedDataMap(map[string]*FeatureFlag{"flag": {Key: "flag", Version: 1}}, nil))
+	config := makeHeartbeatTestConfig(store)
+	p := makeLddTestProcessor(store, func(c *Config) {
+		c.LddStaleAfter = time.Minute
+		c.FeatureStoreHeartbeatInterval = config.FeatureStoreHeartbeatInterval
+		c.FeatureStoreMaxDataAge = config.FeatureStoreMaxDataAge
+	})
+	defer p.Close()
+	p.heartbeat = newStoreHeartbeat(p.config, true, nil)
+	require.NotNil(t, p.heartbeat)
+	start := time.Now()
+
+	// nothing has changed for longer than LddStaleAfter, but the heartbeat is recent
+	store.heartbeat = start.Add(2 * time.Minute)
+	p.heartbeat.update(start.Add(2 * time.Minute))
+	p.scan(start)
+	p.scan(start.Add(2 * time.Minute))
+	status := p.getStatus()
+	assert.Equal(t, start, status.LastChangeTime)
+	assert.False(t, status.Stale)
+
+	// the heartbeat is now older than FeatureStoreMaxDataAge, although something has changed
+	_ = store.Upsert(Features, &FeatureFlag{Key: "flag", Version: 2})
+	p.scan(start.Add(4 * time.Minute))
+	status = p.getStatus()
+	assert.Equal(t, start.Add(4*time.Minute), status.LastChangeTime)
+	assert.True(t, status.Stale)
+}
+
+func TestLddUpdateProcessorReportsStoreError(t *testing.T) {
+	storeErr := errors.New("sorry")
+	store := &testFeatureStoreWithError{FeatureStore: NewInMemoryFeatureStore(nil)}
+	p := makeLddTestProcessor(store, nil)
+	defer p.Close()
+	sub := p.subscribeDataChanges()
+
+	p.scan(time.Now())
+	store.err = storeErr
+	p.scan(time.Now())
+
+	status := p.getStatus()
+	assert.Equal(t, storeErr, status.LastError)
+	assert.True(t, status.Stale)
+	assert.Nil(t, readDataChanges(sub))
+}
+
+func TestLddUpdateProcessorRefreshesCache(t *testing.T) {
+	store := &testFeatureStoreWithRefresh{FeatureStore: NewInMemoryFeatureStore(nil)}
+	p := makeLddTestProcessor(store, nil)
+	defer p.Close()
+
+	p.scan(time.Now())
+	assert.Equal(t, []VersionedDataKind{Features, Segments}, store.refreshed)
+}
+
+func TestLddUpdateProcessorScansPeriodically(t *testing.T) {
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(nil, nil))
+	p := makeLddTestProcessor(store, func(c *Config) { c.LddChangeDetectionInterval = 10 * time.Millisecond })
+	defer p.Close()
+	sub := p.subscribeDataChanges()
+	closeWhenReady := make(chan struct{})
+	p.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for p.getStatus().LastScanTime.IsZero() {
+		require.True(t, time.Now().Before(deadline), "timed out waiting for first scan")
+		time.Sleep(5 * time.Millisecond)
+	}
+	_ = store.Upsert(Features, &FeatureFlag{Key: "flag", Version: 1})
+
+	select {
+	case e := <-sub.Channel():
+		assert.Equal(t, DataChangeEvent{Kind: Features, Key: "flag", Version: 1}, e)
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "timed out waiting for change event")
+	}
+
+	p.Close()
+	_, ok := <-sub.Channel()
+	assert.False(t, ok)
+}
+
+func TestClientUsesLddUpdateProcessorOnlyIfChangeDetectionIsEnabled(t *testing.T) {
+	client := makeTestClientWithConfig(func(c *Config) {
+		c.UseLdd = true
+		c.UpdateProcessorFactory = nil
+	})
+	defer client.Close()
+	_, ok := client.GetDaemonModeStatus()
+	assert.False(t, ok)
+	_, open := <-client.SubscribeDataChanges().Channel()
+	assert.False(t, open)
+
+	client = makeTestClientWithConfig(func(c *Config) {
+		c.UseLdd = true
+		c.LddChangeDetectionInterval = time.Hour
+		c.UpdateProcessorFactory = nil
+	})
+	defer client.Close()
+	_, ok = client.GetDaemonModeStatus()
+	assert.True(t, ok)
+	assert.True(t, client.Initialized())
+}
+
+func TestClientInDaemonModeReportsStaleDataBasedOnHeartbeat(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	store.heartbeat = time.Now().Add(-time.Hour)
+	client := makeTestClientWithConfig(func(c *Config) {
+		c.FeatureStore = store
+		c.UseLdd = true
+		c.LddChangeDetectionInterval = time.Hour
+		c.UpdateProcessorFactory = nil
+		c.FeatureStoreHeartbeatInterval = time.Hour
+		c.FeatureStoreMaxDataAge = time.Minute
+	})
+	defer client.Close()
+
+	// the first scan happens asynchronously after the client starts
+	deadline := time.Now().Add(time.Second)
+	for {
+		status, _ := client.GetDaemonModeStatus()
+		if !status.LastScanTime.IsZero() {
+			assert.True(t, status.Stale)
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "timed out waiting for first scan")
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+type testFeatureStoreWithError struct {
+	FeatureStore
+	err error
+}
+
+func (s *testFeatureStoreWithError) All(kind VersionedDataKind) (map[string]VersionedData, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.FeatureStore.All(kind)
+}
+
+type testFeatureStoreWithRefresh struct {
+	FeatureStore
+	refreshed []VersionedDataKind
+}
+
+func (s *testFeatureStoreWithRefresh) RefreshAll(kind VersionedDataKind) (map[string]VersionedData, error) {
+	s.refreshed = append(s.refreshed, kind)
+	return s.FeatureStore.All(kind)
+}
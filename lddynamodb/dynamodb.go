@@ -86,10 +86,12 @@ const (
 
 const (
 	// Schema of the DynamoDB table
-	tablePartitionKey = "namespace"
-	tableSortKey      = "key"
-	versionAttribute  = "version"
-	itemJSONAttribute = "item"
+	tablePartitionKey     = "namespace"
+	tableSortKey          = "key"
+	versionAttribute      = "version"
+	itemJSONAttribute     = "item"
+	heartbeatAttribute    = "heartbeat"    // used only in the heartbeat item, instead of itemJSONAttribute
+	pollingCacheAttribute = "pollingCache" // used only in the polling cache item, instead of itemJSONAttribute
 )
 
 type namespaceAndKey struct {
@@ -506,6 +508,72 @@ func (store *dynamoDBFeatureStore) IsStoreAvailable() bool {
 	return err == nil
 }
 
+func (store *dynamoDBFeatureStore) WriteHeartbeatInternal(t time.Time) error {
+	millis := t.UnixNano() / int64(time.Millisecond)
+	_, err := store.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(store.options.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			tablePartitionKey:  {S: aws.String(store.heartbeatKey())},
+			tableSortKey:       {S: aws.String(store.heartbeatKey())},
+			heartbeatAttribute: {N: aws.String(strconv.FormatInt(millis, 10))},
+		},
+	})
+	return err
+}
+
+func (store *dynamoDBFeatureStore) ReadHeartbeatInternal() (time.Time, error) {
+	result, err := store.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(store.options.table),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			tablePartitionKey: {S: aws.String(store.heartbeatKey())},
+			tableSortKey:      {S: aws.String(store.heartbeatKey())},
+		},
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	attr := result.Item[heartbeatAttribute]
+	if attr == nil || attr.N == nil {
+		return time.Time{}, nil
+	}
+	millis, err := strconv.ParseInt(*attr.N, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid heartbeat value: %s", err)
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)), nil
+}
+
+func (store *dynamoDBFeatureStore) WritePollingCacheInternal(data []byte) error {
+	_, err := store.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(store.options.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			tablePartitionKey:     {S: aws.String(store.pollingCacheKey())},
+			tableSortKey:          {S: aws.String(store.pollingCacheKey())},
+			pollingCacheAttribute: {B: data},
+		},
+	})
+	return err
+}
+
+func (store *dynamoDBFeatureStore) ReadPollingCacheInternal() ([]byte, error) {
+	result, err := store.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(store.options.table),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			tablePartitionKey: {S: aws.String(store.pollingCacheKey())},
+			tableSortKey:      {S: aws.String(store.pollingCacheKey())},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if attr := result.Item[pollingCacheAttribute]; attr != nil {
+		return attr.B, nil
+	}
+	return nil, nil
+}
+
 // Used internally to describe this component in diagnostic data.
 func (store *dynamoDBFeatureStore) GetDiagnosticsComponentTypeName() string {
 	return "DynamoDB"
@@ -526,6 +594,14 @@ func (store *dynamoDBFeatureStore) initedKey() string {
 	return store.prefixedNamespace("$inited")
 }
 
+func (store *dynamoDBFeatureStore) heartbeatKey() string {
+	return store.prefixedNamespace("$heartbeat")
+}
+
+func (store *dynamoDBFeatureStore) pollingCacheKey() string {
+	return store.prefixedNamespace("$pollingCache")
+}
+
 func (store *dynamoDBFeatureStore) makeQueryForKind(kind ld.VersionedDataKind) *dynamodb.QueryInput {
 	return &dynamodb.QueryInput{
 		TableName:      aws.String(store.options.table),
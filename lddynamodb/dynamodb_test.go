@@ -44,6 +44,20 @@ func TestDynamoDBFeatureStorePrefixes(t *testing.T) {
 		}, clearExistingData)
 }
 
+func TestDynamoDBFeatureStoreHeartbeat(t *testing.T) {
+	err := createTableIfNecessary()
+	require.NoError(t, err)
+
+	ldtest.RunFeatureStoreHeartbeatTests(t, makeStoreWithCacheTTL(0), clearExistingData)
+}
+
+func TestDynamoDBFeatureStorePollingCache(t *testing.T) {
+	err := createTableIfNecessary()
+	require.NoError(t, err)
+
+	ldtest.RunFeatureStorePollingCacheTests(t, makeStoreWithCacheTTL(0), clearExistingData)
+}
+
 func TestDynamoDBFeatureStoreConcurrentModification(t *testing.T) {
 	opts, _ := validateOptions(testTableName, SessionOptions(makeTestOptions()))
 	store1Internal, err := newDynamoDBFeatureStoreInternal(opts, ld.Config{})
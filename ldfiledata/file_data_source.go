@@ -86,6 +86,7 @@ func UseReloader(reloaderFactory ReloaderFactory) FileDataSourceOption {
 
 type fileDataSource struct {
 	store           ld.FeatureStore
+	ldConfig        ld.Config
 	options         fileDataSourceOptions
 	loggers         ldlog.Loggers
 	isInitialized   bool
@@ -169,6 +170,9 @@ type fileDataSource struct {
 //
 // If the data source encounters any error in any file-- malformed content, a missing file, or a
 // duplicate key-- it will not load flags from any of the files.
+//
+// If Config.StoredFlagKeys, StoredFlagKeyPrefixes, or StoredFlagFilter is set, only the selected flags, and
+// the prerequisites and segments that they depend on, are loaded into the feature store.
 func NewFileDataSourceFactory(options ...FileDataSourceOption) ld.UpdateProcessorFactory {
 	return func(sdkKey string, config ld.Config) (ld.UpdateProcessor, error) {
 		return newFileDataSource(config, options...)
@@ -180,8 +184,9 @@ func newFileDataSource(ldConfig ld.Config, options ...FileDataSourceOption) (*fi
 		return nil, fmt.Errorf("featureStore must not be nil")
 	}
 	fs := &fileDataSource{
-		store:   ldConfig.FeatureStore,
-		loggers: ldConfig.Loggers,
+		store:    ldConfig.FeatureStore,
+		ldConfig: ldConfig,
+		loggers:  ldConfig.Loggers,
 	}
 	for _, o := range options {
 		err := o.apply(&fs.options)
@@ -237,7 +242,7 @@ func (fs *fileDataSource) reload() {
 	}
 	storeData, err := mergeFileData(filesData...)
 	if err == nil {
-		err = fs.store.Init(storeData)
+		err = fs.store.Init(fs.ldConfig.FilterStoredData(storeData))
 		fs.signalStartComplete(true)
 	}
 	if err != nil {
@@ -168,3 +168,24 @@ flagValues:
 	require.True(t, flag.(*ld.FeatureFlag).On)
 	assert.Equal(t, 0, *flag.(*ld.FeatureFlag).Fallthrough.Variation)
 }
+
+func TestNewFileDataSourceAppliesStoredFlagFilter(t *testing.T) {
+	filename := makeTempFile(t, `{"flags": {"my-flag": {"on": true, "prerequisites": [{"key": "prereq"}]},
+"prereq": {"on": true}, "other-flag": {"on": true}}}`)
+	defer os.Remove(filename)
+
+	store := ld.NewInMemoryFeatureStore(nil)
+
+	factory := NewFileDataSourceFactory(FilePaths(filename))
+	dataSource, err := factory("", ld.Config{FeatureStore: store, StoredFlagKeys: []string{"my-flag"}})
+	require.NoError(t, err)
+	closeWhenReady := make(chan struct{})
+	dataSource.Start(closeWhenReady)
+	<-closeWhenReady
+	require.True(t, dataSource.Initialized())
+	flags, err := store.All(ld.Features)
+	require.NoError(t, err)
+	assert.Len(t, flags, 2)
+	assert.NotNil(t, flags["my-flag"])
+	assert.NotNil(t, flags["prereq"])
+}
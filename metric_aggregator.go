@@ -0,0 +1,103 @@
+package ldclient
+
+import (
+	"sort"
+)
+
+// Accumulates statistics for the values passed to TrackMetric, if Config.AggregateMetrics is enabled,
+// so that they can be sent as one event per event key and user in each flush instead of one event per
+// call. Like eventSummarizer, this is not thread-safe, because it is only used from the event
+// processor's single event-processing goroutine. The number of aggregates is limited by eventBuffer,
+// since each one is sent as an event.
+type metricAggregator struct {
+	aggregates      map[metricAggregateKey]*metricAggregate
+	histogramBounds []float64
+}
+
+type metricAggregateKey struct {
+	eventKey string
+	userKey  string
+}
+
+type metricAggregate struct {
+	eventKey  string
+	user      User
+	startDate uint64
+	endDate   uint64
+	count     int
+	sum       float64
+	min       float64
+	max       float64
+	bounds    []float64
+	histogram []int // if bounds is set, the counts for each bucket plus one for values above the last bound
+}
+
+func newMetricAggregator(histogramBounds []float64) metricAggregator {
+	bounds := make([]float64, len(histogramBounds))
+	copy(bounds, histogramBounds)
+	sort.Float64s(bounds)
+	return metricAggregator{
+		aggregates:      make(map[metricAggregateKey]*metricAggregate),
+		histogramBounds: bounds,
+	}
+}
+
+// Returns true if there is already an aggregate for the event key and user of a custom event.
+func (a *metricAggregator) hasAggregateFor(ce CustomEvent) bool {
+	_, ok := a.aggregates[metricAggregateKey{eventKey: ce.Key, userKey: ce.User.GetKey()}]
+	return ok
+}
+
+// Adds the metric value from a custom event to the statistics for its event key and user.
+func (a *metricAggregator) add(ce CustomEvent) {
+	key := metricAggregateKey{eventKey: ce.Key, userKey: ce.User.GetKey()}
+	value := *ce.MetricValue
+	agg, ok := a.aggregates[key]
+	if !ok {
+		agg = &metricAggregate{
+			eventKey:  ce.Key,
+			startDate: ce.CreationDate,
+			min:       value,
+			max:       value,
+		}
+		if len(a.histogramBounds) > 0 {
+			agg.bounds = a.histogramBounds
+			agg.histogram = make([]int, len(a.histogramBounds)+1)
+		}
+		a.aggregates[key] = agg
+	}
+	agg.user = ce.User // the most recent user properties are the ones we'll send, as for an index event
+	agg.count++
+	agg.sum += value
+	if value < agg.min {
+		agg.min = value
+	}
+	if value > agg.max {
+		agg.max = value
+	}
+	if ce.CreationDate < agg.startDate {
+		agg.startDate = ce.CreationDate
+	}
+	if ce.CreationDate > agg.endDate {
+		agg.endDate = ce.CreationDate
+	}
+	if agg.histogram != nil {
+		agg.histogram[sort.SearchFloat64s(a.histogramBounds, value)]++
+	}
+}
+
+// Returns the current aggregates. The aggregator must be reset before it is used again.
+func (a *metricAggregator) snapshot() []*metricAggregate {
+	if len(a.aggregates) == 0 {
+		return nil
+	}
+	ret := make([]*metricAggregate, 0, len(a.aggregates))
+	for _, agg := range a.aggregates {
+		ret = append(ret, agg)
+	}
+	return ret
+}
+
+func (a *metricAggregator) reset() {
+	a.aggregates = make(map[metricAggregateKey]*metricAggregate)
+}
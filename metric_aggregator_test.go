@@ -0,0 +1,77 @@
+package ldclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/launchdarkly/go-sdk-common.v1/ldvalue"
+)
+
+func makeMetricEvent(key string, user User, value float64, creationDate uint64) CustomEvent {
+	ce := newCustomEvent(key, user, ldvalue.Null(), true, value)
+	ce.CreationDate = creationDate
+	return ce
+}
+
+func TestMetricAggregatorComputesStatistics(t *testing.T) {
+	a := newMetricAggregator(nil)
+	a.add(makeMetricEvent("metric", epDefaultUser, 3, 2000))
+	a.add(makeMetricEvent("metric", epDefaultUser, -1, 1000))
+	a.add(makeMetricEvent("metric", epDefaultUser, 10, 3000))
+
+	aggs := a.snapshot()
+	require.Len(t, aggs, 1)
+	m := aggs[0]
+	assert.Equal(t, "metric", m.eventKey)
+	assert.Equal(t, 3, m.count)
+	assert.Equal(t, float64(12), m.sum)
+	assert.Equal(t, float64(-1), m.min)
+	assert.Equal(t, float64(10), m.max)
+	assert.Equal(t, uint64(1000), m.startDate)
+	assert.Equal(t, uint64(3000), m.endDate)
+	assert.Nil(t, m.histogram)
+}
+
+func TestMetricAggregatorKeepsSeparateAggregatesPerKeyAndUser(t *testing.T) {
+	a := newMetricAggregator(nil)
+	user2 := NewUser("otherKey")
+	a.add(makeMetricEvent("metric1", epDefaultUser, 1, 1000))
+	a.add(makeMetricEvent("metric2", epDefaultUser, 1, 1000))
+	a.add(makeMetricEvent("metric1", user2, 1, 1000))
+	a.add(makeMetricEvent("metric1", epDefaultUser, 1, 1000))
+
+	counts := make(map[metricAggregateKey]int)
+	for _, m := range a.snapshot() {
+		counts[metricAggregateKey{m.eventKey, *m.user.Key}] = m.count
+	}
+	assert.Equal(t, map[metricAggregateKey]int{
+		{"metric1", *epDefaultUser.Key}: 2,
+		{"metric2", *epDefaultUser.Key}: 1,
+		{"metric1", "otherKey"}:         1,
+	}, counts)
+}
+
+func TestMetricAggregatorHistogram(t *testing.T) {
+	a := newMetricAggregator([]float64{10, 1, 5})
+	for _, v := range []float64{0, 1, 2, 5, 6, 10, 11, 100} {
+		a.add(makeMetricEvent("metric", epDefaultUser, v, 1000))
+	}
+
+	aggs := a.snapshot()
+	require.Len(t, aggs, 1)
+	assert.Equal(t, []float64{1, 5, 10}, aggs[0].bounds)
+	assert.Equal(t, []int{2, 2, 2, 2}, aggs[0].histogram)
+}
+
+func TestMetricAggregatorHasAggregateFor(t *testing.T) {
+	a := newMetricAggregator(nil)
+	assert.False(t, a.hasAggregateFor(makeMetricEvent("metric1", epDefaultUser, 1, 1000)))
+	a.add(makeMetricEvent("metric1", epDefaultUser, 1, 1000))
+	assert.True(t, a.hasAggregateFor(makeMetricEvent("metric1", epDefaultUser, 2, 2000)))
+	assert.False(t, a.hasAggregateFor(makeMetricEvent("metric2", epDefaultUser, 1, 1000)))
+
+	a.reset()
+	assert.Nil(t, a.snapshot())
+	assert.False(t, a.hasAggregateFor(makeMetricEvent("metric1", epDefaultUser, 1, 1000)))
+}
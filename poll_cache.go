@@ -0,0 +1,143 @@
+package ldclient
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gregjones/httpcache"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v4/internal"
+	"gopkg.in/launchdarkly/go-server-sdk.v4/ldlog"
+)
+
+// The HTTP cache used by the requestor when Config.PollCacheFile or Config.PollCacheInFeatureStore is set.
+// Like the default cache, it keeps every response in memory; it also saves the responses for LatestAllPath,
+// and loads them when it is created, so that the first poll after a restart can be a conditional request.
+// A response is saved only if it has an ETag, and only when the ETag changes. If a response is removed
+// from the memory cache, as happens after an error, it remains saved, since it is still the last response
+// that was known to be good.
+type pollCache struct {
+	*httpcache.MemoryCache
+	storage pollCacheStorage
+	loggers ldlog.Loggers
+	saved   map[string][]byte // the saved responses, by cache key
+	lock    sync.Mutex
+}
+
+// Where pollCache saves the responses.
+type pollCacheStorage interface {
+	read() ([]byte, error) // returns nil if nothing has been saved
+	write(data []byte) error
+	description() string
+}
+
+type pollCacheFile struct {
+	path string
+}
+
+type pollCacheFeatureStore struct {
+	store internal.FeatureStorePollingCacheProvider
+}
+
+// Returns nil if the responses should not be saved.
+func newPollCacheStorage(config Config) pollCacheStorage {
+	if config.PollCacheFile != "" {
+		return pollCacheFile{path: config.PollCacheFile}
+	}
+	if config.PollCacheInFeatureStore {
+		if store, ok := config.FeatureStore.(internal.FeatureStorePollingCacheProvider); ok && store.IsPollingCacheSupported() {
+			return pollCacheFeatureStore{store: store}
+		}
+		config.Loggers.Warn("PollCacheInFeatureStore was set, but the feature store does not support it")
+	}
+	return nil
+}
+
+func newPollCache(storage pollCacheStorage, loggers ldlog.Loggers) *pollCache {
+	c := &pollCache{
+		MemoryCache: httpcache.NewMemoryCache(),
+		storage:     storage,
+		loggers:     loggers,
+		saved:       make(map[string][]byte),
+	}
+	data, err := storage.read()
+	if err == nil && data != nil {
+		err = json.Unmarshal(data, &c.saved)
+	}
+	if err != nil {
+		loggers.Warnf("Unable to load saved polling response from %s: %s", storage.description(), err)
+		c.saved = make(map[string][]byte)
+	}
+	for key, responseBytes := range c.saved {
+		c.MemoryCache.Set(key, responseBytes)
+	}
+	return c
+}
+
+func (c *pollCache) Set(key string, responseBytes []byte) {
+	c.MemoryCache.Set(key, responseBytes)
+	if !strings.HasSuffix(key, LatestAllPath) {
+		return
+	}
+	etag := responseETag(responseBytes)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if etag == "" || etag == responseETag(c.saved[key]) {
+		return
+	}
+	c.saved[key] = responseBytes
+	data, err := json.Marshal(c.saved)
+	if err == nil {
+		err = c.storage.write(data)
+	}
+	if err != nil {
+		c.loggers.Warnf("Unable to save polling response to %s: %s", c.storage.description(), err)
+	}
+}
+
+// Returns the ETag of a response in the format used by httpcache, or "" if there is none.
+func responseETag(responseBytes []byte) string {
+	if responseBytes == nil {
+		return ""
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(responseBytes)), nil)
+	if err != nil {
+		return ""
+	}
+	_ = resp.Body.Close()
+	return resp.Header.Get("ETag")
+}
+
+func (f pollCacheFile) read() ([]byte, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f pollCacheFile) write(data []byte) error {
+	return writeFileAtomically(f.path, data)
+}
+
+func (f pollCacheFile) description() string {
+	return "file " + f.path
+}
+
+func (s pollCacheFeatureStore) read() ([]byte, error) {
+	return s.store.ReadPollingCache()
+}
+
+func (s pollCacheFeatureStore) write(data []byte) error {
+	return s.store.WritePollingCache(data)
+}
+
+func (s pollCacheFeatureStore) description() string {
+	return "feature store"
+}
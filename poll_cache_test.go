@@ -0,0 +1,236 @@
+package ldclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a server that responds to conditional requests with the given ETag with a 304.
+func makePollCacheTestServer(etag string, conditionalRequests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(conditionalRequests, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(`{"flags": {"my-flag": {"key": "my-flag", "version": 1}}, "segments": {}}`))
+	}))
+}
+
+type testPollCacheStorage struct {
+	data   []byte
+	writes int
+}
+
+func (s *testPollCacheStorage) read() ([]byte, error) {
+	return s.data, nil
+}
+
+func (s *testPollCacheStorage) write(data []byte) error {
+	s.data = data
+	s.writes++
+	return nil
+}
+
+func (s *testPollCacheStorage) description() string {
+	return "test storage"
+}
+
+func TestRestartedPollerMakesConditionalRequestWithSavedETag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poll-cache-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var conditionalRequests int32
+	server := makePollCacheTestServer(`"v1"`, &conditionalRequests)
+	defer server.Close()
+	makeConfig := func() Config {
+		return Config{
+			FeatureStore:  NewInMemoryFeatureStore(nil),
+			Loggers:       DefaultConfig.Loggers,
+			BaseUri:       server.URL,
+			PollInterval:  time.Hour,
+			PollCacheFile: filepath.Join(dir, "poll-cache.json"),
+		}
+	}
+
+	config1 := makeConfig()
+	require.NoError(t, newPollingProcessor(config1, newRequestor("sdkKey", config1, nil)).poll())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&conditionalRequests))
+	_, err = os.Stat(config1.PollCacheFile)
+	require.NoError(t, err)
+
+	// a new requestor with an empty store gets the data from the saved response
+	config2 := makeConfig()
+	require.NoError(t, newPollingProcessor(config2, newRequestor("sdkKey", config2, nil)).poll())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conditionalRequests))
+	assert.True(t, config2.FeatureStore.Initialized())
+	flag, _ := config2.FeatureStore.Get(Features, "my-flag")
+	assert.NotNil(t, flag)
+}
+
+func TestRestartedPollerAppliesSavedResponseToStoreLoadedFromSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poll-cache-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var conditionalRequests int32
+	server := makePollCacheTestServer(`"v1"`, &conditionalRequests)
+	defer server.Close()
+	config := Config{
+		FeatureStore:  NewInMemoryFeatureStore(nil),
+		Loggers:       DefaultConfig.Loggers,
+		BaseUri:       server.URL,
+		PollInterval:  time.Hour,
+		PollCacheFile: filepath.Join(dir, "poll-cache.json"),
+	}
+	require.NoError(t, newPollingProcessor(config, newRequestor("sdkKey", config, nil)).poll())
+
+	// the snapshot is older than the saved response
+	config.snapshot = newSnapshotFile(filepath.Join(dir, "snapshot.json"), config.Loggers, nil)
+	config.snapshot.save(allData{Flags: map[string]*FeatureFlag{"old-flag": {Key: "old-flag"}}})
+	config.FeatureStore = NewInMemoryFeatureStore(nil)
+	loadSnapshotIfAppropriate(config)
+	require.True(t, config.snapshot.isStoreDataFromSnapshot())
+
+	require.NoError(t, newPollingProcessor(config, newRequestor("sdkKey", config, nil)).poll())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conditionalRequests))
+	flag, _ := config.FeatureStore.Get(Features, "my-flag")
+	assert.NotNil(t, flag)
+	oldFlag, _ := config.FeatureStore.Get(Features, "old-flag")
+	assert.Nil(t, oldFlag)
+	assert.False(t, config.snapshot.isStoreDataFromSnapshot())
+}
+
+type initCountingFeatureStore struct {
+	FeatureStore
+	inits int
+}
+
+func (s *initCountingFeatureStore) Init(data map[VersionedDataKind]map[string]VersionedData) error {
+	s.inits++
+	return s.FeatureStore.Init(data)
+}
+
+func TestRestartedPollerDoesNotReinitializeStoreThatAlreadyHasData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "poll-cache-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	var conditionalRequests int32
+	server := makePollCacheTestServer(`"v1"`, &conditionalRequests)
+	defer server.Close()
+	store := &initCountingFeatureStore{FeatureStore: NewInMemoryFeatureStore(nil)}
+	config := Config{
+		FeatureStore:  store,
+		Loggers:       DefaultConfig.Loggers,
+		BaseUri:       server.URL,
+		PollInterval:  time.Hour,
+		PollCacheFile: filepath.Join(dir, "poll-cache.json"),
+	}
+	require.NoError(t, newPollingProcessor(config, newRequestor("sdkKey", config, nil)).poll())
+	assert.Equal(t, 1, store.inits)
+
+	// a new poller using the same persistent store
+	require.NoError(t, newPollingProcessor(config, newRequestor("sdkKey", config, nil)).poll())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conditionalRequests))
+	assert.Equal(t, 1, store.inits)
+}
+
+func TestPollerCanSaveResponseInFeatureStore(t *testing.T) {
+	var conditionalRequests int32
+	server := makePollCacheTestServer(`"v1"`, &conditionalRequests)
+	defer server.Close()
+	store := &testFeatureStoreWithPollingCache{FeatureStore: NewInMemoryFeatureStore(nil)}
+	config := Config{
+		FeatureStore:            store,
+		Loggers:                 DefaultConfig.Loggers,
+		BaseUri:                 server.URL,
+		PollCacheInFeatureStore: true,
+	}
+
+	_, _, err := newRequestor("sdkKey", config, nil).requestAll(false)
+	require.NoError(t, err)
+	assert.NotNil(t, store.data)
+
+	data, cached, err := newRequestor("sdkKey", config, nil).requestAll(true)
+	require.NoError(t, err)
+	assert.True(t, cached)
+	assert.NotNil(t, data.Flags["my-flag"])
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conditionalRequests))
+}
+
+func TestPollCacheIsNotUsedIfFeatureStoreDoesNotSupportIt(t *testing.T) {
+	config := Config{FeatureStore: NewInMemoryFeatureStore(nil), PollCacheInFeatureStore: true}
+	assert.Nil(t, newPollCacheStorage(config))
+}
+
+func TestPollCacheSavesResponseOnlyWhenETagChanges(t *testing.T) {
+	storage := &testPollCacheStorage{}
+	cache := newPollCache(storage, DefaultConfig.Loggers)
+	key := "http://localhost" + LatestAllPath
+	makeResponse := func(etag, date string) []byte {
+		return []byte("HTTP/1.1 200 OK\r\nEtag: " + etag + "\r\nDate: " + date + "\r\nContent-Length: 2\r\n\r\n{}")
+	}
+
+	cache.Set(key, makeResponse(`"v1"`, "Mon, 01 Jan 2001 00:00:00 GMT"))
+	cache.Set(key, makeResponse(`"v1"`, "Mon, 01 Jan 2001 00:01:00 GMT"))
+	assert.Equal(t, 1, storage.writes)
+	cache.Set(key, makeResponse(`"v2"`, "Mon, 01 Jan 2001 00:02:00 GMT"))
+	assert.Equal(t, 2, storage.writes)
+
+	cache.Set("http://localhost"+LatestFlagsPath+"/my-flag", makeResponse(`"v3"`, "Mon, 01 Jan 2001 00:03:00 GMT"))
+	assert.Equal(t, 2, storage.writes) // only the full data set is saved
+
+	restored := newPollCache(storage, DefaultConfig.Loggers)
+	response, ok := restored.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, `"v2"`, responseETag(response))
+}
+
+func TestPollIntervalJitter(t *testing.T) {
+	p := newPollingProcessor(Config{PollInterval: time.Minute}, nil)
+	p.randFloat = func() float64 { return 0.5 }
+	assert.Equal(t, time.Minute, p.nextPollDelay())
+
+	p.config.PollIntervalJitterRatio = 0.2
+	assert.Equal(t, 66*time.Second, p.nextPollDelay())
+
+	p.config.PollIntervalJitterRatio = 5
+	assert.Equal(t, 90*time.Second, p.nextPollDelay())
+}
+
+func TestPollingConnectionIsHealthyUntilTwoPollsAreMissed(t *testing.T) {
+	p := newPollingProcessor(Config{PollInterval: time.Minute, PollIntervalJitterRatio: 0.5}, nil)
+	now := time.Now()
+	assert.False(t, p.isConnectionHealthy(now))
+
+	p.lastSuccessfulPoll = now
+	assert.True(t, p.isConnectionHealthy(now.Add(3*time.Minute)))
+	assert.False(t, p.isConnectionHealthy(now.Add(3*time.Minute+time.Second)))
+}
+
+type testFeatureStoreWithPollingCache struct {
+	FeatureStore
+	data []byte
+}
+
+func (s *testFeatureStoreWithPollingCache) IsPollingCacheSupported() bool {
+	return true
+}
+
+func (s *testFeatureStoreWithPollingCache) WritePollingCache(data []byte) error {
+	s.data = data
+	return nil
+}
+
+func (s *testFeatureStoreWithPollingCache) ReadPollingCache() ([]byte, error) {
+	return s.data, nil
+}
@@ -1,6 +1,7 @@
 package ldclient
 
 import (
+	"math/rand"
 	"sync"
 	"time"
 )
@@ -13,6 +14,9 @@ type pollingProcessor struct {
 	isInitialized      bool
 	quit               chan struct{}
 	closeOnce          sync.Once
+	randFloat          func() float64
+	lastSuccessfulPoll time.Time
+	lock               sync.Mutex
 }
 
 func newPollingProcessor(config Config, requestor *requestor) *pollingProcessor {
@@ -21,6 +25,7 @@ func newPollingProcessor(config Config, requestor *requestor) *pollingProcessor
 		requestor: requestor,
 		config:    config,
 		quit:      make(chan struct{}),
+		randFloat: rand.Float64,
 	}
 
 	return pp
@@ -29,10 +34,10 @@ func newPollingProcessor(config Config, requestor *requestor) *pollingProcessor
 func (pp *pollingProcessor) Start(closeWhenReady chan<- struct{}) {
 	pp.config.Loggers.Infof("Starting LaunchDarkly polling with interval: %+v", pp.config.PollInterval)
 
-	ticker := newTickerWithInitialTick(pp.config.PollInterval)
+	timer := time.NewTimer(0) // ensure we do an initial poll immediately
 
 	go func() {
-		defer ticker.Stop()
+		defer timer.Stop()
 
 		var readyOnce sync.Once
 		notifyReady := func() {
@@ -48,7 +53,7 @@ func (pp *pollingProcessor) Start(closeWhenReady chan<- struct{}) {
 			case <-pp.quit:
 				pp.config.Loggers.Info("Polling has been shut down")
 				return
-			case <-ticker.C:
+			case <-timer.C:
 				if err := pp.poll(); err != nil {
 					pp.config.Loggers.Errorf("Error when requesting feature updates: %+v", err)
 					if hse, ok := err.(HttpStatusError); ok {
@@ -58,28 +63,40 @@ func (pp *pollingProcessor) Start(closeWhenReady chan<- struct{}) {
 							return
 						}
 					}
-					continue
+				} else {
+					pp.lock.Lock()
+					pp.lastSuccessfulPoll = time.Now()
+					pp.lock.Unlock()
+					pp.setInitializedOnce.Do(func() {
+						pp.isInitialized = true
+						pp.config.Loggers.Info("First polling request successful")
+						notifyReady()
+					})
 				}
-				pp.setInitializedOnce.Do(func() {
-					pp.isInitialized = true
-					pp.config.Loggers.Info("First polling request successful")
-					notifyReady()
-				})
+				timer.Reset(pp.nextPollDelay())
 			}
 		}
 	}()
 }
 
 func (pp *pollingProcessor) poll() error {
-	allData, cached, err := pp.requestor.requestAll()
+	// If the store has no data, as after a restart with a saved polling response (Config.PollCacheFile), or
+	// it only has data from Config.SnapshotFile, which may be older, we need the data even if it hasn't changed
+	needsData := !pp.store.Initialized() || (pp.config.snapshot != nil && pp.config.snapshot.isStoreDataFromSnapshot())
+	allData, cached, err := pp.requestor.requestAll(needsData)
 
 	if err != nil {
 		return err
 	}
 
-	// We initialize the store only if the request wasn't cached
-	if !cached {
-		return pp.store.Init(MakeAllVersionedDataMap(allData.Flags, allData.Segments))
+	// We initialize the store only if the request wasn't cached, or the store needs the data anyway
+	if !cached || needsData {
+		if err := pp.store.Init(pp.config.FilterStoredData(MakeAllVersionedDataMap(allData.Flags, allData.Segments))); err != nil {
+			return err
+		}
+		if pp.config.snapshot != nil {
+			pp.config.snapshot.save(allData)
+		}
 	}
 	return nil
 }
@@ -95,23 +112,27 @@ func (pp *pollingProcessor) Initialized() bool {
 	return pp.isInitialized
 }
 
-type tickerWithInitialTick struct {
-	*time.Ticker
-	C <-chan time.Time
+// The connection is healthy if the last poll succeeded, or the one before; any more than that, and
+// we have missed at least one update.
+func (pp *pollingProcessor) isConnectionHealthy(now time.Time) bool {
+	pp.lock.Lock()
+	defer pp.lock.Unlock()
+	return !pp.lastSuccessfulPoll.IsZero() && now.Sub(pp.lastSuccessfulPoll) <= 2*pp.maxPollDelay()
+}
+
+// Returns the delay before the next poll: PollInterval, plus a random proportion of up to
+// PollIntervalJitterRatio of that.
+func (pp *pollingProcessor) nextPollDelay() time.Duration {
+	return pp.config.PollInterval + time.Duration(pp.randFloat()*float64(pp.maxPollDelay()-pp.config.PollInterval))
 }
 
-func newTickerWithInitialTick(interval time.Duration) *tickerWithInitialTick {
-	c := make(chan time.Time)
-	ticker := time.NewTicker(interval)
-	t := &tickerWithInitialTick{
-		C:      c,
-		Ticker: ticker,
+func (pp *pollingProcessor) maxPollDelay() time.Duration {
+	ratio := pp.config.PollIntervalJitterRatio
+	if ratio > 1 {
+		ratio = 1
 	}
-	go func() {
-		c <- time.Now() // Ensure we do an initial poll immediately
-		for tt := range ticker.C {
-			c <- tt
-		}
-	}()
-	return t
+	if ratio < 0 {
+		ratio = 0
+	}
+	return pp.config.PollInterval + time.Duration(ratio*float64(pp.config.PollInterval))
 }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,6 +19,7 @@ func TestPollingProcessorClosingItShouldNotBlock(t *testing.T) {
 	server := httptest.NewServer(nullHandler)
 	defer server.Close()
 	cfg := Config{
+		FeatureStore: NewInMemoryFeatureStore(nil),
 		Logger:       log.New(ioutil.Discard, "", 0),
 		PollInterval: time.Minute,
 		BaseUri:      server.URL,
@@ -121,6 +123,7 @@ func TestPollingProcessorRequestResponseCodes(t *testing.T) {
 			defer ts.CloseClientConnections()
 
 			cfg := Config{
+				FeatureStore: NewInMemoryFeatureStore(nil),
 				Logger:       log.New(ioutil.Discard, "", 0),
 				PollInterval: time.Millisecond * 10,
 				BaseUri:      ts.URL,
@@ -187,3 +190,56 @@ func TestPollingProcessorUsesHTTPClientFactory(t *testing.T) {
 
 	assert.Equal(t, "/sdk/latest-all/transformed", polledURL)
 }
+
+func TestRequestorFailsOverToNextEndpoint(t *testing.T) {
+	var primaryRequests int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryRequests, 1)
+		w.WriteHeader(503)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"flags": {"my-flag": {"key": "my-flag", "version": 2}}, "segments": {}}`))
+	}))
+	defer secondary.Close()
+
+	cfg := Config{
+		Logger:   log.New(ioutil.Discard, "", 0),
+		BaseUris: []string{primary.URL, secondary.URL},
+	}
+	req := newRequestor("fake", cfg, nil)
+
+	data, _, err := req.requestAll(false)
+	assert.NoError(t, err)
+	assert.NotNil(t, data.Flags["my-flag"])
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primaryRequests))
+
+	// the failed endpoint is not retried until the failback interval has passed
+	_, _, err = req.requestAll(false)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&primaryRequests))
+}
+
+func TestRequestorDoesNotFailOverOnUnrecoverableError(t *testing.T) {
+	var secondaryRequests int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(401)
+	}))
+	defer primary.Close()
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&secondaryRequests, 1)
+	}))
+	defer secondary.Close()
+
+	cfg := Config{
+		Logger:   log.New(ioutil.Discard, "", 0),
+		BaseUris: []string{primary.URL, secondary.URL},
+	}
+	req := newRequestor("fake", cfg, nil)
+
+	_, _, err := req.requestAll(false)
+	if assert.Error(t, err) {
+		assert.Equal(t, 401, err.(HttpStatusError).Code)
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&secondaryRequests))
+}
@@ -253,7 +253,11 @@ func newPool(url string, dialOptions []r.DialOption) *r.Pool {
 	return pool
 }
 
-const initedKey = "$inited"
+const (
+	initedKey       = "$inited"
+	heartbeatKey    = "$heartbeat"
+	pollingCacheKey = "$pollingCache"
+)
 
 // NewRedisFeatureStoreFromUrl constructs a new Redis-backed feature store connecting to the
 // specified URL. It uses a default connection pool configuration (see package description for details).
@@ -551,6 +555,43 @@ func (store *redisFeatureStoreCore) IsStoreAvailable() bool {
 	return err == nil
 }
 
+func (store *redisFeatureStoreCore) WriteHeartbeatInternal(t time.Time) error {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+	_, err := c.Do("SET", store.heartbeatKey(), t.UnixNano()/int64(time.Millisecond))
+	return err
+}
+
+func (store *redisFeatureStoreCore) ReadHeartbeatInternal() (time.Time, error) {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+	millis, err := r.Int64(c.Do("GET", store.heartbeatKey()))
+	if err == r.ErrNil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)), nil
+}
+
+func (store *redisFeatureStoreCore) WritePollingCacheInternal(data []byte) error {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+	_, err := c.Do("SET", store.pollingCacheKey(), data)
+	return err
+}
+
+func (store *redisFeatureStoreCore) ReadPollingCacheInternal() ([]byte, error) {
+	c := store.getConn()
+	defer c.Close() // nolint:errcheck
+	data, err := r.Bytes(c.Do("GET", store.pollingCacheKey()))
+	if err == r.ErrNil {
+		return nil, nil
+	}
+	return data, err
+}
+
 // Used internally to describe this component in diagnostic data.
 func (store *redisFeatureStoreCore) GetDiagnosticsComponentTypeName() string {
 	return "Redis"
@@ -564,6 +605,14 @@ func (store *redisFeatureStoreCore) initedKey() string {
 	return store.options.prefix + ":" + initedKey
 }
 
+func (store *redisFeatureStoreCore) heartbeatKey() string {
+	return store.options.prefix + ":" + heartbeatKey
+}
+
+func (store *redisFeatureStoreCore) pollingCacheKey() string {
+	return store.options.prefix + ":" + pollingCacheKey
+}
+
 func (store *redisFeatureStoreCore) getConn() r.Conn {
 	return store.pool.Get()
 }
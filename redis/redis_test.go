@@ -51,6 +51,18 @@ func TestRedisFeatureStoreCachedWithDeprecatedConstructor(t *testing.T) {
 	}, clearExistingData, true)
 }
 
+func TestRedisFeatureStoreHeartbeat(t *testing.T) {
+	f, err := NewRedisFeatureStoreFactory(CacheTTL(0))
+	require.NoError(t, err)
+	ldtest.RunFeatureStoreHeartbeatTests(t, f, clearExistingData)
+}
+
+func TestRedisFeatureStorePollingCache(t *testing.T) {
+	f, err := NewRedisFeatureStoreFactory(CacheTTL(0))
+	require.NoError(t, err)
+	ldtest.RunFeatureStorePollingCacheTests(t, f, clearExistingData)
+}
+
 func TestRedisFeatureStorePrefixes(t *testing.T) {
 	ldtest.RunFeatureStorePrefixIndependenceTests(t,
 		func(prefix string) (ld.FeatureStore, error) {
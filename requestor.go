@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gregjones/httpcache"
 )
@@ -20,6 +21,7 @@ type requestor struct {
 	sdkKey     string
 	httpClient *http.Client
 	config     Config
+	endpoints  *endpointSelector
 }
 
 func newRequestor(sdkKey string, config Config, httpClient *http.Client) *requestor {
@@ -29,8 +31,12 @@ func newRequestor(sdkKey string, config Config, httpClient *http.Client) *reques
 	} else {
 		decoratedClient = *config.newHTTPClient()
 	}
+	var cache httpcache.Cache = httpcache.NewMemoryCache()
+	if storage := newPollCacheStorage(config); storage != nil {
+		cache = newPollCache(storage, config.Loggers)
+	}
 	decoratedClient.Transport = &httpcache.Transport{
-		Cache:               httpcache.NewMemoryCache(),
+		Cache:               cache,
 		MarkCachedResponses: true,
 		Transport:           decoratedClient.Transport,
 	}
@@ -39,18 +45,22 @@ func newRequestor(sdkKey string, config Config, httpClient *http.Client) *reques
 		sdkKey:     sdkKey,
 		httpClient: &decoratedClient,
 		config:     config,
+		endpoints:  newEndpointSelector(config.baseUris(), config.EndpointFailbackInterval),
 	}
 
 	return &httpRequestor
 }
 
-func (r *requestor) requestAll() (allData, bool, error) {
+// Requests all flags and segments. The second return value is true if the response came from the HTTP
+// cache, meaning that the data has not changed since the last request; in that case, the data is parsed
+// only if parseIfCached is true.
+func (r *requestor) requestAll(parseIfCached bool) (allData, bool, error) {
 	var data allData
 	body, cached, err := r.makeRequest(LatestAllPath)
 	if err != nil {
 		return allData{}, false, err
 	}
-	if cached {
+	if cached && !parseIfCached {
 		return allData{}, true, nil
 	}
 	jsonErr := json.Unmarshal(body, &data)
@@ -83,9 +93,31 @@ func (r *requestor) requestResource(kind VersionedDataKind, key string) (Version
 	return item, nil
 }
 
+// Makes a request to the most preferred endpoint that is working. If it fails in a way that another
+// endpoint might not, we try the other endpoints in turn.
 func (r *requestor) makeRequest(resource string) ([]byte, bool, error) {
+	var lastErr error
+	for _, baseUri := range r.endpoints.candidates(time.Now()) {
+		body, cached, err := r.makeRequestToEndpoint(baseUri, resource)
+		if err == nil {
+			r.endpoints.markSucceeded(baseUri)
+			return body, cached, nil
+		}
+		if !isEndpointFailure(err) {
+			return nil, false, err
+		}
+		r.endpoints.markFailed(baseUri, time.Now())
+		if len(r.endpoints.uris) > 1 {
+			r.config.Loggers.Warnf("Request to %s failed (%s); trying next endpoint", baseUri, err)
+		}
+		lastErr = err
+	}
+	return nil, false, lastErr
+}
+
+func (r *requestor) makeRequestToEndpoint(baseUri string, resource string) ([]byte, bool, error) {
 	r.config.Loggers.Debug("Polling LaunchDarkly for feature flag updates")
-	req, reqErr := http.NewRequest("GET", r.config.BaseUri+resource, nil)
+	req, reqErr := http.NewRequest("GET", baseUri+resource, nil)
 	if reqErr != nil {
 		return nil, false, reqErr
 	}
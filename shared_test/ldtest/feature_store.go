@@ -5,11 +5,13 @@ package ldtest
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	ld "gopkg.in/launchdarkly/go-server-sdk.v4"
+	"gopkg.in/launchdarkly/go-server-sdk.v4/internal"
 )
 
 // RunFeatureStoreTests runs a suite of tests on a feature store.
@@ -397,3 +399,81 @@ func RunFeatureStoreConcurrentModificationTests(t *testing.T, store1 ld.FeatureS
 		assert.Equal(t, 3, result.GetVersion())
 	})
 }
+
+// RunFeatureStoreHeartbeatTests runs tests of the heartbeat support in a persistent feature store (see
+// ld.Config.FeatureStoreHeartbeatInterval). The store must implement internal.FeatureStoreHeartbeatProvider.
+func RunFeatureStoreHeartbeatTests(t *testing.T, storeFactory ld.FeatureStoreFactory, clearExistingData func() error) {
+	makeStore := func(t *testing.T) internal.FeatureStoreHeartbeatProvider {
+		require.NoError(t, clearExistingData())
+		store, err := storeFactory(ld.Config{})
+		require.NoError(t, err)
+		hb, ok := store.(internal.FeatureStoreHeartbeatProvider)
+		require.True(t, ok)
+		require.True(t, hb.IsHeartbeatSupported())
+		return hb
+	}
+	heartbeatTime := time.Unix(0, 1234567890123*int64(time.Millisecond)) // must be a whole number of milliseconds
+
+	t.Run("heartbeat is zero if never written", func(t *testing.T) {
+		store := makeStore(t)
+		hb, err := store.ReadHeartbeat()
+		require.NoError(t, err)
+		assert.True(t, hb.IsZero())
+	})
+
+	t.Run("heartbeat can be written and read", func(t *testing.T) {
+		store := makeStore(t)
+		require.NoError(t, store.WriteHeartbeat(heartbeatTime))
+		hb, err := store.ReadHeartbeat()
+		require.NoError(t, err)
+		assert.True(t, heartbeatTime.Equal(hb))
+	})
+
+	t.Run("heartbeat is not removed by Init", func(t *testing.T) {
+		store := makeStore(t)
+		require.NoError(t, store.WriteHeartbeat(heartbeatTime))
+		require.NoError(t, store.(ld.FeatureStore).Init(makeMockDataMap(&MockDataItem{Key: "flag", Version: 1})))
+		hb, err := store.ReadHeartbeat()
+		require.NoError(t, err)
+		assert.True(t, heartbeatTime.Equal(hb))
+	})
+}
+
+// RunFeatureStorePollingCacheTests runs tests of saving polling responses in a persistent feature store
+// (see ld.Config.PollCacheInFeatureStore). The store must implement internal.FeatureStorePollingCacheProvider.
+func RunFeatureStorePollingCacheTests(t *testing.T, storeFactory ld.FeatureStoreFactory, clearExistingData func() error) {
+	makeStore := func(t *testing.T) internal.FeatureStorePollingCacheProvider {
+		require.NoError(t, clearExistingData())
+		store, err := storeFactory(ld.Config{})
+		require.NoError(t, err)
+		pc, ok := store.(internal.FeatureStorePollingCacheProvider)
+		require.True(t, ok)
+		require.True(t, pc.IsPollingCacheSupported())
+		return pc
+	}
+	data := []byte(`{"response": "data"}`)
+
+	t.Run("data is nil if never written", func(t *testing.T) {
+		store := makeStore(t)
+		read, err := store.ReadPollingCache()
+		require.NoError(t, err)
+		assert.Nil(t, read)
+	})
+
+	t.Run("data can be written and read", func(t *testing.T) {
+		store := makeStore(t)
+		require.NoError(t, store.WritePollingCache(data))
+		read, err := store.ReadPollingCache()
+		require.NoError(t, err)
+		assert.Equal(t, data, read)
+	})
+
+	t.Run("data is not removed by Init", func(t *testing.T) {
+		store := makeStore(t)
+		require.NoError(t, store.WritePollingCache(data))
+		require.NoError(t, store.(ld.FeatureStore).Init(makeMockDataMap(&MockDataItem{Key: "flag", Version: 1})))
+		read, err := store.ReadPollingCache()
+		require.NoError(t, err)
+		assert.Equal(t, data, read)
+	})
+}
@@ -0,0 +1,114 @@
+package ldclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v4/ldlog"
+)
+
+// Saves the last known flag data to a local file, and loads it when the SDK starts. See Config.SnapshotFile.
+type snapshotFile struct {
+	path    string
+	loggers ldlog.Loggers
+	filter  *dataFilter // applied when loading, so that a change to the filter takes effect; may be nil
+	// True if the store has the data that was loaded from the snapshot, rather than data that was saved
+	// since then; the data may be older than a saved polling response (Config.PollCacheFile).
+	storeHasSnapshotData bool
+	lock                 sync.Mutex
+}
+
+func newSnapshotFile(path string, loggers ldlog.Loggers, filter *dataFilter) *snapshotFile {
+	return &snapshotFile{path: path, loggers: loggers, filter: filter}
+}
+
+// Loads the snapshot into the store. Returns false, with a nil error, if there is no snapshot file.
+func (s *snapshotFile) load(store FeatureStore) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	bytes, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	var data allData
+	if err := json.Unmarshal(bytes, &data); err != nil {
+		return false, err
+	}
+	storeData := MakeAllVersionedDataMap(data.Flags, data.Segments)
+	if s.filter != nil {
+		storeData = s.filter.filterAll(storeData)
+	}
+	if err := store.Init(storeData); err != nil {
+		return false, err
+	}
+	s.storeHasSnapshotData = true
+	return true, nil
+}
+
+func (s *snapshotFile) isStoreDataFromSnapshot() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.storeHasSnapshotData
+}
+
+// Replaces the snapshot with new data. The data is written to a temporary file which is then renamed, so
+// that a snapshot that was only partly written is never loaded. Errors are logged but not returned, since
+// they should not prevent the SDK from using the data.
+func (s *snapshotFile) save(data allData) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.storeHasSnapshotData = false
+	if err := s.write(data); err != nil {
+		s.loggers.Warnf("Unable to save flag data to snapshot file %s: %s", s.path, err)
+	}
+}
+
+func (s *snapshotFile) write(data allData) error {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(s.path, bytes)
+}
+
+// Writes a file by way of a temporary file which is then renamed, so that readers never see a partly
+// written file.
+func writeFileAtomically(path string, data []byte) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tempFile.Write(data)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+	}
+	return err
+}
+
+// Loads the snapshot file, if any, when the client starts. See Config.SnapshotFile.
+func loadSnapshotIfAppropriate(config Config) {
+	if config.snapshot == nil || config.UseLdd || config.FeatureStore.Initialized() {
+		return
+	}
+	loaded, err := config.snapshot.load(config.FeatureStore)
+	if err != nil {
+		config.Loggers.Warnf("Unable to load flag data from snapshot file %s: %s", config.snapshot.path, err)
+	} else if loaded {
+		config.Loggers.Infof("Loaded last known flag data from snapshot file %s", config.snapshot.path)
+	}
+}
@@ -0,0 +1,169 @@
+package ldclient
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeSnapshotTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "snapshot-test")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestSnapshotFileRoundTrip(t *testing.T) {
+	dir := makeSnapshotTestDir(t)
+	defer os.RemoveAll(dir)
+	s := newSnapshotFile(filepath.Join(dir, "flags.json"), DefaultConfig.Loggers, nil)
+
+	s.save(allData{
+		Flags:    map[string]*FeatureFlag{"flag": {Key: "flag", Version: 2}},
+		Segments: map[string]*Segment{"segment": {Key: "segment", Version: 3}},
+	})
+
+	store := NewInMemoryFeatureStore(nil)
+	loaded, err := s.load(store)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	assert.True(t, store.Initialized())
+	flag, _ := store.Get(Features, "flag")
+	require.NotNil(t, flag)
+	assert.Equal(t, 2, flag.GetVersion())
+	segment, _ := store.Get(Segments, "segment")
+	require.NotNil(t, segment)
+	assert.Equal(t, 3, segment.GetVersion())
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Equal(t, 1, len(files)) // the temporary file was renamed
+}
+
+func TestSnapshotFileLoadReturnsFalseIfFileDoesNotExist(t *testing.T) {
+	dir := makeSnapshotTestDir(t)
+	defer os.RemoveAll(dir)
+	s := newSnapshotFile(filepath.Join(dir, "flags.json"), DefaultConfig.Loggers, nil)
+
+	store := NewInMemoryFeatureStore(nil)
+	loaded, err := s.load(store)
+	assert.NoError(t, err)
+	assert.False(t, loaded)
+	assert.False(t, store.Initialized())
+}
+
+func TestSnapshotFileLoadReturnsErrorForInvalidData(t *testing.T) {
+	dir := makeSnapshotTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flags.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte("{no"), 0644))
+	s := newSnapshotFile(path, DefaultConfig.Loggers, nil)
+
+	store := NewInMemoryFeatureStore(nil)
+	loaded, err := s.load(store)
+	assert.Error(t, err)
+	assert.False(t, loaded)
+	assert.False(t, store.Initialized())
+}
+
+func TestClientEvaluatesFlagsFromSnapshotBeforeInitialization(t *testing.T) {
+	dir := makeSnapshotTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flags.json")
+	newSnapshotFile(path, DefaultConfig.Loggers, nil).save(allData{
+		Flags: map[string]*FeatureFlag{"flag": {Key: "flag", OffVariation: intPtr(0), Variations: []interface{}{true}}},
+	})
+
+	client := makeTestClientWithConfig(func(c *Config) {
+		c.SnapshotFile = path
+		c.UpdateProcessorFactory = updateProcessorFactory(mockUpdateProcessor{IsInitialized: false})
+	})
+	defer client.Close()
+
+	assert.False(t, client.Initialized())
+	value, _ := client.BoolVariation("flag", evalTestUser, false)
+	assert.True(t, value)
+}
+
+func TestClientDoesNotLoadSnapshotIfStoreIsAlreadyInitialized(t *testing.T) {
+	dir := makeSnapshotTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flags.json")
+	newSnapshotFile(path, DefaultConfig.Loggers, nil).save(allData{
+		Flags: map[string]*FeatureFlag{"flag": {Key: "flag", Version: 1}},
+	})
+	store := NewInMemoryFeatureStore(nil)
+	_ = store.Init(MakeAllVersionedDataMap(map[string]*FeatureFlag{"other-flag": {Key: "other-flag"}}, nil))
+
+	client := makeTestClientWithConfig(func(c *Config) {
+		c.SnapshotFile = path
+		c.FeatureStore = store
+	})
+	defer client.Close()
+
+	flag, _ := store.Get(Features, "flag")
+	assert.Nil(t, flag)
+}
+
+func TestPollingProcessorSavesSnapshot(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"flags": {"my-flag": {"key": "my-flag", "version": 2}}, "segments": {}}`))
+	}))
+	defer ts.Close()
+	dir := makeSnapshotTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flags.json")
+
+	cfg := Config{
+		FeatureStore: NewInMemoryFeatureStore(nil),
+		Logger:       log.New(ioutil.Discard, "", 0),
+		PollInterval: time.Minute,
+		BaseUri:      ts.URL,
+		snapshot:     newSnapshotFile(path, DefaultConfig.Loggers, nil),
+	}
+	p := newPollingProcessor(cfg, newRequestor("fake", cfg, nil))
+	defer p.Close()
+	closeWhenReady := make(chan struct{})
+	p.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	store := NewInMemoryFeatureStore(nil)
+	loaded, err := cfg.snapshot.load(store)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	flag, _ := store.Get(Features, "my-flag")
+	assert.NotNil(t, flag)
+}
+
+func TestStreamProcessorSavesSnapshot(t *testing.T) {
+	s := newFallbackTestServer(200)
+	defer s.Close()
+	dir := makeSnapshotTestDir(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flags.json")
+
+	cfg := Config{
+		StreamUri:    s.URL,
+		FeatureStore: NewInMemoryFeatureStore(nil),
+		Logger:       log.New(ioutil.Discard, "", 0),
+		snapshot:     newSnapshotFile(path, DefaultConfig.Loggers, nil),
+	}
+	sp := newStreamProcessor("sdkKey", cfg, nil)
+	defer sp.Close()
+	closeWhenReady := make(chan struct{})
+	sp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	store := NewInMemoryFeatureStore(nil)
+	loaded, err := cfg.snapshot.load(store)
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	flag, _ := store.Get(Features, "streamed-flag")
+	assert.NotNil(t, flag)
+}
@@ -0,0 +1,147 @@
+package ldclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"gopkg.in/launchdarkly/go-server-sdk.v4/internal"
+)
+
+// ErrStaleData is returned by flag evaluations if Config.UseDefaultsForStaleData is set and the data in
+// the feature store is older than Config.FeatureStoreMaxDataAge.
+var ErrStaleData = errors.New("feature store data is older than the configured maximum age")
+
+// DataStoreFreshness describes how up to date the data in a shared feature store is, based on the
+// heartbeat that is written by the process that updates the store. See LDClient.GetDataStoreFreshness.
+type DataStoreFreshness struct {
+	// HeartbeatTime is the time of the most recent heartbeat, or zero if there is none.
+	HeartbeatTime time.Time
+	// Age is how long ago the most recent heartbeat was written, according to this host's clock. It is
+	// zero if there is no heartbeat.
+	Age time.Duration
+	// Stale is true if Config.FeatureStoreMaxDataAge is set, and either there is no heartbeat or the data
+	// is older than that.
+	Stale bool
+	// LastError is the error from the most recent attempt to read the heartbeat, or nil if it succeeded.
+	LastError error
+}
+
+// Optional interface implemented by UpdateProcessors that can tell whether they are currently receiving
+// data from LaunchDarkly. A heartbeat writer uses this, if available, rather than Initialized, so that
+// it stops writing heartbeats when the connection is lost.
+type connectionHealthProvider interface {
+	isConnectionHealthy(now time.Time) bool
+}
+
+// Writes or reads the heartbeat in a shared feature store; see Config.FeatureStoreHeartbeatInterval. A
+// client that receives data from LaunchDarkly is a writer, and a client in daemon mode is a reader.
+type storeHeartbeat struct {
+	store         internal.FeatureStoreHeartbeatProvider
+	config        Config
+	isReader      bool
+	isDataCurrent func(now time.Time) bool // for a writer, tells whether there is any data worth vouching for
+	lastHeartbeat time.Time
+	lastError     error
+	staleLogged   bool
+	lock          sync.Mutex
+	quit          chan struct{}
+	closeOnce     sync.Once
+}
+
+// Returns nil if the store does not support heartbeats.
+func newStoreHeartbeat(config Config, isReader bool, isDataCurrent func(now time.Time) bool) *storeHeartbeat {
+	store, ok := config.FeatureStore.(internal.FeatureStoreHeartbeatProvider)
+	if !ok || !store.IsHeartbeatSupported() {
+		config.Loggers.Warn("FeatureStoreHeartbeatInterval was set, but the feature store does not support heartbeats")
+		return nil
+	}
+	return &storeHeartbeat{
+		store:         store,
+		config:        config,
+		isReader:      isReader,
+		isDataCurrent: isDataCurrent,
+		quit:          make(chan struct{}),
+	}
+}
+
+// Reads or writes the heartbeat once, and then again at every interval. A reader's first read is done
+// synchronously, so that the freshness of the data is known before any flags are evaluated.
+func (h *storeHeartbeat) start() {
+	if h.isReader {
+		h.update(time.Now())
+	}
+	go func() {
+		ticker := time.NewTicker(h.config.FeatureStoreHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.quit:
+				return
+			case <-ticker.C:
+				h.update(time.Now())
+			}
+		}
+	}()
+}
+
+func (h *storeHeartbeat) close() {
+	h.closeOnce.Do(func() {
+		close(h.quit)
+	})
+}
+
+func (h *storeHeartbeat) update(now time.Time) {
+	if !h.isReader {
+		if h.isDataCurrent(now) {
+			if err := h.store.WriteHeartbeat(now); err != nil {
+				h.config.Loggers.Warnf("Unable to write heartbeat to feature store: %s", err)
+			}
+		}
+		return
+	}
+	heartbeat, err := h.store.ReadHeartbeat()
+	h.lock.Lock()
+	h.lastError = err
+	if err == nil {
+		h.lastHeartbeat = heartbeat
+	}
+	freshness := h.freshness(now)
+	h.lock.Unlock()
+
+	if err != nil {
+		h.config.Loggers.Warnf("Unable to read heartbeat from feature store: %s", err)
+	}
+	if freshness.Stale && !h.staleLogged {
+		if freshness.HeartbeatTime.IsZero() {
+			h.config.Loggers.Error("Feature store has no heartbeat; the data may be out of date")
+		} else {
+			h.config.Loggers.Errorf("Feature store data was last updated %s ago, which is more than FeatureStoreMaxDataAge",
+				freshness.Age)
+		}
+	} else if !freshness.Stale && h.staleLogged {
+		h.config.Loggers.Info("Feature store data is up to date again")
+	}
+	h.staleLogged = freshness.Stale
+}
+
+func (h *storeHeartbeat) getFreshness(now time.Time) DataStoreFreshness {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.freshness(now)
+}
+
+func (h *storeHeartbeat) isStale(now time.Time) bool {
+	return h.getFreshness(now).Stale
+}
+
+// Must be called with the lock held.
+func (h *storeHeartbeat) freshness(now time.Time) DataStoreFreshness {
+	ret := DataStoreFreshness{HeartbeatTime: h.lastHeartbeat, LastError: h.lastError}
+	if !h.lastHeartbeat.IsZero() {
+		ret.Age = now.Sub(h.lastHeartbeat)
+	}
+	maxAge := h.config.FeatureStoreMaxDataAge
+	ret.Stale = maxAge > 0 && (h.lastHeartbeat.IsZero() || ret.Age > maxAge)
+	return ret
+}
@@ -0,0 +1,169 @@
+package ldclient
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testFeatureStoreWithHeartbeat struct {
+	FeatureStore
+	heartbeat time.Time
+	err       error
+	lock      sync.Mutex
+}
+
+func newTestFeatureStoreWithHeartbeat() *testFeatureStoreWithHeartbeat {
+	return &testFeatureStoreWithHeartbeat{FeatureStore: NewInMemoryFeatureStore(nil)}
+}
+
+func (s *testFeatureStoreWithHeartbeat) IsHeartbeatSupported() bool {
+	return true
+}
+
+func (s *testFeatureStoreWithHeartbeat) WriteHeartbeat(t time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.heartbeat = t
+	return s.err
+}
+
+func (s *testFeatureStoreWithHeartbeat) ReadHeartbeat() (time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.heartbeat, s.err
+}
+
+func (s *testFeatureStoreWithHeartbeat) getHeartbeat() time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.heartbeat
+}
+
+func makeHeartbeatTestConfig(store FeatureStore) Config {
+	return Config{
+		FeatureStore:                  store,
+		Loggers:                       DefaultConfig.Loggers,
+		FeatureStoreHeartbeatInterval: time.Hour,
+		FeatureStoreMaxDataAge:        time.Minute,
+	}
+}
+
+func TestStoreHeartbeatReaderReportsDataAge(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	h := newStoreHeartbeat(makeHeartbeatTestConfig(store), true, nil)
+	require.NotNil(t, h)
+	now := time.Now()
+
+	h.update(now)
+	freshness := h.getFreshness(now)
+	assert.True(t, freshness.HeartbeatTime.IsZero())
+	assert.True(t, freshness.Stale) // no heartbeat at all
+
+	store.heartbeat = now.Add(-30 * time.Second)
+	h.update(now)
+	freshness = h.getFreshness(now)
+	assert.Equal(t, store.heartbeat, freshness.HeartbeatTime)
+	assert.Equal(t, 30*time.Second, freshness.Age)
+	assert.False(t, freshness.Stale)
+
+	assert.True(t, h.isStale(now.Add(time.Minute)))
+}
+
+func TestStoreHeartbeatReaderKeepsLastHeartbeatAfterError(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	h := newStoreHeartbeat(makeHeartbeatTestConfig(store), true, nil)
+	now := time.Now()
+	store.heartbeat = now
+	h.update(now)
+
+	store.err = errors.New("sorry")
+	h.update(now)
+	freshness := h.getFreshness(now)
+	assert.Equal(t, now, freshness.HeartbeatTime)
+	assert.Equal(t, store.err, freshness.LastError)
+}
+
+func TestStoreHeartbeatIsNeverStaleWithoutMaxDataAge(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	config := makeHeartbeatTestConfig(store)
+	config.FeatureStoreMaxDataAge = 0
+	h := newStoreHeartbeat(config, true, nil)
+
+	h.update(time.Now())
+	assert.False(t, h.isStale(time.Now()))
+}
+
+func TestStoreHeartbeatWriterWritesOnlyIfDataIsCurrent(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	current := false
+	h := newStoreHeartbeat(makeHeartbeatTestConfig(store), false, func(time.Time) bool { return current })
+	now := time.Now()
+
+	h.update(now)
+	assert.True(t, store.getHeartbeat().IsZero())
+
+	current = true
+	h.update(now)
+	assert.Equal(t, now, store.getHeartbeat())
+}
+
+func TestStoreHeartbeatIsNotCreatedIfStoreDoesNotSupportIt(t *testing.T) {
+	h := newStoreHeartbeat(makeHeartbeatTestConfig(NewInMemoryFeatureStore(nil)), true, nil)
+	assert.Nil(t, h)
+}
+
+func TestClientWritesHeartbeatOnceInitialized(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	client := makeTestClientWithConfig(func(c *Config) {
+		c.FeatureStore = store
+		c.FeatureStoreHeartbeatInterval = 10 * time.Millisecond
+	})
+	defer client.Close()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for store.getHeartbeat().IsZero() {
+		require.True(t, time.Now().Before(deadline), "timed out waiting for heartbeat")
+		time.Sleep(5 * time.Millisecond)
+	}
+	_, ok := client.GetDataStoreFreshness()
+	assert.False(t, ok) // a writer does not report freshness
+}
+
+func TestClientInDaemonModeReturnsDefaultsForStaleDataIfConfigured(t *testing.T) {
+	store := newTestFeatureStoreWithHeartbeat()
+	_ = store.Init(MakeAllVersionedDataMap(map[string]*FeatureFlag{
+		"flag": {Key: "flag", OffVariation: intPtr(0), Variations: []interface{}{true}},
+	}, nil))
+	store.heartbeat = time.Now().Add(-time.Hour)
+	makeClient := func(useDefaults bool) *LDClient {
+		return makeTestClientWithConfig(func(c *Config) {
+			c.FeatureStore = store
+			c.UseLdd = true
+			c.UpdateProcessorFactory = nil
+			c.FeatureStoreHeartbeatInterval = time.Hour
+			c.FeatureStoreMaxDataAge = time.Minute
+			c.UseDefaultsForStaleData = useDefaults
+		})
+	}
+
+	client := makeClient(false)
+	defer client.Close()
+	freshness, ok := client.GetDataStoreFreshness()
+	assert.True(t, ok)
+	assert.True(t, freshness.Stale)
+	value, _ := client.BoolVariation("flag", evalTestUser, false)
+	assert.True(t, value)
+
+	client = makeClient(true)
+	defer client.Close()
+	value, detail, err := client.BoolVariationDetail("flag", evalTestUser, false)
+	assert.Equal(t, ErrStaleData, err)
+	assert.False(t, value)
+	assert.Equal(t, newEvalReasonError(EvalErrorClientNotReady), detail.Reason)
+	assert.False(t, client.AllFlagsState(evalTestUser).IsValid())
+}
@@ -0,0 +1,75 @@
+package ldclient
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Computes the delays between attempts to reconnect to the stream: exponential backoff with jitter, which
+// is reset once a connection has stayed open for long enough. This is used only by the stream processor's
+// own goroutine, so it is not thread-safe.
+type streamBackoff struct {
+	initialDelay  time.Duration
+	maxDelay      time.Duration
+	jitterRatio   float64
+	resetInterval time.Duration
+	retryCount    int
+	goodSince     time.Time
+	randFloat     func() float64
+}
+
+func newStreamBackoff(config Config) *streamBackoff {
+	b := &streamBackoff{
+		initialDelay:  config.StreamInitialReconnectDelay,
+		maxDelay:      config.StreamMaxReconnectDelay,
+		jitterRatio:   config.StreamReconnectJitterRatio,
+		resetInterval: config.StreamBackoffResetInterval,
+		randFloat:     rand.Float64,
+	}
+	if b.initialDelay <= 0 {
+		b.initialDelay = DefaultConfig.StreamInitialReconnectDelay
+	}
+	if b.maxDelay <= 0 {
+		b.maxDelay = DefaultConfig.StreamMaxReconnectDelay
+	}
+	if b.maxDelay < b.initialDelay {
+		b.maxDelay = b.initialDelay
+	}
+	if b.jitterRatio < 0 {
+		b.jitterRatio = 0
+	} else if b.jitterRatio > 1 {
+		b.jitterRatio = 1
+	}
+	if b.resetInterval <= 0 {
+		b.resetInterval = DefaultConfig.StreamBackoffResetInterval
+	}
+	return b
+}
+
+// Called when a connection has been established.
+func (b *streamBackoff) setGood(t time.Time) {
+	b.goodSince = t
+}
+
+// Called when a connection has ended. If it had been open for at least resetInterval, the next delay
+// starts over from initialDelay.
+func (b *streamBackoff) setBad(t time.Time) {
+	if !b.goodSince.IsZero() && t.Sub(b.goodSince) >= b.resetInterval {
+		b.retryCount = 0
+	}
+	b.goodSince = time.Time{}
+}
+
+// Returns the delay before the next connection attempt.
+func (b *streamBackoff) nextDelay() time.Duration {
+	delay := b.maxDelay
+	// Compare before multiplying so that a large retryCount can't overflow
+	if b.retryCount < 63 && b.initialDelay < b.maxDelay>>uint(b.retryCount) {
+		delay = b.initialDelay << uint(b.retryCount)
+	}
+	b.retryCount++
+	if b.jitterRatio > 0 {
+		delay -= time.Duration(b.randFloat() * b.jitterRatio * float64(delay))
+	}
+	return delay
+}
@@ -0,0 +1,82 @@
+package ldclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestBackoff(initial, max time.Duration, jitter float64, randValue float64) *streamBackoff {
+	b := newStreamBackoff(Config{
+		StreamInitialReconnectDelay: initial,
+		StreamMaxReconnectDelay:     max,
+		StreamReconnectJitterRatio:  jitter,
+		StreamBackoffResetInterval:  time.Minute,
+	})
+	b.randFloat = func() float64 { return randValue }
+	return b
+}
+
+func TestStreamBackoffUsesDefaults(t *testing.T) {
+	b := newStreamBackoff(Config{})
+	assert.Equal(t, time.Second, b.initialDelay)
+	assert.Equal(t, 30*time.Second, b.maxDelay)
+	assert.Equal(t, float64(0), b.jitterRatio)
+	assert.Equal(t, time.Minute, b.resetInterval)
+
+	assert.Equal(t, 0.5, newStreamBackoff(DefaultConfig).jitterRatio)
+}
+
+func TestStreamBackoffHasNoJitterIfRatioIsZeroOrNegative(t *testing.T) {
+	for _, jitter := range []float64{0, -1} {
+		b := makeTestBackoff(time.Second, 10*time.Second, jitter, 0.5)
+		assert.Equal(t, time.Second, b.nextDelay())
+		assert.Equal(t, 2*time.Second, b.nextDelay())
+	}
+}
+
+func TestStreamBackoffDoublesDelayUpToMaximum(t *testing.T) {
+	b := makeTestBackoff(time.Second, 10*time.Second, 0, 0.5)
+	var delays []time.Duration
+	for i := 0; i < 6; i++ {
+		delays = append(delays, b.nextDelay())
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second,
+		10 * time.Second, 10 * time.Second}, delays)
+}
+
+func TestStreamBackoffDoesNotOverflowAfterManyAttempts(t *testing.T) {
+	b := makeTestBackoff(time.Second, 30*time.Second, 0, 0)
+	for i := 0; i < 100; i++ {
+		b.nextDelay()
+	}
+	assert.Equal(t, 30*time.Second, b.nextDelay())
+}
+
+func TestStreamBackoffAppliesJitter(t *testing.T) {
+	b := makeTestBackoff(time.Second, 30*time.Second, 0.5, 0)
+	assert.Equal(t, time.Second, b.nextDelay())
+
+	b = makeTestBackoff(time.Second, 30*time.Second, 0.5, 0.5)
+	assert.Equal(t, 750*time.Millisecond, b.nextDelay())
+	assert.Equal(t, 1500*time.Millisecond, b.nextDelay())
+
+	b = makeTestBackoff(time.Second, 30*time.Second, 0.5, 0.999)
+	assert.True(t, b.nextDelay() > 500*time.Millisecond)
+}
+
+func TestStreamBackoffResetsAfterStableConnection(t *testing.T) {
+	b := makeTestBackoff(time.Second, 30*time.Second, 0, 0)
+	start := time.Now()
+	b.nextDelay()
+	b.nextDelay()
+
+	b.setGood(start)
+	b.setBad(start.Add(59 * time.Second))
+	assert.Equal(t, 4*time.Second, b.nextDelay())
+
+	b.setGood(start)
+	b.setBad(start.Add(time.Minute))
+	assert.Equal(t, time.Second, b.nextDelay())
+}
@@ -2,11 +2,13 @@ package ldclient
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	es "github.com/launchdarkly/eventsource"
@@ -22,17 +24,29 @@ const (
 	streamReadTimeout  = 5 * time.Minute // the LaunchDarkly stream should send a heartbeat comment every 3 minutes
 )
 
+var errStreamClosedBeforeData = errors.New("stream was closed before any data was received")
+
 type streamProcessor struct {
 	store                      FeatureStore
 	client                     *http.Client
 	requestor                  *requestor
 	config                     Config
+	filter                     *dataFilter // nil if all flags are stored
 	sdkKey                     string
 	setInitializedOnce         sync.Once
 	isInitialized              bool
 	halt                       chan struct{}
 	storeStatusSub             internal.FeatureStoreStatusSubscription
+	backoff                    *streamBackoff
+	endpoints                  *endpointSelector
+	streamUri                  string          // the endpoint of the current connection
+	failingBack                bool            // true if we closed the stream to reconnect to a preferred endpoint
+	lastEventID                string          // the ID of the last event received, if the server provides IDs
+	lastEventURI               string          // the endpoint that lastEventID came from
+	resumed                    bool            // true if we reconnected with Last-Event-ID and have not received an event since
+	connectionResultListener   func(err error) // used by streamingWithFallbackProcessor
 	connectionAttemptStartTime uint64
+	connected                  int32 // set atomically to 1 while we are receiving data from a stream
 	readyOnce                  sync.Once
 	closeOnce                  sync.Once
 }
@@ -91,13 +105,9 @@ func parsePath(path string) (parsedPath, error) {
 
 // Returns true if we should recreate the stream and start over
 func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struct{}) bool {
-	notifyReady := func() {
-		sp.readyOnce.Do(func() {
-			close(closeWhenReady)
-		})
-	}
 	// Ensure we stop waiting for initialization if we exit, even if initialization fails
-	defer notifyReady()
+	defer sp.signalReady(closeWhenReady)
+	defer atomic.StoreInt32(&sp.connected, 0)
 
 	// Consume remaining Events and Errors so we can garbage collect
 	defer func() {
@@ -112,6 +122,14 @@ func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struc
 		statusCh = sp.storeStatusSub.Channel()
 	}
 
+	// If we're not connected to the most preferred endpoint, we'll try that one again when it's due
+	var failbackCh <-chan time.Time
+	if failbackTime, ok := sp.endpoints.nextFailbackTime(sp.streamUri); ok {
+		failbackTimer := time.NewTimer(time.Until(failbackTime))
+		defer failbackTimer.Stop()
+		failbackCh = failbackTimer.C
+	}
+
 	for {
 		select {
 		case event, ok := <-stream.Events:
@@ -119,7 +137,15 @@ func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struc
 				sp.config.Loggers.Info("Event stream closed")
 				return false
 			}
-			sp.logConnectionResult(true)
+			sp.logConnectionResult(nil)
+			atomic.StoreInt32(&sp.connected, 1)
+			if id := event.Id(); id != "" {
+				sp.lastEventID = id
+				sp.lastEventURI = sp.streamUri
+			}
+			// Only the first event after resuming can reveal missed updates; see resyncIfVersionGap.
+			firstResumedEvent := sp.resumed
+			sp.resumed = false
 			switch event.Event() {
 			case putEvent:
 				var put putData
@@ -127,15 +153,14 @@ func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struc
 					sp.config.Loggers.Errorf("Unexpected error unmarshalling PUT json: %+v", err)
 					break
 				}
-				err := sp.store.Init(MakeAllVersionedDataMap(put.Data.Flags, put.Data.Segments))
-				if err != nil {
+				if err := sp.initStore(put.Data); err != nil {
 					sp.config.Loggers.Errorf("Error initializing store: %s", err)
 					return false
 				}
 				sp.setInitializedOnce.Do(func() {
 					sp.config.Loggers.Info("LaunchDarkly streaming is active")
 					sp.isInitialized = true
-					notifyReady()
+					sp.signalReady(closeWhenReady)
 				})
 			case patchEvent:
 				var patch patchData
@@ -153,7 +178,10 @@ func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struc
 					sp.config.Loggers.Errorf("Unexpected error unmarshalling JSON for %s item: %+v", path.kind, err)
 					break
 				}
-				if err = sp.store.Upsert(path.kind, item); err != nil {
+				if firstResumedEvent && sp.resyncIfVersionGap(path.kind, item) {
+					break
+				}
+				if err = sp.storeUpdatedItem(path.kind, item); err != nil {
 					sp.config.Loggers.Errorf("Unexpected error storing %s item: %+v", path.kind, err)
 				}
 			case deleteEvent:
@@ -167,8 +195,13 @@ func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struc
 					sp.config.Loggers.Errorf("Unable to process event %s: %s", event.Event(), err)
 					break
 				}
+				if sp.filter != nil && !sp.filter.shouldStore(sp.store, path.kind, path.key, nil) {
+					break
+				}
 				if err = sp.store.Delete(path.kind, path.key, data.Version); err != nil {
 					sp.config.Loggers.Errorf(`Unexpected error deleting %s item "%s": %s`, path.kind, path.key, err)
+				} else if sp.filter != nil {
+					sp.filter.recordStored(path.kind, path.key, data.Version, true)
 				}
 			case indirectPatchEvent:
 				path, err := parsePath(event.Data())
@@ -181,7 +214,7 @@ func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struc
 					sp.config.Loggers.Errorf(`Unexpected error requesting %s item "%s": %+v`, path.kind, path.key, err)
 					break
 				}
-				if err = sp.store.Upsert(path.kind, item); err != nil {
+				if err = sp.storeUpdatedItem(path.kind, item); err != nil {
 					sp.config.Loggers.Errorf(`Unexpected error store %s item "%s": %+v`, path.kind, path.key, err)
 				}
 			default:
@@ -202,14 +235,24 @@ func (sp *streamProcessor) events(stream *es.Stream, closeWhenReady chan<- struc
 					return false
 				}
 			}
+			// Rather than letting the eventsource package reconnect, which it would do with its own fixed
+			// delay, we start a new stream so that subscribe() can apply our backoff policy.
+			stream.Close()
+			return true
 		case newStoreStatus := <-statusCh:
 			if newStoreStatus.Available && newStoreStatus.NeedsRefresh {
 				// The store has just transitioned from unavailable to available, and we can't guarantee that
 				// all of the latest data got cached, so let's restart the stream to refresh all the data.
 				sp.config.Loggers.Warn("Restarting stream to refresh data after feature store outage")
+				sp.lastEventID = "" // we need a full put, not just the events we missed
 				stream.Close()
 				return true // causes subscribe() to restart the connection
 			}
+		case <-failbackCh:
+			sp.config.Loggers.Infof("Reconnecting to preferred stream endpoint %s", sp.endpoints.first(time.Now()))
+			sp.failingBack = true
+			stream.Close()
+			return true
 		case <-sp.halt:
 			stream.Close()
 			return false
@@ -224,6 +267,9 @@ func newStreamProcessor(sdkKey string, config Config, requestor *requestor) *str
 		sdkKey:    sdkKey,
 		requestor: requestor,
 		halt:      make(chan struct{}),
+		backoff:   newStreamBackoff(config),
+		filter:    newDataFilter(config),
+		endpoints: newEndpointSelector(config.streamUris(), config.EndpointFailbackInterval),
 	}
 
 	sp.client = config.newHTTPClient()
@@ -236,44 +282,182 @@ func newStreamProcessor(sdkKey string, config Config, requestor *requestor) *str
 	return sp
 }
 
+// Replaces all of the data in the store, as for a put event.
+func (sp *streamProcessor) initStore(data allData) error {
+	storeData := MakeAllVersionedDataMap(data.Flags, data.Segments)
+	if sp.filter != nil {
+		storeData = sp.filter.filterAll(storeData)
+	}
+	if err := sp.store.Init(storeData); err != nil {
+		return err
+	}
+	if sp.filter != nil {
+		sp.filter.recordInit(storeData)
+	}
+	if sp.config.snapshot != nil {
+		sp.config.snapshot.save(data)
+	}
+	sp.resumed = false
+	return nil
+}
+
+// Checks whether the version of an item in the first patch event after resuming a stream with Last-Event-ID
+// is more than one higher than the stored version. Within one connection the events arrive in order, so a
+// version may skip numbers for other reasons; but in the first replayed event, a gap may mean that the
+// server could not replay all of the events we missed, for this item or for others, so we request all of
+// the data again. Later events, including a patch that follows a replayed delete, are live updates and
+// are not checked. Returns true if the data was resynced, in which case the patch should not be applied.
+func (sp *streamProcessor) resyncIfVersionGap(kind VersionedDataKind, item VersionedData) bool {
+	if sp.requestor == nil {
+		return false
+	}
+	existing, err := sp.store.Get(kind, item.GetKey())
+	if err != nil || existing == nil || item.GetVersion() <= existing.GetVersion()+1 {
+		return false
+	}
+	sp.config.Loggers.Warnf(
+		`Missed updates to %s item "%s" (from version %d to %d) after resuming stream; requesting all data`,
+		kind, item.GetKey(), existing.GetVersion(), item.GetVersion())
+	data, _, err := sp.requestor.requestAll(true)
+	if err == nil {
+		err = sp.initStore(data)
+	}
+	if err != nil {
+		sp.config.Loggers.Errorf("Unable to resync all data: %s", err)
+		return false
+	}
+	return true
+}
+
+// Stores an item from a patch or indirect patch event, unless it is excluded by Config.StoredFlagKeys and
+// related options. If the item is a flag that now depends on prerequisites or segments that were filtered
+// out, they are requested individually and stored first, so that the flag is never evaluated without them.
+func (sp *streamProcessor) storeUpdatedItem(kind VersionedDataKind, item VersionedData) error {
+	if sp.filter != nil {
+		if !sp.filter.shouldStore(sp.store, kind, item.GetKey(), item) {
+			return nil
+		}
+		if flag, ok := item.(*FeatureFlag); ok {
+			sp.storeMissingDependencies(flag, make(map[itemRef]bool))
+		}
+	}
+	return sp.upsert(kind, item)
+}
+
+func (sp *streamProcessor) upsert(kind VersionedDataKind, item VersionedData) error {
+	err := sp.store.Upsert(kind, item)
+	if err == nil && sp.filter != nil {
+		sp.filter.recordStored(kind, item.GetKey(), item.GetVersion(), item.IsDeleted())
+	}
+	return err
+}
+
+func (sp *streamProcessor) storeMissingDependencies(flag *FeatureFlag, seen map[itemRef]bool) {
+	for _, ref := range sp.filter.missingDependencies(sp.store, flag) {
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		item, err := sp.requestor.requestResource(ref.kind, ref.key)
+		if err != nil {
+			sp.config.Loggers.Errorf(`Unable to request %s item "%s" that flag "%s" depends on: %s`,
+				ref.kind, ref.key, flag.Key, err)
+			continue
+		}
+		if dep, ok := item.(*FeatureFlag); ok {
+			sp.storeMissingDependencies(dep, seen)
+		}
+		if err := sp.upsert(ref.kind, item); err != nil {
+			sp.config.Loggers.Errorf(`Unexpected error storing %s item "%s": %s`, ref.kind, ref.key, err)
+		}
+	}
+}
+
 func (sp *streamProcessor) subscribe(closeWhenReady chan<- struct{}) {
 	for {
-		req, _ := http.NewRequest("GET", sp.config.StreamUri+"/all", nil)
+		sp.streamUri = sp.endpoints.first(time.Now())
+		req, _ := http.NewRequest("GET", sp.streamUri+"/all", nil)
 		addBaseHeaders(req, sp.sdkKey, sp.config)
 		sp.config.Loggers.Info("Connecting to LaunchDarkly stream")
 
 		sp.logConnectionStarted()
 
-		if stream, err := es.SubscribeWithRequestAndOptions(req,
+		options := []es.StreamOption{
 			es.StreamOptionHTTPClient(sp.client),
 			es.StreamOptionReadTimeout(streamReadTimeout),
-			es.StreamOptionLogger(sp.config.Loggers.ForLevel(ldlog.Info))); err != nil {
+			es.StreamOptionLogger(sp.config.Loggers.ForLevel(ldlog.Info)),
+		}
+		// If the server gave us event IDs, it may be able to send just the events we missed, instead of a
+		// full put. Event IDs are only meaningful to the server they came from.
+		sp.resumed = sp.lastEventID != "" && sp.lastEventURI == sp.streamUri
+		if sp.resumed {
+			options = append(options, es.StreamOptionLastEventID(sp.lastEventID))
+		}
+
+		if stream, err := es.SubscribeWithRequestAndOptions(req, options...); err != nil {
 
 			sp.config.Loggers.Warnf("Unable to establish streaming connection: %+v", err)
-			sp.logConnectionResult(false)
+			sp.logConnectionResult(err)
 
 			if sp.checkIfPermanentFailure(err) {
-				close(closeWhenReady)
+				sp.signalReady(closeWhenReady)
 				return
 			}
+			sp.endpoints.markFailed(sp.streamUri, time.Now())
 
-			// Halt immediately if we've been closed already
-			select {
-			case <-sp.halt:
-				close(closeWhenReady)
+			if !sp.waitToReconnect() {
+				sp.signalReady(closeWhenReady)
 				return
-			default:
-				time.Sleep(2 * time.Second)
 			}
 		} else {
+			sp.backoff.setGood(time.Now())
+			sp.endpoints.markSucceeded(sp.streamUri)
 			if !sp.events(stream, closeWhenReady) {
 				return
 			}
-			// If events() returned true, we should continue the for loop
+			// If events() returned true, we should reconnect
+			if sp.connectionAttemptStartTime > 0 {
+				sp.logConnectionResult(errStreamClosedBeforeData)
+				sp.endpoints.markFailed(sp.streamUri, time.Now())
+			}
+			sp.backoff.setBad(time.Now())
+			if sp.failingBack {
+				sp.failingBack = false
+				continue // reconnect to the preferred endpoint right away
+			}
+			if !sp.waitToReconnect() {
+				return
+			}
 		}
 	}
 }
 
+// The connection is healthy if the current stream has sent us data. A stream that stops sending data
+// without being closed is detected by the read timeout.
+func (sp *streamProcessor) isConnectionHealthy(now time.Time) bool {
+	return atomic.LoadInt32(&sp.connected) == 1
+}
+
+// Stops the client from waiting for initialization. The channel may already have been closed by an
+// earlier connection, so this must be the only place where it is closed.
+func (sp *streamProcessor) signalReady(closeWhenReady chan<- struct{}) {
+	sp.readyOnce.Do(func() {
+		close(closeWhenReady)
+	})
+}
+
+// Sleeps for the next backoff delay. Returns false if the processor was closed in the meantime.
+func (sp *streamProcessor) waitToReconnect() bool {
+	delay := sp.backoff.nextDelay()
+	sp.config.Loggers.Infof("Will reconnect to stream in %s", delay)
+	select {
+	case <-sp.halt:
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
 func (sp *streamProcessor) checkIfPermanentFailure(err error) bool {
 	if se, ok := err.(es.SubscriptionError); ok {
 		sp.config.Loggers.Error(httpErrorMessage(se.Code, "streaming connection", "will retry"))
@@ -288,11 +472,18 @@ func (sp *streamProcessor) logConnectionStarted() {
 	sp.connectionAttemptStartTime = now()
 }
 
-func (sp *streamProcessor) logConnectionResult(success bool) {
-	if sp.connectionAttemptStartTime > 0 && sp.config.diagnosticsManager != nil {
+// Records the outcome of a connection attempt; err is nil if we received data from the stream.
+func (sp *streamProcessor) logConnectionResult(err error) {
+	if sp.connectionAttemptStartTime == 0 {
+		return
+	}
+	if sp.config.diagnosticsManager != nil {
 		timestamp := now()
-		sp.config.diagnosticsManager.RecordStreamInit(timestamp, !success,
-			milliseconds(timestamp-sp.connectionAttemptStartTime))
+		sp.config.diagnosticsManager.RecordStreamInit(timestamp, err != nil,
+			DiagnosticMillis(timestamp-sp.connectionAttemptStartTime))
+	}
+	if sp.connectionResultListener != nil {
+		sp.connectionResultListener(err)
 	}
 	sp.connectionAttemptStartTime = 0
 }
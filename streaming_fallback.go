@@ -0,0 +1,227 @@
+package ldclient
+
+import (
+	"sync"
+	"time"
+
+	es "github.com/launchdarkly/eventsource"
+)
+
+const defaultStreamFallbackProbeInterval = 5 * time.Minute
+
+// An UpdateProcessor that uses streaming, but switches to polling if the stream keeps failing, as
+// configured by Config.StreamFallbackFailureCount and Config.StreamFallbackTimeout. While polling, it
+// periodically starts a new stream processor as a probe; if the probe receives data, it becomes the
+// active processor and polling stops.
+type streamingWithFallbackProcessor struct {
+	sdkKey         string
+	config         Config
+	requestor      *requestor
+	probeInterval  time.Duration
+	stream         *streamProcessor
+	polling        *pollingProcessor
+	wasInitialized bool // true if a processor that we have closed had been initialized
+	lock           sync.Mutex
+	quit           chan struct{}
+	closeOnce      sync.Once
+}
+
+func newStreamingWithFallbackProcessor(sdkKey string, config Config, requestor *requestor) *streamingWithFallbackProcessor {
+	probeInterval := config.StreamFallbackProbeInterval
+	if probeInterval <= 0 {
+		probeInterval = defaultStreamFallbackProbeInterval
+	}
+	return &streamingWithFallbackProcessor{
+		sdkKey:        sdkKey,
+		config:        config,
+		requestor:     requestor,
+		probeInterval: probeInterval,
+		quit:          make(chan struct{}),
+	}
+}
+
+func (fp *streamingWithFallbackProcessor) Initialized() bool {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	return fp.wasInitialized || (fp.stream != nil && fp.stream.Initialized()) ||
+		(fp.polling != nil && fp.polling.Initialized())
+}
+
+func (fp *streamingWithFallbackProcessor) isConnectionHealthy(now time.Time) bool {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	return (fp.stream != nil && fp.stream.isConnectionHealthy(now)) ||
+		(fp.polling != nil && fp.polling.isConnectionHealthy(now))
+}
+
+func (fp *streamingWithFallbackProcessor) Start(closeWhenReady chan<- struct{}) {
+	go fp.run(closeWhenReady)
+}
+
+func (fp *streamingWithFallbackProcessor) Close() error {
+	fp.closeOnce.Do(func() {
+		close(fp.quit)
+	})
+	return nil
+}
+
+func (fp *streamingWithFallbackProcessor) run(closeWhenReady chan<- struct{}) {
+	var readyOnce sync.Once
+	notifyReady := func() {
+		readyOnce.Do(func() {
+			close(closeWhenReady)
+		})
+	}
+	// Ensure we stop waiting for initialization if we exit, even if initialization fails
+	defer notifyReady()
+
+	streamReady, streamResults := fp.startStream()
+	var pollingReady <-chan struct{}
+	var probeTicker *time.Ticker
+	var probeCh <-chan time.Time
+	probing := false
+	failures := 0
+	var failingSince time.Time
+
+	defer func() {
+		if probeTicker != nil {
+			probeTicker.Stop()
+		}
+		fp.lock.Lock()
+		defer fp.lock.Unlock()
+		if fp.stream != nil {
+			_ = fp.stream.Close()
+		}
+		if fp.polling != nil {
+			_ = fp.polling.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-fp.quit:
+			return
+		case <-streamReady:
+			streamReady = nil
+			if !probing {
+				// Either the stream received data, or it failed permanently (for instance, because the SDK key
+				// was rejected), in which case there is no point in falling back to polling.
+				notifyReady()
+			} else if !fp.streamInitialized() {
+				// The probe failed permanently; we'll try again later
+				fp.closeStream()
+				streamResults = nil
+				probing = false
+			}
+		case <-pollingReady:
+			pollingReady = nil
+			notifyReady()
+		case success := <-streamResults:
+			if probing {
+				if success {
+					fp.config.Loggers.Warn("Streaming connection has recovered; stopping polling")
+					fp.closePolling()
+					probeTicker.Stop()
+					probeTicker, probeCh, pollingReady = nil, nil, nil
+					probing = false
+					failures = 0
+				} else {
+					fp.closeStream()
+					streamReady, streamResults = nil, nil
+					probing = false
+				}
+				break
+			}
+			if success {
+				failures = 0
+				failingSince = time.Time{}
+				break
+			}
+			failures++
+			if failingSince.IsZero() {
+				failingSince = time.Now()
+			}
+			if fp.shouldFallBack(failures, failingSince) {
+				fp.config.Loggers.Warnf("Streaming connection has failed %d times; falling back to polling", failures)
+				fp.closeStream()
+				streamReady, streamResults = nil, nil
+				pollingReady = fp.startPolling()
+				probeTicker = time.NewTicker(fp.probeInterval)
+				probeCh = probeTicker.C
+				failures = 0
+				failingSince = time.Time{}
+			}
+		case <-probeCh:
+			if !probing {
+				fp.config.Loggers.Info("Trying to reconnect to streaming service")
+				streamReady, streamResults = fp.startStream()
+				probing = true
+			}
+		}
+	}
+}
+
+func (fp *streamingWithFallbackProcessor) streamInitialized() bool {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	return fp.stream != nil && fp.stream.Initialized()
+}
+
+func (fp *streamingWithFallbackProcessor) shouldFallBack(failures int, failingSince time.Time) bool {
+	if fp.config.StreamFallbackFailureCount > 0 && failures >= fp.config.StreamFallbackFailureCount {
+		return true
+	}
+	return fp.config.StreamFallbackTimeout > 0 && time.Since(failingSince) >= fp.config.StreamFallbackTimeout
+}
+
+// Starts a new stream processor, returning its ready channel and a channel that receives the result of
+// each connection attempt.
+func (fp *streamingWithFallbackProcessor) startStream() (<-chan struct{}, <-chan bool) {
+	ready := make(chan struct{})
+	results := make(chan bool, 10)
+	sp := newStreamProcessor(fp.sdkKey, fp.config, fp.requestor)
+	sp.connectionResultListener = func(err error) {
+		if se, ok := err.(es.SubscriptionError); ok && !isHTTPErrorRecoverable(se.Code) {
+			return // the stream processor will give up, and polling would fail in the same way
+		}
+		select {
+		case results <- err == nil:
+		default: // we only care about the most recent results; don't block the stream
+		}
+	}
+	fp.lock.Lock()
+	fp.stream = sp
+	fp.lock.Unlock()
+	sp.Start(ready)
+	return ready, results
+}
+
+func (fp *streamingWithFallbackProcessor) startPolling() <-chan struct{} {
+	ready := make(chan struct{})
+	pp := newPollingProcessor(fp.config, fp.requestor)
+	fp.lock.Lock()
+	fp.polling = pp
+	fp.lock.Unlock()
+	pp.Start(ready)
+	return ready
+}
+
+func (fp *streamingWithFallbackProcessor) closeStream() {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	if fp.stream != nil {
+		fp.wasInitialized = fp.wasInitialized || fp.stream.Initialized()
+		_ = fp.stream.Close()
+		fp.stream = nil
+	}
+}
+
+func (fp *streamingWithFallbackProcessor) closePolling() {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	if fp.polling != nil {
+		fp.wasInitialized = fp.wasInitialized || fp.polling.Initialized()
+		_ = fp.polling.Close()
+		fp.polling = nil
+	}
+}
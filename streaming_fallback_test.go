@@ -0,0 +1,252 @@
+package ldclient
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A test server that provides both the streaming and polling endpoints. The stream responds with
+// streamStatus, or with a put event if streamStatus is 200.
+type fallbackTestServer struct {
+	*httptest.Server
+	streamStatus int
+	streamCount  int
+	pollCount    int
+	lock         sync.Mutex
+}
+
+func newFallbackTestServer(streamStatus int) *fallbackTestServer {
+	s := &fallbackTestServer{streamStatus: streamStatus}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		status := s.streamStatus
+		if r.URL.Path == "/all" {
+			s.streamCount++
+		} else {
+			s.pollCount++
+		}
+		s.lock.Unlock()
+		if r.URL.Path != "/all" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"flags": {"polled-flag": {"key": "polled-flag", "version": 1}}, "segments": {}}`))
+			return
+		}
+		if status != 200 {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte("event: put\ndata: {\"path\": \"/\", \"data\": {\"flags\": " +
+			"{\"streamed-flag\": {\"key\": \"streamed-flag\", \"version\": 1}}, \"segments\": {}}}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done() // keep the stream open
+	}))
+	return s
+}
+
+func (s *fallbackTestServer) setStreamStatus(status int) {
+	s.lock.Lock()
+	s.streamStatus = status
+	s.lock.Unlock()
+}
+
+func (s *fallbackTestServer) counts() (streams, polls int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.streamCount, s.pollCount
+}
+
+func makeFallbackTestProcessor(s *fallbackTestServer, modConfig func(*Config)) (*streamingWithFallbackProcessor, FeatureStore) {
+	store := NewInMemoryFeatureStore(nil)
+	config := Config{
+		StreamUri:                   s.URL,
+		BaseUri:                     s.URL,
+		FeatureStore:                store,
+		Logger:                      log.New(ioutil.Discard, "", 0),
+		PollInterval:                time.Hour,
+		StreamInitialReconnectDelay: 10 * time.Millisecond,
+		StreamFallbackFailureCount:  2,
+		StreamFallbackProbeInterval: time.Hour,
+	}
+	if modConfig != nil {
+		modConfig(&config)
+	}
+	return newStreamingWithFallbackProcessor("sdkKey", config, newRequestor("sdkKey", config, nil)), store
+}
+
+func awaitReady(t *testing.T, closeWhenReady <-chan struct{}) {
+	select {
+	case <-closeWhenReady:
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "timed out waiting for update processor to be ready")
+	}
+}
+
+func TestStreamingWithFallbackUsesStreamIfItWorks(t *testing.T) {
+	s := newFallbackTestServer(200)
+	defer s.Close()
+	fp, store := makeFallbackTestProcessor(s, nil)
+	defer fp.Close()
+
+	closeWhenReady := make(chan struct{})
+	fp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	assert.True(t, fp.Initialized())
+	flag, _ := store.Get(Features, "streamed-flag")
+	assert.NotNil(t, flag)
+	_, polls := s.counts()
+	assert.Equal(t, 0, polls)
+}
+
+func TestStreamingWithFallbackSwitchesToPollingAfterFailureCount(t *testing.T) {
+	s := newFallbackTestServer(503)
+	defer s.Close()
+	fp, store := makeFallbackTestProcessor(s, nil)
+	defer fp.Close()
+
+	closeWhenReady := make(chan struct{})
+	fp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	assert.True(t, fp.Initialized())
+	flag, _ := store.Get(Features, "polled-flag")
+	assert.NotNil(t, flag)
+	streams, polls := s.counts()
+	assert.True(t, streams >= 2)
+	assert.Equal(t, 1, polls)
+}
+
+func TestStreamingWithFallbackSwitchesToPollingAfterTimeout(t *testing.T) {
+	s := newFallbackTestServer(503)
+	defer s.Close()
+	fp, _ := makeFallbackTestProcessor(s, func(c *Config) {
+		c.StreamFallbackFailureCount = 0
+		c.StreamFallbackTimeout = 100 * time.Millisecond
+	})
+	defer fp.Close()
+
+	closeWhenReady := make(chan struct{})
+	fp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	assert.True(t, fp.Initialized())
+	streams, polls := s.counts()
+	assert.True(t, streams > 1)
+	assert.Equal(t, 1, polls)
+}
+
+func TestStreamingWithFallbackSwitchesToPollingAfterStreamThatHadConnectedFails(t *testing.T) {
+	s := newFallbackTestServer(200)
+	defer s.Close()
+	fp, store := makeFallbackTestProcessor(s, nil)
+	defer fp.Close()
+
+	closeWhenReady := make(chan struct{})
+	fp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+	s.setStreamStatus(503)
+	s.CloseClientConnections()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		fp.lock.Lock()
+		switched := fp.polling != nil && fp.stream == nil
+		fp.lock.Unlock()
+		if switched {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "timed out waiting for processor to fall back to polling")
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond) // give the closed stream processor time to exit
+	assert.True(t, fp.Initialized())
+	flag, _ := store.Get(Features, "polled-flag")
+	assert.NotNil(t, flag)
+}
+
+func TestStreamingWithFallbackReturnsToStreamingWhenProbeSucceeds(t *testing.T) {
+	s := newFallbackTestServer(503)
+	defer s.Close()
+	fp, store := makeFallbackTestProcessor(s, func(c *Config) {
+		c.StreamFallbackProbeInterval = 50 * time.Millisecond
+	})
+	defer fp.Close()
+
+	closeWhenReady := make(chan struct{})
+	fp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+	s.setStreamStatus(200)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		fp.lock.Lock()
+		switched := fp.polling == nil && fp.stream != nil
+		fp.lock.Unlock()
+		if switched {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "timed out waiting for processor to return to streaming")
+		time.Sleep(10 * time.Millisecond)
+	}
+	flag, _ := store.Get(Features, "streamed-flag")
+	assert.NotNil(t, flag)
+	assert.True(t, fp.Initialized())
+}
+
+func TestStreamingWithFallbackKeepsPollingWhileProbesFail(t *testing.T) {
+	s := newFallbackTestServer(503)
+	defer s.Close()
+	fp, _ := makeFallbackTestProcessor(s, func(c *Config) {
+		c.StreamFallbackProbeInterval = 50 * time.Millisecond
+	})
+	defer fp.Close()
+
+	closeWhenReady := make(chan struct{})
+	fp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+	streamsBefore, _ := s.counts()
+
+	time.Sleep(300 * time.Millisecond)
+	streamsAfter, _ := s.counts()
+	assert.True(t, streamsAfter > streamsBefore) // probes were attempted
+	fp.lock.Lock()
+	assert.NotNil(t, fp.polling)
+	fp.lock.Unlock()
+	assert.True(t, fp.Initialized())
+}
+
+func TestStreamingWithFallbackDoesNotFallBackAfterUnrecoverableError(t *testing.T) {
+	s := newFallbackTestServer(401)
+	defer s.Close()
+	fp, _ := makeFallbackTestProcessor(s, func(c *Config) { c.StreamFallbackFailureCount = 1 })
+	defer fp.Close()
+
+	closeWhenReady := make(chan struct{})
+	fp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	assert.False(t, fp.Initialized())
+	_, polls := s.counts()
+	assert.Equal(t, 0, polls)
+}
+
+func TestDefaultUpdateProcessorUsesFallbackOnlyIfConfigured(t *testing.T) {
+	factory := createDefaultUpdateProcessor(nil)
+	config := DefaultConfig
+	up, _ := factory("sdkKey", config)
+	assert.IsType(t, &streamProcessor{}, up)
+
+	config.StreamFallbackFailureCount = 3
+	up, _ = factory("sdkKey", config)
+	assert.IsType(t, &streamingWithFallbackProcessor{}, up)
+}
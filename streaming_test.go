@@ -1,15 +1,19 @@
 package ldclient
 
 import (
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/launchdarkly/eventsource"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/launchdarkly/go-server-sdk.v4/internal"
 )
 
@@ -287,6 +291,121 @@ func testStreamProcessorRecoverableError(t *testing.T, statusCode int) {
 	assert.False(t, event.StreamInits[1].Failed)
 }
 
+func TestStreamProcessorReconnectsWithBackoffAfterStreamEnds(t *testing.T) {
+	connections := make(chan time.Time, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connections <- time.Now()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(200)
+		_, _ = w.Write([]byte("event: put\ndata: {\"path\": \"/\", \"data\": {\"flags\": {}, \"segments\": {}}}\n\n"))
+		// returning from the handler ends the stream
+	}))
+	defer ts.Close()
+
+	diagnosticsManager := newDiagnosticsManager(newDiagnosticId("sdkKey"), Config{}, time.Second, time.Now(), nil)
+	cfg := Config{
+		StreamUri:                   ts.URL,
+		FeatureStore:                NewInMemoryFeatureStore(log.New(ioutil.Discard, "", 0)),
+		Logger:                      log.New(ioutil.Discard, "", 0),
+		StreamInitialReconnectDelay: 100 * time.Millisecond,
+		diagnosticsManager:          diagnosticsManager,
+	}
+	sp := newStreamProcessor("sdkKey", cfg, nil)
+	defer sp.Close()
+	sp.Start(make(chan struct{}))
+
+	var times []time.Time
+	for i := 0; i < 3; i++ {
+		select {
+		case t := <-connections:
+			times = append(times, t)
+		case <-time.After(time.Second * 3):
+			assert.FailNow(t, "timed out waiting for stream to reconnect")
+		}
+	}
+	assert.True(t, times[1].Sub(times[0]) >= 100*time.Millisecond)
+	assert.True(t, times[2].Sub(times[1]) >= 200*time.Millisecond)
+	assert.True(t, len(diagnosticsManager.GetDiagnostics().DataSource.StreamInits) >= 2)
+}
+
+func TestStreamProcessorCanBeClosedAfterReconnectFailsFollowingSuccessfulConnection(t *testing.T) {
+	for _, statusCode := range []int{503, 401} {
+		t.Run(strconv.Itoa(statusCode), func(t *testing.T) {
+			failedAttempts := make(chan struct{}, 10)
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&attempts, 1) > 1 {
+					w.WriteHeader(statusCode)
+					failedAttempts <- struct{}{}
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write([]byte("event: put\ndata: {\"path\": \"/\", \"data\": {\"flags\": {}, \"segments\": {}}}\n\n"))
+				// returning from the handler ends the stream
+			}))
+			defer ts.Close()
+
+			cfg := Config{
+				StreamUri:                   ts.URL,
+				FeatureStore:                NewInMemoryFeatureStore(nil),
+				Logger:                      log.New(ioutil.Discard, "", 0),
+				StreamInitialReconnectDelay: 50 * time.Millisecond,
+			}
+			sp := newStreamProcessor("sdkKey", cfg, nil)
+			closeWhenReady := make(chan struct{})
+			sp.Start(closeWhenReady)
+
+			select {
+			case <-failedAttempts:
+			case <-time.After(time.Second * 3):
+				assert.FailNow(t, "timed out waiting for stream to reconnect")
+			}
+			<-closeWhenReady
+			sp.Close() // the processor is now waiting to retry, or has given up after the 401
+			time.Sleep(200 * time.Millisecond)
+			assert.True(t, sp.Initialized())
+		})
+	}
+}
+
+func TestStreamProcessorConnectionIsHealthyOnlyWhileStreamIsOpen(t *testing.T) {
+	endStream := make(chan struct{})
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) > 1 {
+			w.WriteHeader(503)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("event: put\ndata: {\"path\": \"/\", \"data\": {\"flags\": {}, \"segments\": {}}}\n\n"))
+		w.(http.Flusher).Flush()
+		<-endStream
+	}))
+	defer ts.Close()
+
+	cfg := Config{
+		StreamUri:                   ts.URL,
+		FeatureStore:                NewInMemoryFeatureStore(nil),
+		Logger:                      log.New(ioutil.Discard, "", 0),
+		StreamInitialReconnectDelay: time.Hour,
+	}
+	sp := newStreamProcessor("sdkKey", cfg, nil)
+	defer sp.Close()
+	assert.False(t, sp.isConnectionHealthy(time.Now()))
+	closeWhenReady := make(chan struct{})
+	sp.Start(closeWhenReady)
+	<-closeWhenReady
+	assert.True(t, sp.isConnectionHealthy(time.Now()))
+
+	close(endStream)
+	deadline := time.Now().Add(3 * time.Second)
+	for sp.isConnectionHealthy(time.Now()) {
+		require.True(t, time.Now().Before(deadline), "timed out waiting for stream to be closed")
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, sp.Initialized())
+}
+
 func TestStreamProcessorUsesHTTPClientFactory(t *testing.T) {
 	polledURLs := make(chan string, 1)
 
@@ -455,3 +574,263 @@ func (s *testStatusSubscription) Channel() <-chan internal.FeatureStoreStatus {
 func (s *testStatusSubscription) Close() {
 	close(s.ch)
 }
+
+func TestStreamProcessorFailsOverToNextEndpointAndFailsBack(t *testing.T) {
+	primary := newFallbackTestServer(503)
+	defer primary.Close()
+	secondary := newFallbackTestServer(200)
+	defer secondary.Close()
+
+	cfg := Config{
+		StreamUris:                  []string{primary.URL, secondary.URL},
+		FeatureStore:                NewInMemoryFeatureStore(log.New(ioutil.Discard, "", 0)),
+		Logger:                      log.New(ioutil.Discard, "", 0),
+		StreamInitialReconnectDelay: 10 * time.Millisecond,
+		EndpointFailbackInterval:    200 * time.Millisecond,
+	}
+	sp := newStreamProcessor("sdkKey", cfg, nil)
+	defer sp.Close()
+	closeWhenReady := make(chan struct{})
+	sp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	assert.True(t, sp.Initialized())
+	primaryStreams, _ := primary.counts()
+	secondaryStreams, _ := secondary.counts()
+	assert.Equal(t, 1, primaryStreams)
+	assert.Equal(t, 1, secondaryStreams)
+
+	primary.setStreamStatus(200)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if primaryStreams, _ = primary.counts(); primaryStreams == 2 {
+			break
+		}
+		require.True(t, time.Now().Before(deadline), "timed out waiting for stream to fail back")
+		time.Sleep(10 * time.Millisecond)
+	}
+	secondaryStreams, _ = secondary.counts()
+	assert.Equal(t, 1, secondaryStreams)
+}
+
+func TestStreamProcessorAppliesStoredFlagFilter(t *testing.T) {
+	esserver := eventsource.NewServer()
+	esserver.ReplayAll = true
+	esserver.Register("test", &testRepo{initialEvent: &testEvent{
+		event: putEvent,
+		data: `{"path": "/", "data": {
+"flags": {"wanted": {"key": "wanted", "version": 1}, "unwanted": {"key": "unwanted", "version": 1}},
+"segments": {"my-segment": {"key": "my-segment", "version": 1}}
+}}`,
+	}})
+	events := make(chan eventsource.Event, 10)
+	streamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		go func() {
+			for e := range events {
+				esserver.Publish([]string{"test"}, e)
+			}
+		}()
+		esserver.Handler("test").ServeHTTP(w, r)
+	}))
+	defer streamServer.Close()
+	defer esserver.Close()
+
+	sdkServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sdk/latest-flags/new-prereq":
+			w.Write([]byte(`{"key": "new-prereq", "version": 3}`))
+		case "/sdk/latest-segments/my-segment":
+			w.Write([]byte(`{"key": "my-segment", "version": 4}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer sdkServer.Close()
+
+	store := NewInMemoryFeatureStore(nil)
+	cfg := Config{
+		FeatureStore:   store,
+		StreamUri:      streamServer.URL,
+		BaseUri:        sdkServer.URL,
+		Logger:         log.New(ioutil.Discard, "", 0),
+		StoredFlagKeys: []string{"wanted"},
+	}
+	sp := newStreamProcessor("sdkKey", cfg, newRequestor("sdkKey", cfg, nil))
+	defer sp.Close()
+	closeWhenReady := make(chan struct{})
+	sp.Start(closeWhenReady)
+	awaitReady(t, closeWhenReady)
+
+	waitForVersion(t, store, Features, "wanted", 1)
+	unwanted, _ := store.Get(Features, "unwanted")
+	assert.Nil(t, unwanted)
+	segment, _ := store.Get(Segments, "my-segment")
+	assert.Nil(t, segment)
+
+	events <- &testEvent{event: patchEvent, data: `{"path": "/flags/unwanted", "data": {"key": "unwanted", "version": 2}}`}
+	events <- &testEvent{event: patchEvent, data: `{"path": "/flags/wanted", "data": {"key": "wanted", "version": 2,
+"prerequisites": [{"key": "new-prereq", "variation": 0}],
+"rules": [{"clauses": [{"attribute": "", "op": "segmentMatch", "values": ["my-segment"]}]}]}}`}
+
+	waitForVersion(t, store, Features, "wanted", 2)
+	prereq, _ := store.Get(Features, "new-prereq")
+	require.NotNil(t, prereq)
+	assert.Equal(t, 3, prereq.GetVersion())
+	segment, _ = store.Get(Segments, "my-segment")
+	require.NotNil(t, segment)
+	assert.Equal(t, 4, segment.GetVersion())
+	unwanted, _ = store.Get(Features, "unwanted")
+	assert.Nil(t, unwanted)
+}
+
+// Returns a stream server that sends the given events on each successive connection, and records the
+// Last-Event-ID header of each connection. Each connection but the last is closed after its events.
+func makeResumableStreamServer(connections [][]string, lastEventIDs chan<- string) *httptest.Server {
+	var count int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&count, 1)) - 1
+		lastEventIDs <- r.Header.Get("Last-Event-ID")
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n < len(connections) {
+			for _, e := range connections[n] {
+				w.Write([]byte(e))
+			}
+		}
+		w.(http.Flusher).Flush()
+		if n >= len(connections)-1 {
+			<-r.Context().Done()
+		}
+	}))
+}
+
+func makeSSEEvent(id, event, data string) string {
+	return fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", id, event, data)
+}
+
+func awaitStoredVersion(t *testing.T, store FeatureStore, key string, version int) {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if item, _ := store.Get(Features, key); item != nil && item.GetVersion() == version {
+			return
+		}
+		require.True(t, time.Now().Before(deadline), `timed out waiting for version %d of "%s"`, version, key)
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func runResumableStreamTest(t *testing.T, connections [][]string, sdkHandler http.HandlerFunc,
+	test func(store FeatureStore, lastEventIDs <-chan string)) {
+	lastEventIDs := make(chan string, 10)
+	streamServer := makeResumableStreamServer(connections, lastEventIDs)
+	defer streamServer.Close()
+	sdkServer := httptest.NewServer(sdkHandler)
+	defer sdkServer.Close()
+
+	store := NewInMemoryFeatureStore(nil)
+	cfg := Config{
+		FeatureStore:                store,
+		StreamUri:                   streamServer.URL,
+		BaseUri:                     sdkServer.URL,
+		Logger:                      log.New(ioutil.Discard, "", 0),
+		StreamInitialReconnectDelay: time.Millisecond,
+	}
+	sp := newStreamProcessor("sdkKey", cfg, newRequestor("sdkKey", cfg, nil))
+	defer sp.Close()
+	sp.Start(make(chan struct{}))
+
+	test(store, lastEventIDs)
+}
+
+func TestStreamProcessorSendsLastEventIDOnReconnect(t *testing.T) {
+	connections := [][]string{
+		{
+			makeSSEEvent("1", putEvent, `{"path": "/", "data": {"flags": {"my-flag": {"key": "my-flag", "version": 1}}, "segments": {}}}`),
+			makeSSEEvent("2", patchEvent, `{"path": "/flags/my-flag", "data": {"key": "my-flag", "version": 2}}`),
+		},
+		{
+			makeSSEEvent("3", patchEvent, `{"path": "/flags/my-flag", "data": {"key": "my-flag", "version": 3}}`),
+		},
+	}
+	runResumableStreamTest(t, connections, nullHandler.ServeHTTP, func(store FeatureStore, lastEventIDs <-chan string) {
+		assert.Equal(t, "", <-lastEventIDs)
+		assert.Equal(t, "2", <-lastEventIDs)
+		awaitStoredVersion(t, store, "my-flag", 3)
+	})
+}
+
+func TestStreamProcessorIgnoresVersionGapInUninterruptedStream(t *testing.T) {
+	connections := [][]string{
+		{
+			makeSSEEvent("1", putEvent, `{"path": "/", "data": {"flags": {"my-flag": {"key": "my-flag", "version": 1}}, "segments": {}}}`),
+			makeSSEEvent("2", patchEvent, `{"path": "/flags/my-flag", "data": {"key": "my-flag", "version": 3}}`),
+		},
+	}
+	sdkHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "unexpected request", r.URL.Path)
+	}
+	runResumableStreamTest(t, connections, sdkHandler, func(store FeatureStore, lastEventIDs <-chan string) {
+		awaitStoredVersion(t, store, "my-flag", 3)
+	})
+}
+
+func TestStreamProcessorRequestsAllDataAfterVersionGapInResumedStream(t *testing.T) {
+	connections := [][]string{
+		{
+			makeSSEEvent("1", putEvent, `{"path": "/", "data": {"flags": {"my-flag": {"key": "my-flag", "version": 1}}, "segments": {}}}`),
+		},
+		{
+			makeSSEEvent("5", patchEvent, `{"path": "/flags/my-flag", "data": {"key": "my-flag", "version": 3}}`),
+		},
+	}
+	sdkHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, LatestAllPath, r.URL.Path)
+		w.Write([]byte(`{"flags": {"my-flag": {"key": "my-flag", "version": 3},
+"other-flag": {"key": "other-flag", "version": 1}}, "segments": {}}`))
+	}
+	runResumableStreamTest(t, connections, sdkHandler, func(store FeatureStore, lastEventIDs <-chan string) {
+		assert.Equal(t, "", <-lastEventIDs)
+		assert.Equal(t, "1", <-lastEventIDs)
+		awaitStoredVersion(t, store, "other-flag", 1)
+		awaitStoredVersion(t, store, "my-flag", 3)
+	})
+}
+
+func TestStreamProcessorIgnoresVersionGapAfterFirstPatchInResumedStream(t *testing.T) {
+	connections := [][]string{
+		{
+			makeSSEEvent("1", putEvent, `{"path": "/", "data": {"flags": {"my-flag": {"key": "my-flag", "version": 1}}, "segments": {}}}`),
+		},
+		{
+			makeSSEEvent("2", patchEvent, `{"path": "/flags/my-flag", "data": {"key": "my-flag", "version": 2}}`),
+			makeSSEEvent("3", patchEvent, `{"path": "/flags/my-flag", "data": {"key": "my-flag", "version": 5}}`),
+		},
+	}
+	sdkHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "unexpected request", r.URL.Path)
+	}
+	runResumableStreamTest(t, connections, sdkHandler, func(store FeatureStore, lastEventIDs <-chan string) {
+		assert.Equal(t, "", <-lastEventIDs)
+		assert.Equal(t, "1", <-lastEventIDs)
+		awaitStoredVersion(t, store, "my-flag", 5)
+	})
+}
+
+func TestStreamProcessorIgnoresVersionGapAfterFirstDeleteInResumedStream(t *testing.T) {
+	connections := [][]string{
+		{
+			makeSSEEvent("1", putEvent, `{"path": "/", "data": {"flags": {"my-flag": {"key": "my-flag", "version": 1}, "other-flag": {"key": "other-flag", "version": 1}}, "segments": {}}}`),
+		},
+		{
+			makeSSEEvent("2", deleteEvent, `{"path": "/flags/other-flag", "version": 2}`),
+			makeSSEEvent("3", patchEvent, `{"path": "/flags/my-flag", "data": {"key": "my-flag", "version": 5}}`),
+		},
+	}
+	sdkHandler := func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "unexpected request", r.URL.Path)
+	}
+	runResumableStreamTest(t, connections, sdkHandler, func(store FeatureStore, lastEventIDs <-chan string) {
+		assert.Equal(t, "", <-lastEventIDs)
+		assert.Equal(t, "1", <-lastEventIDs)
+		awaitStoredVersion(t, store, "my-flag", 5)
+	})
+}
@@ -102,7 +102,8 @@ type User struct {
 
 	// This contains list of attributes to keep private, whether they appear at the top-level or Custom
 	// The attribute "key" is always sent regardless of whether it is in this list, and "custom" cannot be used to
-	// eliminate all custom attributes
+	// eliminate all custom attributes. A name beginning with a slash, such as "/billing/cardLast4", refers to a
+	// property within a custom attribute whose value is a JSON object.
 	//
 	// Deprecated: Direct access to User fields is now deprecated in favor of UserBuilder. In a future version,
 	// User fields will be private and only accessible via getter methods.
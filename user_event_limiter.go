@@ -0,0 +1,57 @@
+package ldclient
+
+import (
+	"container/list"
+)
+
+// Counts events per user key within a time window, so that the event processor can limit how many
+// individual events it sends for any one user. The number of user keys that are tracked is bounded;
+// when the capacity is reached, the least recently seen user is forgotten. Like lruCache, this is not
+// thread-safe, because it is only used from the event processor's single event-processing goroutine.
+type userEventLimiter struct {
+	limit    int
+	counts   map[string]*list.Element
+	lruList  *list.List
+	capacity int
+}
+
+type userEventCount struct {
+	userKey string
+	count   int
+}
+
+func newUserEventLimiter(limit int, capacity int) userEventLimiter {
+	return userEventLimiter{
+		limit:    limit,
+		counts:   make(map[string]*list.Element),
+		lruList:  list.New(),
+		capacity: capacity,
+	}
+}
+
+// Counts an event for this user, and returns true if the user is still within the limit. A limit of
+// zero means there is no limit.
+func (l *userEventLimiter) allow(user User) bool {
+	if l.limit <= 0 || user.Key == nil || l.capacity <= 0 {
+		return true
+	}
+	if e, ok := l.counts[*user.Key]; ok {
+		l.lruList.MoveToFront(e)
+		uc := e.Value.(*userEventCount)
+		uc.count++
+		return uc.count <= l.limit
+	}
+	for len(l.counts) >= l.capacity {
+		oldest := l.lruList.Back()
+		delete(l.counts, oldest.Value.(*userEventCount).userKey)
+		l.lruList.Remove(oldest)
+	}
+	l.counts[*user.Key] = l.lruList.PushFront(&userEventCount{userKey: *user.Key, count: 1})
+	return true
+}
+
+// Starts a new time window.
+func (l *userEventLimiter) clear() {
+	l.counts = make(map[string]*list.Element)
+	l.lruList.Init()
+}
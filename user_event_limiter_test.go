@@ -0,0 +1,49 @@
+package ldclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserEventLimiter(t *testing.T) {
+	t.Run("allows events up to the limit", func(t *testing.T) {
+		limiter := newUserEventLimiter(2, 10)
+		user := NewUser("a")
+		assert.True(t, limiter.allow(user))
+		assert.True(t, limiter.allow(user))
+		assert.False(t, limiter.allow(user))
+		assert.True(t, limiter.allow(NewUser("b")))
+	})
+
+	t.Run("limit of zero means no limit", func(t *testing.T) {
+		limiter := newUserEventLimiter(0, 10)
+		for i := 0; i < 100; i++ {
+			assert.True(t, limiter.allow(NewUser("a")))
+		}
+	})
+
+	t.Run("clear starts a new window", func(t *testing.T) {
+		limiter := newUserEventLimiter(1, 10)
+		limiter.allow(NewUser("a"))
+		assert.False(t, limiter.allow(NewUser("a")))
+		limiter.clear()
+		assert.True(t, limiter.allow(NewUser("a")))
+	})
+
+	t.Run("least recently seen user is forgotten when capacity is exceeded", func(t *testing.T) {
+		limiter := newUserEventLimiter(1, 2)
+		limiter.allow(NewUser("a"))
+		limiter.allow(NewUser("b"))
+		limiter.allow(NewUser("c"))
+		assert.False(t, limiter.allow(NewUser("c")))
+		assert.False(t, limiter.allow(NewUser("b")))
+		assert.True(t, limiter.allow(NewUser("a")))
+	})
+
+	t.Run("user without key is not limited", func(t *testing.T) {
+		limiter := newUserEventLimiter(1, 10)
+		assert.True(t, limiter.allow(User{}))
+		assert.True(t, limiter.allow(User{}))
+	})
+}
@@ -1,7 +1,11 @@
 package ldclient
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"strings"
 
 	"gopkg.in/launchdarkly/go-server-sdk.v4/ldlog"
 )
@@ -14,14 +18,29 @@ type serializableUser struct {
 type userFilter struct {
 	allAttributesPrivate    bool
 	globalPrivateAttributes []string
+	onlyPublicAttributes    bool
+	isPublic                map[string]bool
+	hashKey                 []byte
+	hashUserKey             bool
 	loggers                 ldlog.Loggers
 	logUserKeyInErrors      bool
 }
 
 func newUserFilter(config Config) userFilter {
+	var isPublic map[string]bool
+	if config.OnlyPublicAttributes {
+		isPublic = make(map[string]bool, len(config.PublicAttributeNames))
+		for _, n := range config.PublicAttributeNames {
+			isPublic[n] = true
+		}
+	}
 	return userFilter{
 		allAttributesPrivate:    config.AllAttributesPrivate,
 		globalPrivateAttributes: config.PrivateAttributeNames,
+		onlyPublicAttributes:    config.OnlyPublicAttributes,
+		isPublic:                isPublic,
+		hashKey:                 config.PrivateAttributeHashKey,
+		hashUserKey:             config.HashUserKeyInEvents && len(config.PrivateAttributeHashKey) > 0,
 		loggers:                 config.Loggers,
 		logUserKeyInErrors:      config.LogUserKeyInErrors,
 	}
@@ -34,7 +53,18 @@ const userSerializationErrorMessage = "An error occurred while processing custom
 // Returns a version of the user data that is suitable for JSON serialization in event data.
 // If neither the configuration nor the user specifies any private attributes, then this is the same
 // as the original user. Otherwise, it is a copy which may have some attributes removed (with the
-// PrivateAttributes property set to a list of their names).
+// PrivateAttributes property set to a list of their names). If a hash key is configured, private
+// attributes are replaced with a keyed hash of their value rather than being removed, and they are
+// still listed in PrivateAttributes; the user key may also be hashed, in which case "key" is listed.
+//
+// A private attribute name that begins with a slash is a path to a property within a custom attribute
+// whose value is a JSON object, such as "/billing/cardLast4" (see parsePrivateAttributePath). Only that
+// property is removed, and the path exactly as it was specified is added to PrivateAttributes. If the
+// custom attribute's value cannot be inspected, the whole attribute is treated as private. A custom
+// attribute whose name is literally the same as the path, slash included, is also private.
+//
+// If OnlyPublicAttributes is set, every attribute other than the key and anonymous is private unless
+// it is listed in PublicAttributeNames (and not also listed as private).
 //
 // This function, and the custom marshaller for serializableUser, also guard against a potential
 // concurrent modification error on the user's custom attributes map. We can't prevent someone in
@@ -44,16 +74,28 @@ const userSerializationErrorMessage = "An error occurred while processing custom
 // way to know whether they are still correct after the concurrent modification).
 func (uf *userFilter) scrubUser(user User) (ret *serializableUser) {
 	ret = &serializableUser{User: user, filter: uf}
-	if len(user.PrivateAttributeNames) == 0 && len(uf.globalPrivateAttributes) == 0 && !uf.allAttributesPrivate {
+	if len(user.PrivateAttributeNames) == 0 && len(uf.globalPrivateAttributes) == 0 && !uf.allAttributesPrivate &&
+		!uf.onlyPublicAttributes && !uf.hashUserKey {
 		return
 	}
 
 	isPrivate := map[string]bool{}
-	for _, n := range uf.globalPrivateAttributes {
-		isPrivate[n] = true
+	privatePaths := map[string][]privateAttributePath{} // keyed by the name of the top-level custom attribute
+	for _, names := range [][]string{uf.globalPrivateAttributes, user.PrivateAttributeNames} {
+		for _, n := range names {
+			// The name is always private as it was written, in case there is a custom attribute that
+			// literally has that name, even if it also refers to a nested property.
+			isPrivate[n] = true
+			path := parsePrivateAttributePath(n)
+			if len(path) == 1 {
+				isPrivate[path[0]] = true
+			} else if len(path) > 1 {
+				privatePaths[path[0]] = appendPrivateAttributePath(privatePaths[path[0]], privateAttributePath{n, path[1:]})
+			}
+		}
 	}
-	for _, n := range user.PrivateAttributeNames {
-		isPrivate[n] = true
+	attrIsPrivate := func(name string) bool {
+		return uf.allAttributesPrivate || isPrivate[name] || (uf.onlyPublicAttributes && !uf.isPublic[name])
 	}
 	ret.User.PrivateAttributeNames = nil // this property is not used in the output schema for events
 	ret.User.PrivateAttributes = nil     // see below
@@ -63,43 +105,48 @@ func (uf *userFilter) scrubUser(user User) (ret *serializableUser) {
 	// attributes enabled. This allows us to reuse the event processor code in ld-relay, where we may have to
 	// reprocess events that have already been through the scrubbing process.
 
-	if !isEmpty(user.Avatar) && (uf.allAttributesPrivate || isPrivate["avatar"]) {
-		ret.User.Avatar = nil
+	if uf.hashUserKey && user.Key != nil {
+		ret.User.Key = uf.filterUserKey(user.Key)
+		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "key")
+	}
+
+	if !isEmpty(user.Avatar) && attrIsPrivate("avatar") {
+		ret.User.Avatar = uf.redactString(user.Avatar)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "avatar")
 	}
 
-	if !isEmpty(user.Country) && (uf.allAttributesPrivate || isPrivate["country"]) {
-		ret.User.Country = nil
+	if !isEmpty(user.Country) && attrIsPrivate("country") {
+		ret.User.Country = uf.redactString(user.Country)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "country")
 	}
 
-	if !isEmpty(user.Ip) && (uf.allAttributesPrivate || isPrivate["ip"]) {
-		ret.User.Ip = nil
+	if !isEmpty(user.Ip) && attrIsPrivate("ip") {
+		ret.User.Ip = uf.redactString(user.Ip)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "ip")
 	}
 
-	if !isEmpty(user.FirstName) && (uf.allAttributesPrivate || isPrivate["firstName"]) {
-		ret.User.FirstName = nil
+	if !isEmpty(user.FirstName) && attrIsPrivate("firstName") {
+		ret.User.FirstName = uf.redactString(user.FirstName)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "firstName")
 	}
 
-	if !isEmpty(user.LastName) && (uf.allAttributesPrivate || isPrivate["lastName"]) {
-		ret.User.LastName = nil
+	if !isEmpty(user.LastName) && attrIsPrivate("lastName") {
+		ret.User.LastName = uf.redactString(user.LastName)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "lastName")
 	}
 
-	if !isEmpty(user.Name) && (uf.allAttributesPrivate || isPrivate["name"]) {
-		ret.User.Name = nil
+	if !isEmpty(user.Name) && attrIsPrivate("name") {
+		ret.User.Name = uf.redactString(user.Name)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "name")
 	}
 
-	if !isEmpty(user.Secondary) && (uf.allAttributesPrivate || isPrivate["secondary"]) {
-		ret.User.Secondary = nil
+	if !isEmpty(user.Secondary) && attrIsPrivate("secondary") {
+		ret.User.Secondary = uf.redactString(user.Secondary)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "secondary")
 	}
 
-	if !isEmpty(user.Email) && (uf.allAttributesPrivate || isPrivate["email"]) {
-		ret.User.Email = nil
+	if !isEmpty(user.Email) && attrIsPrivate("email") {
+		ret.User.Email = uf.redactString(user.Email)
 		ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, "email")
 	}
 
@@ -114,8 +161,17 @@ func (uf *userFilter) scrubUser(user User) (ret *serializableUser) {
 		}()
 		var custom = map[string]interface{}{}
 		for k, v := range *user.Custom {
-			if uf.allAttributesPrivate || isPrivate[k] {
+			if attrIsPrivate(k) {
 				ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, k)
+				if hashed, ok := uf.redactValue(v); ok {
+					custom[k] = hashed
+				}
+			} else if paths := privatePaths[k]; len(paths) > 0 {
+				if redacted, ok := uf.redactPaths(v, paths, &ret.User.PrivateAttributes); ok {
+					custom[k] = redacted
+				} else {
+					ret.User.PrivateAttributes = append(ret.User.PrivateAttributes, k)
+				}
 			} else {
 				custom[k] = v
 			}
@@ -155,6 +211,126 @@ func (u serializableUser) MarshalJSON() (output []byte, err error) {
 	return
 }
 
+// A private attribute path, as parsed from a private attribute name such as "/billing/cardLast4".
+type privateAttributePath struct {
+	name     string   // the name as it was specified, which is what will appear in privateAttrs
+	segments []string // the property names below the top-level custom attribute
+}
+
+// Parses a private attribute name that refers to a nested property. Such names begin with a slash and
+// use JSON Pointer syntax (RFC 6901): "/billing/cardLast4" is the "cardLast4" property of the object in
+// the "billing" custom attribute, and a slash or tilde within a property name is written as "~1" or
+// "~0". Array indexes are not supported. Returns nil if the name is not a path.
+func parsePrivateAttributePath(name string) []string {
+	if !strings.HasPrefix(name, "/") {
+		return nil
+	}
+	segments := strings.Split(name[1:], "/")
+	for i, s := range segments {
+		segments[i] = strings.Replace(strings.Replace(s, "~1", "/", -1), "~0", "~", -1)
+	}
+	return segments
+}
+
+func appendPrivateAttributePath(paths []privateAttributePath, path privateAttributePath) []privateAttributePath {
+	for _, p := range paths {
+		if p.name == path.name {
+			return paths // the same path was specified both globally and for this user
+		}
+	}
+	return append(paths, path)
+}
+
+// Returns a copy of a custom attribute value with the properties at the given paths removed (or hashed),
+// adding the name of each path that was found to privateAttrs. The original value is not modified.
+// Returns false if the value could not be converted to a generic JSON representation.
+func (uf *userFilter) redactPaths(value interface{}, paths []privateAttributePath, privateAttrs *[]string) (interface{}, bool) {
+	if _, ok := value.(map[string]interface{}); !ok {
+		// Values set with UserBuilder are already in this form, but a value that was put directly into
+		// User.Custom could be of any type. We can only redact properties if we can see them.
+		bytes, err := json.Marshal(value)
+		if err != nil || json.Unmarshal(bytes, &value) != nil {
+			return nil, false
+		}
+	}
+	for _, p := range paths {
+		var found bool
+		if value, found = uf.redactPath(value, p.segments); found {
+			*privateAttrs = append(*privateAttrs, p.name)
+		}
+	}
+	return value, true
+}
+
+func (uf *userFilter) redactPath(value interface{}, segments []string) (interface{}, bool) {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return value, false
+	}
+	child, found := obj[segments[0]]
+	if !found {
+		return value, false
+	}
+	newChild, keep := child, true
+	if len(segments) == 1 {
+		newChild, keep = uf.redactValue(child)
+	} else if newChild, found = uf.redactPath(child, segments[1:]); !found {
+		return value, false
+	}
+	copied := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		copied[k] = v
+	}
+	if keep {
+		copied[segments[0]] = newChild
+	} else {
+		delete(copied, segments[0])
+	}
+	return copied, true
+}
+
+// Returns the replacement for a private string attribute: nil if it should be removed, or a pointer
+// to its hash if a hash key is configured.
+func (uf *userFilter) redactString(value *string) *string {
+	if len(uf.hashKey) == 0 {
+		return nil
+	}
+	hashed := uf.hashString(*value)
+	return &hashed
+}
+
+// Returns the replacement for a private custom attribute value, and false if it should be removed. A
+// string is hashed as-is; any other type of value is hashed in its JSON representation.
+func (uf *userFilter) redactValue(value interface{}) (interface{}, bool) {
+	if len(uf.hashKey) == 0 {
+		return nil, false
+	}
+	if s, ok := value.(string); ok {
+		return uf.hashString(s), true
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	return uf.hashString(string(bytes)), true
+}
+
+// Returns the user key as it should appear in event data, which is the hashed key if HashUserKeyInEvents
+// is enabled.
+func (uf *userFilter) filterUserKey(key *string) *string {
+	if key == nil || !uf.hashUserKey {
+		return key
+	}
+	hashed := uf.hashString(*key)
+	return &hashed
+}
+
+func (uf *userFilter) hashString(value string) string {
+	h := hmac.New(sha256.New, uf.hashKey)
+	_, _ = h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func isEmpty(s *string) bool {
 	return s == nil || *s == ""
 }
@@ -101,6 +101,177 @@ func TestScrubUser(t *testing.T) {
 		scrubbedUser := *filter.scrubUser(user)
 		assert.Equal(t, user, scrubbedUser.User)
 	})
+
+	t.Run("private nested property", func(t *testing.T) {
+		filter := newUserFilter(Config{PrivateAttributeNames: []string{"/billing/card/last4"}})
+		billing := ldvalue.ObjectBuild().
+			Set("plan", ldvalue.String("gold")).
+			Set("card", ldvalue.ObjectBuild().
+				Set("last4", ldvalue.String("1234")).
+				Set("brand", ldvalue.String("visa")).Build()).
+			Build()
+		user := NewUserBuilder(userKey).Custom("billing", billing).Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, []string{"/billing/card/last4"}, scrubbedUser.PrivateAttributes)
+		expected := map[string]interface{}{
+			"plan": "gold",
+			"card": map[string]interface{}{"brand": "visa"},
+		}
+		assert.Equal(t, expected, (*scrubbedUser.Custom)["billing"])
+		assert.Equal(t, billing.AsArbitraryValue(), (*user.Custom)["billing"]) // original is not modified
+	})
+
+	t.Run("private nested property per user", func(t *testing.T) {
+		filter := newUserFilter(DefaultConfig)
+		user := NewUserBuilder(userKey).
+			Custom("a", ldvalue.ObjectBuild().Set("b/c", ldvalue.Int(1)).Set("d", ldvalue.Int(2)).Build()).
+			Build()
+		user.PrivateAttributeNames = []string{"/a/b~1c", "/a/missing"}
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, []string{"/a/b~1c"}, scrubbedUser.PrivateAttributes)
+		assert.Equal(t, map[string]interface{}{"d": float64(2)}, (*scrubbedUser.Custom)["a"])
+	})
+
+	t.Run("single-segment path is the same as a top-level name", func(t *testing.T) {
+		filter := newUserFilter(Config{PrivateAttributeNames: []string{"/email", "/tier"}})
+		user := NewUserBuilder(userKey).Email("me@example.com").Custom("tier", ldvalue.String("gold")).Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		sort.Strings(scrubbedUser.PrivateAttributes)
+		assert.Equal(t, []string{"email", "tier"}, scrubbedUser.PrivateAttributes)
+		assert.Nil(t, scrubbedUser.Email)
+		assert.Nil(t, scrubbedUser.Custom)
+	})
+
+	t.Run("custom attribute whose name begins with a slash", func(t *testing.T) {
+		filter := newUserFilter(Config{PrivateAttributeNames: []string{"/foo", "/bar/baz"}})
+		user := NewUserBuilder(userKey).
+			Custom("/foo", ldvalue.String("x")).
+			Custom("/bar/baz", ldvalue.String("y")).
+			Custom("plain", ldvalue.String("z")).
+			Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		sort.Strings(scrubbedUser.PrivateAttributes)
+		assert.Equal(t, []string{"/bar/baz", "/foo"}, scrubbedUser.PrivateAttributes)
+		assert.Equal(t, map[string]interface{}{"plain": "z"}, *scrubbedUser.Custom)
+	})
+
+	t.Run("nested property is hashed if hash key is set", func(t *testing.T) {
+		filter := newUserFilter(Config{PrivateAttributeNames: []string{"/billing/last4"},
+			PrivateAttributeHashKey: []byte("secret")})
+		user := NewUserBuilder(userKey).
+			Custom("billing", ldvalue.ObjectBuild().Set("last4", ldvalue.String("1234")).Build()).
+			Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, []string{"/billing/last4"}, scrubbedUser.PrivateAttributes)
+		assert.Equal(t, map[string]interface{}{"last4": filter.hashString("1234")}, (*scrubbedUser.Custom)["billing"])
+	})
+
+	t.Run("nested property in a non-generic value", func(t *testing.T) {
+		type billingInfo struct {
+			Plan  string `json:"plan"`
+			Last4 string `json:"last4"`
+		}
+		filter := newUserFilter(Config{PrivateAttributeNames: []string{"/billing/last4"}})
+		user := NewUser(userKey)
+		user.Custom = &map[string]interface{}{"billing": billingInfo{Plan: "gold", Last4: "1234"}}
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, []string{"/billing/last4"}, scrubbedUser.PrivateAttributes)
+		assert.Equal(t, map[string]interface{}{"plan": "gold"}, (*scrubbedUser.Custom)["billing"])
+	})
+
+	t.Run("only public attributes", func(t *testing.T) {
+		filter := newUserFilter(Config{OnlyPublicAttributes: true, PublicAttributeNames: []string{"country", "plan"}})
+		user := NewUserBuilder(userKey).
+			Name("sammy").
+			Country("freedonia").
+			Email("me@example.com").
+			Anonymous(true).
+			Custom("plan", ldvalue.String("gold")).
+			Custom("new-attr", ldvalue.String("surprise")).
+			Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		sort.Strings(scrubbedUser.PrivateAttributes)
+		assert.Equal(t, []string{"email", "name", "new-attr"}, scrubbedUser.PrivateAttributes)
+		expected := NewUserBuilder(userKey).
+			Country("freedonia").
+			Anonymous(true).
+			Custom("plan", ldvalue.String("gold")).
+			Build()
+		scrubbedUser.PrivateAttributes = nil
+		assert.Equal(t, expected, scrubbedUser.User)
+	})
+
+	t.Run("private attribute names take precedence over public attribute names", func(t *testing.T) {
+		filter := newUserFilter(Config{OnlyPublicAttributes: true, PublicAttributeNames: []string{"country", "name"},
+			PrivateAttributeNames: []string{"name"}})
+		user := NewUserBuilder(userKey).Name("sammy").Country("freedonia").Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, []string{"name"}, scrubbedUser.PrivateAttributes)
+		assert.Equal(t, "freedonia", scrubbedUser.GetCountry().StringValue())
+	})
+
+	t.Run("public attribute names are ignored unless allowlist mode is on", func(t *testing.T) {
+		filter := newUserFilter(Config{PublicAttributeNames: []string{"country"}})
+		user := NewUserBuilder(userKey).Name("sammy").Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, user, scrubbedUser.User)
+	})
+
+	t.Run("private attributes are hashed if hash key is set", func(t *testing.T) {
+		filter := newUserFilter(Config{PrivateAttributeNames: []string{"email", "tier", "count"},
+			PrivateAttributeHashKey: []byte("secret")})
+		user := NewUserBuilder(userKey).
+			Email("me@example.com").
+			Name("sammy").
+			Custom("tier", ldvalue.String("gold")).
+			Custom("count", ldvalue.Int(3)).
+			Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		sort.Strings(scrubbedUser.PrivateAttributes)
+		assert.Equal(t, []string{"count", "email", "tier"}, scrubbedUser.PrivateAttributes)
+		assert.Equal(t, userKey, scrubbedUser.GetKey())
+		assert.Equal(t, "sammy", scrubbedUser.GetName().StringValue())
+		assert.Equal(t, filter.hashString("me@example.com"), scrubbedUser.GetEmail().StringValue())
+		assert.Equal(t, filter.hashString("gold"), (*scrubbedUser.Custom)["tier"])
+		assert.Equal(t, filter.hashString("3"), (*scrubbedUser.Custom)["count"])
+		assert.NotEqual(t, "me@example.com", scrubbedUser.GetEmail().StringValue())
+	})
+
+	t.Run("hashes are stable and depend on the key", func(t *testing.T) {
+		filter1 := newUserFilter(Config{PrivateAttributeHashKey: []byte("key1")})
+		filter2 := newUserFilter(Config{PrivateAttributeHashKey: []byte("key2")})
+		assert.Equal(t, filter1.hashString("value"), filter1.hashString("value"))
+		assert.NotEqual(t, filter1.hashString("value"), filter2.hashString("value"))
+		assert.Equal(t, 64, len(filter1.hashString("value")))
+	})
+
+	t.Run("user key is hashed if enabled", func(t *testing.T) {
+		filter := newUserFilter(Config{PrivateAttributeHashKey: []byte("secret"), HashUserKeyInEvents: true})
+		user := NewUserBuilder(userKey).Name("sammy").Build()
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, filter.hashString(userKey), scrubbedUser.GetKey())
+		assert.Equal(t, "sammy", scrubbedUser.GetName().StringValue())
+		assert.Equal(t, []string{"key"}, scrubbedUser.PrivateAttributes)
+	})
+
+	t.Run("user key is not hashed without hash key", func(t *testing.T) {
+		filter := newUserFilter(Config{HashUserKeyInEvents: true})
+		user := NewUser(userKey)
+
+		scrubbedUser := *filter.scrubUser(user)
+		assert.Equal(t, user, scrubbedUser.User)
+	})
 }
 
 func TestUserSerialization(t *testing.T) {
@@ -4,9 +4,11 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/singleflight"
@@ -84,6 +86,32 @@ type FeatureStoreCoreStatus interface {
 	IsStoreAvailable() bool
 }
 
+// FeatureStoreCoreHeartbeat is an optional interface that can be implemented by FeatureStoreCoreBase
+// implementations for data stores that are shared between processes. It allows the process that updates
+// the store to record that the data is still current, so that processes which only read from the store
+// can tell how old the data is. See ldclient.Config.FeatureStoreHeartbeatInterval.
+type FeatureStoreCoreHeartbeat interface {
+	// WriteHeartbeatInternal records the time when the data was last known to be current. This should
+	// be stored separately from the flags and segments, and should not be removed by InitInternal.
+	WriteHeartbeatInternal(t time.Time) error
+	// ReadHeartbeatInternal returns the time that was most recently written by WriteHeartbeatInternal,
+	// or a zero time if there is none.
+	ReadHeartbeatInternal() (time.Time, error)
+}
+
+// FeatureStoreCorePollingCache is an optional interface that can be implemented by FeatureStoreCoreBase
+// implementations for data stores that are shared between processes. It allows the SDK to save the last
+// response from the LaunchDarkly polling service in the data store. See
+// ldclient.Config.PollCacheInFeatureStore.
+type FeatureStoreCorePollingCache interface {
+	// WritePollingCacheInternal saves the response data, replacing any previous data. This should be
+	// stored separately from the flags and segments, and should not be removed by InitInternal.
+	WritePollingCacheInternal(data []byte) error
+	// ReadPollingCacheInternal returns the data that was most recently written by
+	// WritePollingCacheInternal, or nil if there is none.
+	ReadPollingCacheInternal() ([]byte, error)
+}
+
 // FeatureStoreCore is an interface for a simplified subset of the functionality of
 // ldclient.FeatureStore, to be used in conjunction with FeatureStoreWrapper. This allows
 // developers of custom FeatureStore implementations to avoid repeating logic that would
@@ -138,6 +166,8 @@ type StoreCollection struct {
 // will make it possible for SDK components to react appropriately if the availability of the store
 // changes (e.g. if we lose a database connection, but then regain it).
 type FeatureStoreWrapper struct {
+	cacheHits     int64 // accessed atomically; must be 64-bit aligned
+	cacheMisses   int64
 	core          FeatureStoreCoreBase
 	coreAtomic    FeatureStoreCore
 	coreNonAtomic NonAtomicFeatureStoreCore
@@ -152,6 +182,10 @@ type FeatureStoreWrapper struct {
 
 const initCheckedKey = "$initChecked"
 
+var errHeartbeatNotSupported = errors.New("data store does not support heartbeats")
+
+var errPollingCacheNotSupported = errors.New("data store does not support saving polling responses")
+
 // NewFeatureStoreWrapperWithConfig creates an instance of FeatureStoreWrapper that wraps an instance
 // of FeatureStoreCore. It takes a Config parameter so that it can use the same logging configuration
 // as the SDK.
@@ -287,12 +321,15 @@ func (w *FeatureStoreWrapper) Get(kind ld.VersionedDataKind, key string) (ld.Ver
 	cacheKey := featureStoreCacheKey(kind, key)
 	if data, present := w.cache.Get(cacheKey); present {
 		if data == nil { // If present is true but data is nil, we have cached the absence of an item
+			atomic.AddInt64(&w.cacheHits, 1)
 			return nil, nil
 		}
 		if item, ok := data.(ld.VersionedData); ok {
+			atomic.AddInt64(&w.cacheHits, 1)
 			return itemOnlyIfNotDeleted(item), nil
 		}
 	}
+	atomic.AddInt64(&w.cacheMisses, 1)
 	// Item was not cached or cached value was not valid. Use singleflight to ensure that we'll only
 	// do this core query once even if multiple goroutines are requesting it
 	reqKey := fmt.Sprintf("get:%s:%s", kind.GetNamespace(), key)
@@ -332,9 +369,11 @@ func (w *FeatureStoreWrapper) All(kind ld.VersionedDataKind) (map[string]ld.Vers
 	cacheKey := featureStoreAllItemsCacheKey(kind)
 	if data, present := w.cache.Get(cacheKey); present {
 		if items, ok := data.(map[string]ld.VersionedData); ok {
+			atomic.AddInt64(&w.cacheHits, 1)
 			return items, nil
 		}
 	}
+	atomic.AddInt64(&w.cacheMisses, 1)
 	// Data set was not cached or cached value was not valid. Use singleflight to ensure that we'll only
 	// do this core query once even if multiple goroutines are requesting it
 	reqKey := fmt.Sprintf("all:%s", kind.GetNamespace())
@@ -356,6 +395,19 @@ func (w *FeatureStoreWrapper) All(kind ld.VersionedDataKind) (map[string]ld.Vers
 	return nil, nil
 }
 
+// RefreshAll queries the underlying data store for all items of the given kind, bypassing the cache,
+// and then replaces the cached data for that kind with the results. The SDK calls this periodically in
+// daemon mode (see Config.LddChangeDetectionInterval), so that it can detect changes made by another
+// process and so that the cache stays warm.
+func (w *FeatureStoreWrapper) RefreshAll(kind ld.VersionedDataKind) (map[string]ld.VersionedData, error) {
+	items, err := w.core.GetAllInternal(kind)
+	w.processError(err)
+	if err != nil {
+		return nil, err
+	}
+	return w.filterAndCacheItems(kind, items), nil
+}
+
 // Upsert updates or adds an item, with optional caching.
 func (w *FeatureStoreWrapper) Upsert(kind ld.VersionedDataKind, item ld.VersionedData) error {
 	finalItem, err := w.core.UpsertInternal(kind, item)
@@ -456,6 +508,78 @@ func (w *FeatureStoreWrapper) StatusSubscribe() internal.FeatureStoreStatusSubsc
 	return w.statusManager.Subscribe()
 }
 
+// GetCacheStats returns the number of queries that were answered from the cache, and the number that
+// were passed to the underlying data store, since the wrapper was created. If there is no cache, both
+// are zero.
+func (w *FeatureStoreWrapper) GetCacheStats() internal.FeatureStoreCacheStats {
+	return internal.FeatureStoreCacheStats{
+		Hits:   atomic.LoadInt64(&w.cacheHits),
+		Misses: atomic.LoadInt64(&w.cacheMisses),
+	}
+}
+
+// IsHeartbeatSupported returns true if the underlying data store implements FeatureStoreCoreHeartbeat.
+func (w *FeatureStoreWrapper) IsHeartbeatSupported() bool {
+	_, ok := w.core.(FeatureStoreCoreHeartbeat)
+	return ok
+}
+
+// WriteHeartbeat records the time when the data was last known to be current, if the underlying data
+// store implements FeatureStoreCoreHeartbeat. Heartbeats are not cached.
+func (w *FeatureStoreWrapper) WriteHeartbeat(t time.Time) error {
+	hb, ok := w.core.(FeatureStoreCoreHeartbeat)
+	if !ok {
+		return errHeartbeatNotSupported
+	}
+	err := hb.WriteHeartbeatInternal(t)
+	w.processError(err)
+	return err
+}
+
+// ReadHeartbeat returns the time that was most recently written by WriteHeartbeat, or a zero time if
+// there is none, if the underlying data store implements FeatureStoreCoreHeartbeat. Heartbeats are not
+// cached.
+func (w *FeatureStoreWrapper) ReadHeartbeat() (time.Time, error) {
+	hb, ok := w.core.(FeatureStoreCoreHeartbeat)
+	if !ok {
+		return time.Time{}, errHeartbeatNotSupported
+	}
+	t, err := hb.ReadHeartbeatInternal()
+	w.processError(err)
+	return t, err
+}
+
+// IsPollingCacheSupported returns true if the underlying data store implements FeatureStoreCorePollingCache.
+func (w *FeatureStoreWrapper) IsPollingCacheSupported() bool {
+	_, ok := w.core.(FeatureStoreCorePollingCache)
+	return ok
+}
+
+// WritePollingCache saves the last response from the polling service, if the underlying data store
+// implements FeatureStoreCorePollingCache. The response is not cached.
+func (w *FeatureStoreWrapper) WritePollingCache(data []byte) error {
+	pc, ok := w.core.(FeatureStoreCorePollingCache)
+	if !ok {
+		return errPollingCacheNotSupported
+	}
+	err := pc.WritePollingCacheInternal(data)
+	w.processError(err)
+	return err
+}
+
+// ReadPollingCache returns the data that was most recently written by WritePollingCache, or nil if there
+// is none, if the underlying data store implements FeatureStoreCorePollingCache. The response is not
+// cached.
+func (w *FeatureStoreWrapper) ReadPollingCache() ([]byte, error) {
+	pc, ok := w.core.(FeatureStoreCorePollingCache)
+	if !ok {
+		return nil, errPollingCacheNotSupported
+	}
+	data, err := pc.ReadPollingCacheInternal()
+	w.processError(err)
+	return data, err
+}
+
 // Used internally to describe this component in diagnostic data.
 func (w *FeatureStoreWrapper) GetDiagnosticsComponentTypeName() string {
 	if dcd, ok := w.core.(diagnosticsComponentDescriptor); ok {
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	ld "gopkg.in/launchdarkly/go-server-sdk.v4"
+	"gopkg.in/launchdarkly/go-server-sdk.v4/internal"
 	"gopkg.in/launchdarkly/go-server-sdk.v4/ldlog"
 	"gopkg.in/launchdarkly/go-server-sdk.v4/shared_test"
 )
@@ -67,6 +68,48 @@ func newCore(ttl time.Duration) *mockCore {
 	}
 }
 
+// Test implementation of FeatureStoreCoreHeartbeat
+type mockCoreWithHeartbeat struct {
+	*mockCore
+	heartbeat time.Time
+}
+
+func (c *mockCoreWithHeartbeat) WriteHeartbeatInternal(t time.Time) error {
+	if c.fakeError != nil {
+		return c.fakeError
+	}
+	c.heartbeat = t
+	return nil
+}
+
+func (c *mockCoreWithHeartbeat) ReadHeartbeatInternal() (time.Time, error) {
+	if c.fakeError != nil {
+		return time.Time{}, c.fakeError
+	}
+	return c.heartbeat, nil
+}
+
+// Test implementation of FeatureStoreCorePollingCache
+type mockCoreWithPollingCache struct {
+	*mockCore
+	pollingCache []byte
+}
+
+func (c *mockCoreWithPollingCache) WritePollingCacheInternal(data []byte) error {
+	if c.fakeError != nil {
+		return c.fakeError
+	}
+	c.pollingCache = data
+	return nil
+}
+
+func (c *mockCoreWithPollingCache) ReadPollingCacheInternal() ([]byte, error) {
+	if c.fakeError != nil {
+		return nil, c.fakeError
+	}
+	return c.pollingCache, nil
+}
+
 func newCoreWithInstrumentedQueries(ttl time.Duration) *mockCoreWithInstrumentedQueries {
 	return &mockCoreWithInstrumentedQueries{
 		cacheTTL:       ttl,
@@ -447,6 +490,104 @@ func TestFeatureStoreWrapper(t *testing.T) {
 		}
 	}, testUncached, testCached, testCachedIndefinitely)
 
+	runTests(t, "GetCacheStats", func(t *testing.T, mode testCacheMode, core *mockCore) {
+		w := NewFeatureStoreWrapper(core)
+		defer w.Close()
+		flag := ld.FeatureFlag{Key: "flag", Version: 1}
+		core.forceSet(ld.Features, &flag)
+
+		_, _ = w.Get(ld.Features, flag.Key)
+		_, _ = w.Get(ld.Features, flag.Key)
+		_, _ = w.Get(ld.Features, "missing")
+		_, _ = w.Get(ld.Features, "missing")
+		_, _ = w.All(ld.Features)
+		_, _ = w.All(ld.Features)
+
+		stats := w.GetCacheStats()
+		if mode.isCached() {
+			assert.Equal(t, internal.FeatureStoreCacheStats{Hits: 3, Misses: 3}, stats)
+		} else {
+			assert.Equal(t, internal.FeatureStoreCacheStats{}, stats)
+		}
+	}, testUncached, testCached, testCachedIndefinitely)
+
+	runTests(t, "RefreshAll", func(t *testing.T, mode testCacheMode, core *mockCore) {
+		w := NewFeatureStoreWrapper(core)
+		defer w.Close()
+		flagv1 := ld.FeatureFlag{Key: "flag", Version: 1}
+		flagv2 := ld.FeatureFlag{Key: "flag", Version: 2}
+		core.forceSet(ld.Features, &flagv1)
+		_, _ = w.All(ld.Features)
+		core.forceSet(ld.Features, &flagv2)
+
+		items, err := w.RefreshAll(ld.Features)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]ld.VersionedData{flagv1.Key: &flagv2}, items)
+
+		// the cache now has the refreshed data
+		items, _ = w.All(ld.Features)
+		assert.Equal(t, map[string]ld.VersionedData{flagv1.Key: &flagv2}, items)
+		item, _ := w.Get(ld.Features, flagv1.Key)
+		assert.Equal(t, &flagv2, item)
+	}, testUncached, testCached, testCachedIndefinitely)
+
+	t.Run("Heartbeat is passed to core", func(t *testing.T) {
+		core := &mockCoreWithHeartbeat{mockCore: newCore(0)}
+		w := NewFeatureStoreWrapper(core)
+		defer w.Close()
+		assert.True(t, w.IsHeartbeatSupported())
+
+		hb, err := w.ReadHeartbeat()
+		require.NoError(t, err)
+		assert.True(t, hb.IsZero())
+
+		now := time.Now()
+		require.NoError(t, w.WriteHeartbeat(now))
+		hb, err = w.ReadHeartbeat()
+		require.NoError(t, err)
+		assert.Equal(t, now, hb)
+
+		core.fakeError = errors.New("sorry")
+		assert.Equal(t, core.fakeError, w.WriteHeartbeat(now))
+	})
+
+	t.Run("Heartbeat is not supported if core does not implement it", func(t *testing.T) {
+		w := NewFeatureStoreWrapper(newCore(0))
+		defer w.Close()
+		assert.False(t, w.IsHeartbeatSupported())
+		assert.Error(t, w.WriteHeartbeat(time.Now()))
+		_, err := w.ReadHeartbeat()
+		assert.Error(t, err)
+	})
+
+	t.Run("Polling cache is passed to core", func(t *testing.T) {
+		core := &mockCoreWithPollingCache{mockCore: newCore(0)}
+		w := NewFeatureStoreWrapper(core)
+		defer w.Close()
+		assert.True(t, w.IsPollingCacheSupported())
+
+		data, err := w.ReadPollingCache()
+		require.NoError(t, err)
+		assert.Nil(t, data)
+
+		require.NoError(t, w.WritePollingCache([]byte("response")))
+		data, err = w.ReadPollingCache()
+		require.NoError(t, err)
+		assert.Equal(t, []byte("response"), data)
+
+		core.fakeError = errors.New("sorry")
+		assert.Equal(t, core.fakeError, w.WritePollingCache([]byte("response")))
+	})
+
+	t.Run("Polling cache is not supported if core does not implement it", func(t *testing.T) {
+		w := NewFeatureStoreWrapper(newCore(0))
+		defer w.Close()
+		assert.False(t, w.IsPollingCacheSupported())
+		assert.Error(t, w.WritePollingCache([]byte("response")))
+		_, err := w.ReadPollingCache()
+		assert.Error(t, err)
+	})
+
 	t.Run("Initialized calls InitializedInternal only if not already inited", func(t *testing.T) {
 		core := newCore(0)
 		w := NewFeatureStoreWrapper(core)